@@ -0,0 +1,79 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+var benchKey = tuple.Tuple{"users", int64(1234567), "profile", []byte("avatar"), int64(42)}
+
+func Benchmark_TuplePack(b *testing.B) {
+	b.ReportAllocs()
+
+	var r []byte
+	for n := 0; n < b.N; n++ {
+		r = benchKey.Pack()
+	}
+
+	b.SetBytes(int64(len(r)))
+	result = r
+}
+
+func Benchmark_TupleAppendPack(b *testing.B) {
+	b.ReportAllocs()
+
+	r := make([]byte, 0, 64)
+	for n := 0; n < b.N; n++ {
+		r = benchKey.AppendPack(r[:0])
+	}
+
+	b.SetBytes(int64(len(r)))
+	result = r
+}
+
+var sum int64
+
+func Benchmark_TupleUnpack(b *testing.B) {
+	b.ReportAllocs()
+
+	key := benchKey.Pack()
+	b.SetBytes(int64(len(key)))
+
+	var s int64
+	for n := 0; n < b.N; n++ {
+		t, err := tuple.Unpack(key)
+		if err != nil {
+			b.Fatal("failed to unpack tuple:", err)
+		}
+		s += t[1].(int64) + t[4].(int64)
+	}
+
+	sum = s
+}
+
+func Benchmark_TupleDecoder(b *testing.B) {
+	b.ReportAllocs()
+
+	key := benchKey.Pack()
+	b.SetBytes(int64(len(key)))
+
+	var s int64
+	for n := 0; n < b.N; n++ {
+		d := tuple.NewDecoder(key)
+		d.Skip()
+		id, err := d.NextInt64()
+		if err != nil {
+			b.Fatal("failed to decode tuple:", err)
+		}
+		d.Skip()
+		d.Skip()
+		v, err := d.NextInt64()
+		if err != nil {
+			b.Fatal("failed to decode tuple:", err)
+		}
+		s += id + v
+	}
+
+	sum = s
+}
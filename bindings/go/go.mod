@@ -1,4 +1,6 @@
 module github.com/apple/foundationdb/bindings/go
 
+go 1.23
+
 // The FoundationDB go bindings currently have no external golang dependencies outside of
 // the go standard library.
@@ -44,7 +44,7 @@ endif
 
 GO_PACKAGE_OUTDIR := $(GOPATH)/pkg/$(GOPLATFORM)/$(GO_IMPORT_PATH)
 
-GO_PACKAGES := fdb fdb/tuple fdb/subspace fdb/directory
+GO_PACKAGES := fdb fdb/tuple fdb/subspace fdb/directory fdb/memdb fdb/metrics
 GO_PACKAGE_OBJECTS := $(addprefix $(GO_PACKAGE_OUTDIR)/,$(GO_PACKAGES:=.a))
 
 GO_GEN := $(CURDIR)/bindings/go/src/fdb/generated.go
@@ -94,6 +94,14 @@ $(GO_PACKAGE_OUTDIR)/fdb/directory.a: $(GO_DEST)/.stamp $(GO_SRC) $(GO_PACKAGE_O
 	@echo "Compiling      fdb/directory"
 	@go install $(GO_IMPORT_PATH)/fdb/directory
 
+$(GO_PACKAGE_OUTDIR)/fdb/memdb.a: $(GO_DEST)/.stamp $(GO_SRC) $(GO_PACKAGE_OUTDIR)/fdb.a
+	@echo "Compiling      fdb/memdb"
+	@go install $(GO_IMPORT_PATH)/fdb/memdb
+
+$(GO_PACKAGE_OUTDIR)/fdb/metrics.a: $(GO_DEST)/.stamp $(GO_SRC) $(GO_PACKAGE_OUTDIR)/fdb.a
+	@echo "Compiling      fdb/metrics"
+	@go install $(GO_IMPORT_PATH)/fdb/metrics
+
 $(GO_PACKAGE_OUTDIR)/fdb.a: $(GO_DEST)/.stamp lib/libfdb_c.$(DLEXT) $(GO_SRC)
 	@echo "Compiling      fdb"
 	@go install $(GO_IMPORT_PATH)/fdb
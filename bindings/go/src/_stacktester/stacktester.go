@@ -45,21 +45,6 @@ const verbose bool = false
 var trMap = map[string]fdb.Transaction{}
 var trMapLock = sync.RWMutex{}
 
-// Make tuples sortable by byte-order
-type byBytes []tuple.Tuple
-
-func (b byBytes) Len() int {
-	return len(b)
-}
-
-func (b byBytes) Swap(i, j int) {
-	b[i], b[j] = b[j], b[i]
-}
-
-func (b byBytes) Less(i, j int) bool {
-	return bytes.Compare(b[i].Pack(), b[j].Pack()) < 0
-}
-
 func int64ToBool(i int64) bool {
 	switch i {
 	case 0:
@@ -168,40 +153,6 @@ func (sm *StackMachine) store(idx int, item interface{}) {
 	sm.stack = append(sm.stack, stackEntry{item, idx})
 }
 
-func tupleToString(t tuple.Tuple) string {
-	var buffer bytes.Buffer
-	buffer.WriteByte('(')
-	for i, el := range t {
-		if i > 0 {
-			buffer.WriteString(", ")
-		}
-		switch el := el.(type) {
-		case int64, uint64:
-			buffer.WriteString(fmt.Sprintf("%d", el))
-		case *big.Int:
-			buffer.WriteString(fmt.Sprintf("%s", el))
-		case []byte:
-			buffer.WriteString(fmt.Sprintf("%+q", string(el)))
-		case string:
-			buffer.WriteString(fmt.Sprintf("%+q", el))
-		case bool:
-			buffer.WriteString(fmt.Sprintf("%t", el))
-		case tuple.UUID:
-			buffer.WriteString(hex.EncodeToString(el[:]))
-		case float32, float64:
-			buffer.WriteString(fmt.Sprintf("%f", el))
-		case nil:
-			buffer.WriteString("nil")
-		case tuple.Tuple:
-			buffer.WriteString(tupleToString(el))
-		default:
-			log.Fatalf("Don't know how to stringify tuple elemement %v %T\n", el, el)
-		}
-	}
-	buffer.WriteByte(')')
-	return buffer.String()
-}
-
 func (sm *StackMachine) dumpStack() {
 	for i := len(sm.stack) - 1; i >= 0; i-- {
 		fmt.Printf(" %d.", sm.stack[i].idx)
@@ -226,7 +177,7 @@ func (sm *StackMachine) dumpStack() {
 		case bool:
 			fmt.Printf(" %t", el)
 		case tuple.Tuple:
-			fmt.Printf(" %s", tupleToString(el))
+			fmt.Printf(" %s", el)
 		case tuple.UUID:
 			fmt.Printf(" %s", hex.EncodeToString(el[:]))
 		case float32, float64:
@@ -700,7 +651,9 @@ func (sm *StackMachine) processInst(idx int, inst tuple.Tuple) {
 				panic(e)
 			}
 		}
-		sort.Sort(byBytes(tuples))
+		sort.Slice(tuples, func(i, j int) bool {
+			return tuple.Compare(tuples[i], tuples[j]) < 0
+		})
 		for _, t := range tuples {
 			sm.store(idx, t.Pack())
 		}
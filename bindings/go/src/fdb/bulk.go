@@ -0,0 +1,417 @@
+/*
+ * bulk.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"errors"
+	"sync"
+)
+
+// BulkWriterOptions specify how a BulkWriter batches and commits mutations.
+type BulkWriterOptions struct {
+	// MaxBatchBytes is the approximate size of the mutations committed in a
+	// single transaction. A value of 0 uses 1MB. Values above the transaction
+	// size limit of 10MB cause batches to fail and be split.
+	MaxBatchBytes int
+
+	// MaxBatchMutations is the maximum number of mutations committed in a
+	// single transaction. A value of 0 uses 10000.
+	MaxBatchMutations int
+
+	// Concurrency is the number of batches committed at once. A value of 0
+	// commits 4 batches at a time.
+	Concurrency int
+
+	// Progress, if not nil, is called once for each batch after it has been
+	// committed, has failed, or has been abandoned because another batch
+	// failed. Calls are not concurrent, and are made in the order in which
+	// batches complete.
+	Progress func(BulkProgress)
+}
+
+// BulkProgress describes a batch of mutations committed (or not) by a
+// BulkWriter, and the progress of the BulkWriter so far.
+type BulkProgress struct {
+	// Mutations is the number of mutations in the batch, and Bytes their
+	// approximate size.
+	Mutations int
+	Bytes     int64
+
+	// Err is the error with which the batch failed, after any retries, or nil
+	// if it was committed. A batch abandoned because another batch failed
+	// reports the error of that batch.
+	Err error
+
+	// Batches, TotalMutations and TotalBytes count the batches committed by
+	// the BulkWriter so far, including this one, and their mutations and size.
+	Batches        int
+	TotalMutations int64
+	TotalBytes     int64
+}
+
+// A BulkWriter loads an unbounded stream of mutations into the database by
+// dividing it into batches, each of which is committed in its own transaction.
+// It is the Go counterpart of the BulkLoader of layers/bulkload.
+//
+// Mutations are collected into a batch until it reaches
+// BulkWriterOptions.MaxBatchBytes or MaxBatchMutations, at which point the
+// batch is committed while further mutations are collected. Each batch is
+// committed with (ReadWriterTransactor).TransactReadWriter, which for a
+// Database retries the batch as its retry policy allows. Before committing, the
+// size of the batch is checked with GetApproximateSize; a batch much larger
+// than MaxBatchBytes, or one which fails with transaction_too_large, is split in
+// half and each half committed separately.
+//
+// Each batch is atomic, but a BulkWriter as a whole is not: if a batch fails,
+// other batches may already have been committed, and batches are committed
+// concurrently, in no particular order. Mutations of the same key should be
+// separated by a call to Flush if their order matters.
+//
+// Once a batch has failed, every method of the BulkWriter returns its error
+// and no further batches are started.
+//
+// Except for the Progress callback, a BulkWriter may not be used by multiple
+// goroutines at once.
+type BulkWriter struct {
+	t       ReadWriterTransactor
+	options BulkWriterOptions
+
+	batch      []bulkMutation
+	batchBytes int
+
+	batches chan []bulkMutation
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+	closed  bool
+
+	mu  sync.Mutex
+	err error
+
+	// reportMu serializes updates to progress and calls to Progress.
+	reportMu sync.Mutex
+	progress BulkProgress
+}
+
+// bulkMutation is a mutation buffered by a BulkWriter, applied to a
+// transaction by apply. For ClearRange, param is the end of the range.
+type bulkMutation struct {
+	apply func(tr ReadWriter, key KeyConvertible, param []byte)
+	key   Key
+	param []byte
+}
+
+// size estimates the contribution of the mutation to the approximate size of a
+// transaction: its key and parameter, and its write conflict range.
+func (m bulkMutation) size() int {
+	return 2*len(m.key) + len(m.param)
+}
+
+var (
+	// errBatchTooLarge is returned by a batch whose approximate size is too
+	// large.
+	errBatchTooLarge = errors.New("batch too large")
+
+	errBulkWriterClosed = errors.New("bulk writer is closed")
+
+	errBulkWriterTransaction = errors.New("bulk writer cannot commit batches in a single transaction")
+)
+
+// NewBulkWriter returns a BulkWriter which commits mutations with t, typically
+// a Database. Close must be called once all mutations have been written.
+//
+// t must run each call of TransactReadWriter in a transaction of its own, as a
+// Database does. A transaction (such as a Transaction) runs the function in
+// itself instead, which would neither commit nor split the batches, so if t is
+// a ReadWriter every method of the returned BulkWriter fails.
+func NewBulkWriter(t ReadWriterTransactor, options BulkWriterOptions) *BulkWriter {
+	if options.MaxBatchBytes <= 0 {
+		options.MaxBatchBytes = 1 << 20
+	}
+	if options.MaxBatchMutations <= 0 {
+		options.MaxBatchMutations = 10000
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = 4
+	}
+
+	w := &BulkWriter{
+		t:       t,
+		options: options,
+		batches: make(chan []bulkMutation),
+	}
+	if _, ok := t.(ReadWriter); ok {
+		w.err = errBulkWriterTransaction
+	}
+
+	w.workers.Add(options.Concurrency)
+	for i := 0; i < options.Concurrency; i++ {
+		go func() {
+			defer w.workers.Done()
+			for batch := range w.batches {
+				w.commit(batch)
+				w.pending.Done()
+			}
+		}()
+	}
+
+	return w
+}
+
+// Set adds a mutation setting key to value. The key and value are copied.
+func (w *BulkWriter) Set(key KeyConvertible, value []byte) error {
+	return w.add(ReadWriter.Set, key, value)
+}
+
+// Clear adds a mutation removing key.
+func (w *BulkWriter) Clear(key KeyConvertible) error {
+	return w.add(func(tr ReadWriter, key KeyConvertible, _ []byte) {
+		tr.Clear(key)
+	}, key, nil)
+}
+
+// ClearRange adds a mutation removing all keys in er.
+func (w *BulkWriter) ClearRange(er ExactRange) error {
+	begin, end := er.FDBRangeKeys()
+	return w.add(func(tr ReadWriter, key KeyConvertible, param []byte) {
+		tr.ClearRange(KeyRange{Begin: key, End: Key(param)})
+	}, begin, end.FDBKey())
+}
+
+// Add adds an atomic addition mutation, as (Transaction).Add.
+func (w *BulkWriter) Add(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.Add, key, param)
+}
+
+// BitAnd adds an atomic bitwise and mutation, as (Transaction).BitAnd.
+func (w *BulkWriter) BitAnd(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.BitAnd, key, param)
+}
+
+// BitOr adds an atomic bitwise or mutation, as (Transaction).BitOr.
+func (w *BulkWriter) BitOr(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.BitOr, key, param)
+}
+
+// BitXor adds an atomic bitwise xor mutation, as (Transaction).BitXor.
+func (w *BulkWriter) BitXor(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.BitXor, key, param)
+}
+
+// AppendIfFits adds an atomic append mutation, as (Transaction).AppendIfFits.
+func (w *BulkWriter) AppendIfFits(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.AppendIfFits, key, param)
+}
+
+// Max adds an atomic maximum mutation, as (Transaction).Max.
+func (w *BulkWriter) Max(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.Max, key, param)
+}
+
+// Min adds an atomic minimum mutation, as (Transaction).Min.
+func (w *BulkWriter) Min(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.Min, key, param)
+}
+
+// ByteMax adds an atomic lexicographic maximum mutation, as
+// (Transaction).ByteMax.
+func (w *BulkWriter) ByteMax(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.ByteMax, key, param)
+}
+
+// ByteMin adds an atomic lexicographic minimum mutation, as
+// (Transaction).ByteMin.
+func (w *BulkWriter) ByteMin(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.ByteMin, key, param)
+}
+
+// CompareAndClear adds an atomic compare and clear mutation, as
+// (Transaction).CompareAndClear.
+func (w *BulkWriter) CompareAndClear(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.CompareAndClear, key, param)
+}
+
+// SetVersionstampedKey adds a versionstamped key mutation, as
+// (Transaction).SetVersionstampedKey. Every mutation in a batch receives the
+// versionstamp of that batch.
+func (w *BulkWriter) SetVersionstampedKey(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.SetVersionstampedKey, key, param)
+}
+
+// SetVersionstampedValue adds a versionstamped value mutation, as
+// (Transaction).SetVersionstampedValue. Every mutation in a batch receives the
+// versionstamp of that batch.
+func (w *BulkWriter) SetVersionstampedValue(key KeyConvertible, param []byte) error {
+	return w.add(ReadWriter.SetVersionstampedValue, key, param)
+}
+
+// Flush commits the mutations added so far, and waits until every batch has
+// been committed or has failed. It returns the error of the first batch to
+// fail, if any.
+func (w *BulkWriter) Flush() error {
+	if e := w.check(); e != nil {
+		return e
+	}
+	w.dispatch()
+	w.pending.Wait()
+	return w.Err()
+}
+
+// Close flushes the BulkWriter, as Flush, and stops its goroutines. If a batch
+// has already failed, the mutations collected since are not committed, and
+// are reported to Progress as a batch failing with its error. The BulkWriter
+// may not be used after it is closed.
+func (w *BulkWriter) Close() error {
+	if w.closed {
+		return w.Err()
+	}
+	if e := w.Err(); e != nil {
+		if len(w.batch) > 0 {
+			w.report(len(w.batch), int64(w.batchBytes), e)
+			w.batch, w.batchBytes = nil, 0
+		}
+	} else {
+		w.dispatch()
+	}
+	w.closed = true
+	w.pending.Wait()
+	close(w.batches)
+	w.workers.Wait()
+	return w.Err()
+}
+
+// Err returns the error of the first batch to fail, or nil if no batch has
+// failed.
+func (w *BulkWriter) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+func (w *BulkWriter) check() error {
+	if w.closed {
+		return errBulkWriterClosed
+	}
+	return w.Err()
+}
+
+func (w *BulkWriter) add(apply func(ReadWriter, KeyConvertible, []byte), key KeyConvertible, param []byte) error {
+	if e := w.check(); e != nil {
+		return e
+	}
+
+	m := bulkMutation{
+		apply: apply,
+		key:   append(Key{}, key.FDBKey()...),
+		param: append([]byte{}, param...),
+	}
+	if len(w.batch) > 0 && (len(w.batch) >= w.options.MaxBatchMutations || w.batchBytes+m.size() > w.options.MaxBatchBytes) {
+		w.dispatch()
+	}
+	w.batch = append(w.batch, m)
+	w.batchBytes += m.size()
+
+	return nil
+}
+
+// dispatch hands the current batch to a worker, blocking until one is free.
+func (w *BulkWriter) dispatch() {
+	if len(w.batch) == 0 {
+		return
+	}
+	w.pending.Add(1)
+	w.batches <- w.batch
+	w.batch = nil
+	w.batchBytes = 0
+}
+
+// commit commits batch, splitting it if it is too large, and reports the
+// outcome of each resulting transaction. Once a batch has failed, batch is
+// reported with its error instead.
+func (w *BulkWriter) commit(batch []bulkMutation) {
+	if e := w.Err(); e != nil {
+		var size int64
+		for _, m := range batch {
+			size += int64(m.size())
+		}
+		w.report(len(batch), size, e)
+		return
+	}
+
+	var size int64
+	_, e := w.t.TransactReadWriter(func(tr ReadWriter) (interface{}, error) {
+		size = 0
+		for _, m := range batch {
+			m.apply(tr, m.key, m.param)
+			size += int64(m.size())
+		}
+
+		if as, ok := tr.(interface{ GetApproximateSize() FutureInt64 }); ok {
+			s, e := as.GetApproximateSize().Get()
+			if e != nil {
+				return nil, e
+			}
+			if s > 2*int64(w.options.MaxBatchBytes) && len(batch) > 1 {
+				return nil, errBatchTooLarge
+			}
+			size = s
+		}
+
+		return nil, nil
+	})
+
+	if (e == errBatchTooLarge || errors.Is(e, ErrTransactionTooLarge)) && len(batch) > 1 {
+		w.commit(batch[:len(batch)/2])
+		w.commit(batch[len(batch)/2:])
+		return
+	}
+
+	if e != nil {
+		w.mu.Lock()
+		if w.err == nil {
+			w.err = e
+		}
+		w.mu.Unlock()
+	}
+
+	w.report(len(batch), size, e)
+}
+
+// report updates the progress of the BulkWriter with a batch of n mutations
+// of the given size, which failed with e if it is not nil, and passes it to
+// the Progress callback.
+func (w *BulkWriter) report(n int, size int64, e error) {
+	w.reportMu.Lock()
+	defer w.reportMu.Unlock()
+
+	p := BulkProgress{Mutations: n, Bytes: size, Err: e}
+	if e == nil {
+		w.progress.Batches++
+		w.progress.TotalMutations += int64(n)
+		w.progress.TotalBytes += size
+	}
+	p.Batches, p.TotalMutations, p.TotalBytes = w.progress.Batches, w.progress.TotalMutations, w.progress.TotalBytes
+
+	if w.options.Progress != nil {
+		w.options.Progress(p)
+	}
+}
@@ -27,7 +27,11 @@ package fdb
 import "C"
 
 import (
+	"context"
+	"log/slog"
 	"runtime"
+	"sync"
+	"time"
 )
 
 // Database is a handle to a FoundationDB database. Database is a lightweight
@@ -40,9 +44,38 @@ import (
 // method.
 type Database struct {
 	*database
+	config *databaseConfig
+}
+
+// databaseConfig holds the client-side settings of a Database handle. It is
+// never modified once created; the With* methods of Database return a copy of
+// the handle referring to an updated copy of the configuration.
+type databaseConfig struct {
+	retryPolicy       RetryPolicy
+	idempotencyPrefix Key
+	name              string
+	metrics           Metrics
+	tracer            Tracer
+	logger            *slog.Logger
+	slowLatency       time.Duration
+	slowSize          int64
+}
+
+// withConfig returns a copy of d whose configuration has been modified by
+// update.
+func (d Database) withConfig(update func(*databaseConfig)) Database {
+	var c databaseConfig
+	if d.config != nil {
+		c = *d.config
+	}
+	update(&c)
+	d.config = &c
+	return d
 }
 
 type database struct {
+	// mu guards ptr, which is nil once the database has been closed.
+	mu  sync.RWMutex
 	ptr *C.FDBDatabase
 }
 
@@ -54,15 +87,68 @@ type DatabaseOptions struct {
 }
 
 func (opt DatabaseOptions) setOpt(code int, param []byte) error {
-	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
+	opt.d.mu.RLock()
+	defer opt.d.mu.RUnlock()
+
+	if opt.d.ptr == nil {
+		return ErrDatabaseClosed
+	}
+
+	e := setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
 		return C.fdb_database_set_option(opt.d.ptr, C.FDBDatabaseOption(code), p, pl)
 	}, param)
+	if e != nil {
+		logOptionError(logger(), "database", code, e)
+	}
+	return e
 }
 
 func (d *database) destroy() {
 	C.fdb_database_destroy(d.ptr)
 }
 
+// finalize destroys a database handle which was not closed before becoming
+// unreachable.
+func (d *database) finalize() {
+	logger().Debug("fdb database handle finalized without being closed")
+	d.destroy()
+}
+
+// Close destroys the database handle, releasing the resources it holds in the
+// FoundationDB C library immediately rather than when the Database is garbage
+// collected. The handle is also removed from the databases cached by
+// OpenDatabase, so a later call to OpenDatabase with the same cluster file
+// opens a new handle.
+//
+// All copies of a Database share one handle, so Close affects every copy
+// (including those returned by earlier calls to OpenDatabase for the same
+// cluster file). Once Close has been called, CreateTransaction, Transact and
+// the other methods which create transactions return ErrDatabaseClosed.
+// Transactions created before Close remain usable. Calling Close more than
+// once has no effect.
+func (d Database) Close() error {
+	networkMutex.Lock()
+	for clusterFile, db := range openDatabases {
+		if db.database == d.database {
+			delete(openDatabases, clusterFile)
+		}
+	}
+	networkMutex.Unlock()
+
+	d.database.mu.Lock()
+	defer d.database.mu.Unlock()
+
+	if d.ptr == nil {
+		return nil
+	}
+
+	runtime.SetFinalizer(d.database, nil)
+	d.database.destroy()
+	d.ptr = nil
+
+	return nil
+}
+
 // CreateTransaction returns a new FoundationDB transaction. It is generally
 // preferable to use the (Database).Transact method, which handles
 // automatically creating and committing a transaction with appropriate retry
@@ -70,18 +156,75 @@ func (d *database) destroy() {
 func (d Database) CreateTransaction() (Transaction, error) {
 	var outt *C.FDBTransaction
 
+	d.database.mu.RLock()
+	defer d.database.mu.RUnlock()
+
+	if d.ptr == nil {
+		return Transaction{}, ErrDatabaseClosed
+	}
+
 	if err := C.fdb_database_create_transaction(d.ptr, &outt); err != 0 {
 		return Transaction{}, Error{int(err)}
 	}
 
-	t := &transaction{outt, d}
+	t := &transaction{ptr: outt, db: d}
 	runtime.SetFinalizer(t, (*transaction).destroy)
 
+	if tracer := d.tracer(); tracer != nil {
+		t.trace.Store(newTransactionTrace(tracer, context.Background()))
+	}
+
 	return Transaction{t}, nil
 }
 
-func retryable(wrapped func() (interface{}, error), onError func(Error) FutureNil) (ret interface{}, e error) {
-	for {
+// RetryPolicy observes and controls the retry loop of (Database).Transact and
+// related methods. A RetryPolicy may be attached to a Database with
+// (Database).WithRetryPolicy.
+//
+// A RetryPolicy supplements, rather than replaces, (Transaction).OnError: an
+// error which the policy elects to retry is still passed to OnError, which
+// resets the transaction, applies the standard backoff and decides whether the
+// error is retryable at all. In particular, commit_unknown_result is handled
+// exactly as it is without a policy.
+type RetryPolicy interface {
+	// Retry is called each time an attempt fails with an Error, with the
+	// number of the failed attempt (starting at 1). If retry is false, the
+	// retry loop stops and the error is returned to the caller. Otherwise,
+	// once OnError has determined that the error is retryable, the next
+	// attempt is delayed by delay in addition to the standard backoff.
+	//
+	// Retry may be called concurrently from multiple goroutines.
+	Retry(e Error, attempt int) (delay time.Duration, retry bool)
+}
+
+// RetryPolicyFunc is an adapter to allow the use of an ordinary function as a
+// RetryPolicy.
+type RetryPolicyFunc func(e Error, attempt int) (time.Duration, bool)
+
+// Retry calls f(e, attempt).
+func (f RetryPolicyFunc) Retry(e Error, attempt int) (time.Duration, bool) {
+	return f(e, attempt)
+}
+
+// WithRetryPolicy returns a copy of d whose transactional methods consult p
+// between attempts. The original Database is unaffected, so a policy may be
+// applied to a single call with d.WithRetryPolicy(p).Transact(f). A nil p
+// removes any policy.
+func (d Database) WithRetryPolicy(p RetryPolicy) Database {
+	return d.withConfig(func(c *databaseConfig) {
+		c.retryPolicy = p
+	})
+}
+
+func (d Database) retryPolicy() RetryPolicy {
+	if d.config == nil {
+		return nil
+	}
+	return d.config.retryPolicy
+}
+
+func retryable(ctx context.Context, wrapped func() (interface{}, error), onError func(Error) FutureNil, policy RetryPolicy, retried func(e Error, attempt int)) (ret interface{}, e error) {
+	for attempt := 1; ; attempt++ {
 		ret, e = wrapped()
 
 		// No error means success!
@@ -89,17 +232,182 @@ func retryable(wrapped func() (interface{}, error), onError func(Error) FutureNi
 			return
 		}
 
+		// Once the context is done, the transaction has been cancelled and
+		// there is no point in trying again
+		if ce := ctx.Err(); ce != nil {
+			return nil, ce
+		}
+
 		ep, ok := e.(Error)
-		if ok {
-			e = onError(ep).Get()
+		if !ok {
+			return
+		}
+
+		var delay time.Duration
+		if policy != nil {
+			var retry bool
+			if delay, retry = policy.Retry(ep, attempt); !retry {
+				return
+			}
 		}
 
 		// If OnError returns an error, then it's not
 		// retryable; otherwise take another pass at things
-		if e != nil {
+		if e = onError(ep).Get(); e != nil {
+			if ce := ctx.Err(); ce != nil {
+				return nil, ce
+			}
 			return
 		}
+
+		if retried != nil {
+			retried(ep, attempt)
+		}
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
+
+// createTransactionContext creates a transaction that is bound to ctx. If ctx
+// has a deadline, the remaining time is set as the timeout of the
+// transaction. The returned function must be called once the transaction is
+// no longer in use; until then, the transaction will be cancelled as soon as
+// ctx is done.
+func (d Database) createTransactionContext(ctx context.Context) (Transaction, func(), error) {
+	if e := ctx.Err(); e != nil {
+		return Transaction{}, nil, e
+	}
+
+	tr, e := d.CreateTransaction()
+	if e != nil {
+		return Transaction{}, nil, e
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		// Round up, so that the transaction does not time out before ctx
+		timeout := (time.Until(deadline) + time.Millisecond - 1).Milliseconds()
+		if timeout <= 0 {
+			return Transaction{}, nil, context.DeadlineExceeded
+		}
+		if e := tr.Options().SetTimeout(timeout); e != nil {
+			return Transaction{}, nil, e
+		}
+	}
+
+	// A context that can never be done needs no watcher
+	if ctx.Done() == nil {
+		return tr, func() {}, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Cancelling the transaction also sets all of its outstanding
+			// futures to an error, unblocking anything waiting on them
+			tr.Cancel()
+		case <-done:
+		}
+	}()
+
+	return tr, func() { close(done) }, nil
+}
+
+// contextError translates the error of a transaction bound to ctx. A
+// transaction_timed_out error is reported as ctx.Err() once ctx is done, or as
+// context.DeadlineExceeded once its deadline has passed. A timeout set with
+// (TransactionOptions).SetTimeout which expires before ctx is done is not
+// translated.
+func contextError(ctx context.Context, e error) error {
+	if e == ErrTransactionTimedOut {
+		if ce := ctx.Err(); ce != nil {
+			return ce
+		}
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			return context.DeadlineExceeded
+		}
 	}
+	return e
+}
+
+// transact is the retry loop shared by Transact, ReadTransact,
+// TransactContext and ReadTransactContext. Read-only transactions never
+// record an idempotency ID.
+func (d Database) transact(ctx context.Context, f func(Transaction) (interface{}, error), readOnly bool) (interface{}, error) {
+	done, e := beginTransact()
+	if e != nil {
+		return nil, e
+	}
+	defer done()
+
+	tr, release, e := d.createTransactionContext(ctx)
+	// Any error here is non-retryable
+	if e != nil {
+		return nil, e
+	}
+	defer release()
+
+	var idem *idempotentCommit
+	if !readOnly {
+		if idem, e = d.newIdempotentCommit(); e != nil {
+			return nil, e
+		}
+	}
+
+	rec := d.newTransactionRecorder(readOnly)
+	attempt := 0
+
+	wrapped := func() (ret interface{}, e error) {
+		attempt++
+		at := d.beginAttemptTrace(ctx, tr, attempt, readOnly)
+		defer func() {
+			rec.endAttempt(e)
+			at.end(e)
+		}()
+		defer panicToError(&e)
+
+		rec.beginAttempt(tr)
+
+		if idem != nil {
+			if ret, done := idem.committed(tr); done {
+				return ret, nil
+			}
+		}
+
+		ret, e = f(tr)
+
+		if e == nil {
+			if idem != nil {
+				idem.prepare(tr, ret)
+			}
+
+			e = rec.commit(tr)
+
+			if idem != nil {
+				ep, ok := e.(Error)
+				idem.observe(ok && ep.MaybeCommitted())
+			}
+		}
+
+		return
+	}
+
+	retried := func(e Error, attempt int) {
+		d.logRetry(ctx, e, attempt)
+	}
+
+	ret, e := retryable(ctx, wrapped, tr.OnError, d.retryPolicy(), retried)
+	e = contextError(ctx, e)
+	rec.finish(e)
+	return ret, e
 }
 
 // Transact runs a caller-provided function inside a retry loop, providing it
@@ -122,25 +430,7 @@ func retryable(wrapped func() (interface{}, error), onError func(Error) FutureNi
 // See the Transactor interface for an example of using Transact with
 // Transaction and Database objects.
 func (d Database) Transact(f func(Transaction) (interface{}, error)) (interface{}, error) {
-	tr, e := d.CreateTransaction()
-	// Any error here is non-retryable
-	if e != nil {
-		return nil, e
-	}
-
-	wrapped := func() (ret interface{}, e error) {
-		defer panicToError(&e)
-
-		ret, e = f(tr)
-
-		if e == nil {
-			e = tr.Commit().Get()
-		}
-
-		return
-	}
-
-	return retryable(wrapped, tr.OnError)
+	return d.transact(context.Background(), f, false)
 }
 
 // ReadTransact runs a caller-provided function inside a retry loop, providing
@@ -162,25 +452,30 @@ func (d Database) Transact(f func(Transaction) (interface{}, error)) (interface{
 // See the ReadTransactor interface for an example of using ReadTransact with
 // Transaction, Snapshot and Database objects.
 func (d Database) ReadTransact(f func(ReadTransaction) (interface{}, error)) (interface{}, error) {
-	tr, e := d.CreateTransaction()
-	// Any error here is non-retryable
-	if e != nil {
-		return nil, e
-	}
-
-	wrapped := func() (ret interface{}, e error) {
-		defer panicToError(&e)
-
-		ret, e = f(tr)
-
-		if e == nil {
-			e = tr.Commit().Get()
-		}
+	return d.transact(context.Background(), func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	}, true)
+}
 
-		return
-	}
+// TransactContext is like Transact, but is bound to the provided context. If
+// ctx is done before the transaction has been committed, the transaction and
+// all of its outstanding futures are cancelled, no further retries are
+// attempted, and TransactContext returns ctx.Err(). If ctx has a deadline, the
+// time remaining until the deadline is set as the timeout of the transaction.
+//
+// As with (Transaction).Cancel, if ctx is done while the commit is in flight,
+// the transaction may or may not have been committed.
+func (d Database) TransactContext(ctx context.Context, f func(Transaction) (interface{}, error)) (interface{}, error) {
+	return d.transact(ctx, f, false)
+}
 
-	return retryable(wrapped, tr.OnError)
+// ReadTransactContext is like ReadTransact, but is bound to the provided
+// context in the same way as TransactContext. If ctx is done before the
+// function has completed successfully, ReadTransactContext returns ctx.Err().
+func (d Database) ReadTransactContext(ctx context.Context, f func(ReadTransaction) (interface{}, error)) (interface{}, error) {
+	return d.transact(ctx, func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	}, true)
 }
 
 // Options returns a DatabaseOptions instance suitable for setting options
@@ -0,0 +1,158 @@
+/*
+ * delete.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"context"
+	"time"
+)
+
+// DeleteOptions specify how DeleteWhere walks a range.
+type DeleteOptions struct {
+	// BatchKeys is the number of keys examined in each transaction. A value of
+	// 0 examines 1000 keys per transaction.
+	BatchKeys int
+
+	// KeysPerSecond, if positive, limits the rate at which keys are examined.
+	KeysPerSecond float64
+
+	// Cursor, if not nil, is the Cursor of a DeleteProgress reported by an
+	// earlier call to DeleteWhere for the same range. Keys up to and including
+	// Cursor are skipped.
+	Cursor Key
+
+	// Progress, if not nil, is called after each transaction has committed.
+	Progress func(DeleteProgress)
+}
+
+// DeleteProgress describes the progress of DeleteWhere.
+type DeleteProgress struct {
+	// Examined and Deleted count the keys examined and deleted so far.
+	Examined int64
+	Deleted  int64
+
+	// Cursor is the last key examined by a committed transaction. It is the
+	// resume token of the deletion: if the process running DeleteWhere fails,
+	// another may continue the deletion by passing Cursor as
+	// DeleteOptions.Cursor.
+	Cursor Key
+}
+
+// DeleteWhere clears every key in er for whose key-value pair predicate
+// returns true. Unlike a single transaction, which is limited in duration and
+// size, DeleteWhere walks the range in a series of transactions, each of which
+// examines up to options.BatchKeys keys and clears those that match.
+//
+// Each transaction reads the keys it examines without snapshot isolation, so
+// that a key modified concurrently causes the transaction to be retried and
+// predicate to be evaluated against the new value. predicate is called within
+// the transactional function, so every retry of a transaction (whether after
+// a conflict or any other retryable error) calls it again for each key of the
+// batch. It should therefore be cheap, and should not have side effects.
+//
+// When t is a Database, ctx also interrupts the retry loop of the transaction
+// in progress, as it does for (Database).TransactContext. DeleteWhere returns
+// the progress it has made, and the first error which prevented a transaction
+// from committing, or ctx.Err() if ctx is done. A deletion which returns an
+// error may be continued from the Cursor of the returned progress.
+func DeleteWhere(ctx context.Context, t ReadWriterTransactor, er ExactRange, predicate func(KeyValue) bool, options DeleteOptions) (DeleteProgress, error) {
+	batchKeys := options.BatchKeys
+	if batchKeys <= 0 {
+		batchKeys = 1000
+	}
+
+	bk, ek := er.FDBRangeKeys()
+	begin, end := bk.FDBKey(), ek.FDBKey()
+	p := DeleteProgress{Cursor: options.Cursor}
+	start := time.Now()
+
+	for {
+		if e := ctx.Err(); e != nil {
+			return p, e
+		}
+
+		sr := SelectorRange{Begin: FirstGreaterOrEqual(begin), End: FirstGreaterOrEqual(end)}
+		if p.Cursor != nil {
+			sr.Begin = FirstGreaterThan(p.Cursor)
+		}
+
+		var examined, deleted int
+		var last Key
+		f := func(tr ReadWriter) (interface{}, error) {
+			kvs, e := tr.GetRange(sr, RangeOptions{Limit: batchKeys, Mode: StreamingModeWantAll}).GetSliceWithError()
+			if e != nil {
+				return nil, e
+			}
+
+			examined, deleted, last = len(kvs), 0, nil
+			for _, kv := range kvs {
+				if predicate(kv) {
+					tr.Clear(kv.Key)
+					deleted++
+				}
+			}
+			if len(kvs) > 0 {
+				last = kvs[len(kvs)-1].Key
+			}
+			return nil, nil
+		}
+
+		var e error
+		if d, ok := t.(Database); ok {
+			_, e = d.transact(ctx, func(tr Transaction) (interface{}, error) {
+				return f(tr)
+			}, false)
+		} else {
+			_, e = t.TransactReadWriter(f)
+		}
+		if e != nil {
+			return p, e
+		}
+
+		if examined == 0 {
+			return p, nil
+		}
+		p.Examined += int64(examined)
+		p.Deleted += int64(deleted)
+		p.Cursor = last
+		if options.Progress != nil {
+			options.Progress(p)
+		}
+		if examined < batchKeys {
+			return p, nil
+		}
+
+		if options.KeysPerSecond > 0 {
+			due := start.Add(time.Duration(float64(p.Examined) / options.KeysPerSecond * float64(time.Second)))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return p, ctx.Err()
+				}
+			}
+		}
+	}
+}
@@ -62,9 +62,19 @@ func windowSize(start int64) int64 {
 	return 8192
 }
 
-func (hca highContentionAllocator) allocate(tr fdb.Transaction, s subspace.Subspace) (subspace.Subspace, error) {
+// setNextWriteNoWriteConflictRange sets the option of the same name on tr if it
+// supports transaction options (as fdb.Transaction does). Otherwise the next
+// write adds a write conflict range as usual, which is safe but may cause
+// additional conflicts between concurrent allocations.
+func setNextWriteNoWriteConflictRange(tr fdb.ReadWriter) {
+	if o, ok := tr.(interface{ Options() fdb.TransactionOptions }); ok {
+		o.Options().SetNextWriteNoWriteConflictRange()
+	}
+}
+
+func (hca highContentionAllocator) allocate(tr fdb.ReadWriter, s subspace.Subspace) (subspace.Subspace, error) {
 	for {
-		rr := tr.Snapshot().GetRange(hca.counters, fdb.RangeOptions{Limit: 1, Reverse: true})
+		rr := tr.SnapshotReader().GetRange(hca.counters, fdb.RangeOptions{Limit: 1, Reverse: true})
 		kvs, e := rr.GetSliceWithError()
 		if e != nil {
 			return nil, e
@@ -87,13 +97,13 @@ func (hca highContentionAllocator) allocate(tr fdb.Transaction, s subspace.Subsp
 
 			if windowAdvanced {
 				tr.ClearRange(fdb.KeyRange{hca.counters, hca.counters.Sub(start)})
-				tr.Options().SetNextWriteNoWriteConflictRange()
+				setNextWriteNoWriteConflictRange(tr)
 				tr.ClearRange(fdb.KeyRange{hca.recent, hca.recent.Sub(start)})
 			}
 
 			// Increment the allocation count for the current window
 			tr.Add(hca.counters.Sub(start), oneBytes)
-			countFuture := tr.Snapshot().Get(hca.counters.Sub(start))
+			countFuture := tr.SnapshotReader().Get(hca.counters.Sub(start))
 
 			allocatorMutex.Unlock()
 
@@ -131,9 +141,9 @@ func (hca highContentionAllocator) allocate(tr fdb.Transaction, s subspace.Subsp
 
 			allocatorMutex.Lock()
 
-			latestCounter := tr.Snapshot().GetRange(hca.counters, fdb.RangeOptions{Limit: 1, Reverse: true})
+			latestCounter := tr.SnapshotReader().GetRange(hca.counters, fdb.RangeOptions{Limit: 1, Reverse: true})
 			candidateValue := tr.Get(key)
-			tr.Options().SetNextWriteNoWriteConflictRange()
+			setNextWriteNoWriteConflictRange(tr)
 			tr.Set(key, []byte(""))
 
 			allocatorMutex.Unlock()
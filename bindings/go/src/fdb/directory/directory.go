@@ -37,6 +37,16 @@
 //
 // Directory operations are transactional. A byte slice layer option is used as
 // a metadata identifier when opening a directory.
+//
+// Each directory operation also has a variant with a ReadWriter or Reader
+// suffix (such as CreateOrOpenReadWriter and OpenReader) which accepts an
+// fdb.ReadWriterTransactor or fdb.ReaderTransactor in place of an
+// fdb.Transactor or fdb.ReadTransactor. These may be used with any
+// implementation of the transaction API satisfying those interfaces (such as a
+// memdb.Database) as well as with an fdb.Database, fdb.Transaction or
+// fdb.Snapshot. The variants are package functions and methods of the
+// ReadWriterDirectory interface, which every Directory and DirectorySubspace
+// returned by this package implements.
 package directory
 
 import (
@@ -153,6 +163,35 @@ type Directory interface {
 	GetPath() []string
 }
 
+// ReadWriterDirectory is a Directory whose operations may also be performed
+// with any implementation of the transaction API (such as a memdb.Database),
+// as well as with an fdb.Database, fdb.Transaction or fdb.Snapshot. Every
+// Directory and DirectorySubspace returned by this package (including Root) is
+// a ReadWriterDirectory.
+//
+// Each method behaves like the method of Directory of the same name without
+// the ReadWriter or Reader suffix, but accepts an fdb.ReadWriterTransactor or
+// fdb.ReaderTransactor.
+type ReadWriterDirectory interface {
+	Directory
+
+	CreateOrOpenReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte) (DirectorySubspace, error)
+	OpenReader(rt fdb.ReaderTransactor, path []string, layer []byte) (DirectorySubspace, error)
+	CreateReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte) (DirectorySubspace, error)
+	CreatePrefixReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte, prefix []byte) (DirectorySubspace, error)
+	MoveReadWriter(t fdb.ReadWriterTransactor, oldPath []string, newPath []string) (DirectorySubspace, error)
+	MoveToReadWriter(t fdb.ReadWriterTransactor, newAbsolutePath []string) (DirectorySubspace, error)
+	RemoveReadWriter(t fdb.ReadWriterTransactor, path []string) (bool, error)
+	ExistsReader(rt fdb.ReaderTransactor, path []string) (bool, error)
+	ListReader(rt fdb.ReaderTransactor, path []string) ([]string, error)
+}
+
+var (
+	_ ReadWriterDirectory = directoryLayer{}
+	_ ReadWriterDirectory = directorySubspace{}
+	_ ReadWriterDirectory = directoryPartition{}
+)
+
 func stringsEqual(a, b []string) bool {
 	if len(a) != len(b) {
 		return false
@@ -165,17 +204,66 @@ func stringsEqual(a, b []string) bool {
 	return true
 }
 
-func moveTo(t fdb.Transactor, dl directoryLayer, path, newAbsolutePath []string) (DirectorySubspace, error) {
+func moveTo(t fdb.ReadWriterTransactor, dl directoryLayer, path, newAbsolutePath []string) (DirectorySubspace, error) {
 	partition_len := len(dl.path)
 
 	if !stringsEqual(newAbsolutePath[:partition_len], dl.path) {
 		return nil, errors.New("cannot move between partitions")
 	}
 
-	return dl.Move(t, path[partition_len:], newAbsolutePath[partition_len:])
+	return dl.MoveReadWriter(t, path[partition_len:], newAbsolutePath[partition_len:])
+}
+
+// transactor adapts an fdb.Transactor to the fdb.ReadWriterTransactor
+// interface, in terms of which the directory operations are implemented.
+type transactor struct {
+	fdb.Transactor
+}
+
+func (t transactor) TransactReadWriter(f func(fdb.ReadWriter) (interface{}, error)) (interface{}, error) {
+	return t.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		return f(tr)
+	})
+}
+
+func (t transactor) ReadTransactReader(f func(fdb.Reader) (interface{}, error)) (interface{}, error) {
+	return readTransactor{t.Transactor}.ReadTransactReader(f)
+}
+
+// readTransactor adapts an fdb.ReadTransactor to the fdb.ReaderTransactor
+// interface.
+type readTransactor struct {
+	fdb.ReadTransactor
+}
+
+func (rt readTransactor) ReadTransactReader(f func(fdb.Reader) (interface{}, error)) (interface{}, error) {
+	return rt.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return f(reader(rtr))
+	})
+}
+
+// reader returns rtr as an fdb.Reader. fdb.Transaction and fdb.Snapshot are
+// Readers already; any other fdb.ReadTransaction is adapted.
+func reader(rtr fdb.ReadTransaction) fdb.Reader {
+	if r, ok := rtr.(fdb.Reader); ok {
+		return r
+	}
+	return readTransaction{rtr}
+}
+
+type readTransaction struct {
+	fdb.ReadTransaction
+}
+
+func (rtr readTransaction) SnapshotReader() fdb.Reader {
+	return rtr.Snapshot()
 }
 
-var root = NewDirectoryLayer(subspace.FromBytes([]byte{0xFE}), subspace.AllKeys(), false)
+func (rtr readTransaction) ReadTransactReader(f func(fdb.Reader) (interface{}, error)) (interface{}, error) {
+	return readTransactor{rtr.ReadTransaction}.ReadTransactReader(f)
+}
+
+var root = NewDirectoryLayer(subspace.FromBytes([]byte{0xFE}), subspace.AllKeys(), false).(ReadWriterDirectory)
 
 // CreateOrOpen opens the directory specified by path (resolved relative to the
 // default root directory), and returns the directory and its contents as a
@@ -236,6 +324,38 @@ func List(rt fdb.ReadTransactor, path []string) ([]string, error) {
 	return root.List(rt, path)
 }
 
+// CreateOrOpenReadWriter behaves like CreateOrOpen, but accepts any
+// fdb.ReadWriterTransactor.
+func CreateOrOpenReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return root.CreateOrOpenReadWriter(t, path, layer)
+}
+
+// OpenReader behaves like Open, but accepts any fdb.ReaderTransactor.
+func OpenReader(rt fdb.ReaderTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return root.OpenReader(rt, path, layer)
+}
+
+// CreateReadWriter behaves like Create, but accepts any
+// fdb.ReadWriterTransactor.
+func CreateReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return root.CreateReadWriter(t, path, layer)
+}
+
+// MoveReadWriter behaves like Move, but accepts any fdb.ReadWriterTransactor.
+func MoveReadWriter(t fdb.ReadWriterTransactor, oldPath []string, newPath []string) (DirectorySubspace, error) {
+	return root.MoveReadWriter(t, oldPath, newPath)
+}
+
+// ExistsReader behaves like Exists, but accepts any fdb.ReaderTransactor.
+func ExistsReader(rt fdb.ReaderTransactor, path []string) (bool, error) {
+	return root.ExistsReader(rt, path)
+}
+
+// ListReader behaves like List, but accepts any fdb.ReaderTransactor.
+func ListReader(rt fdb.ReaderTransactor, path []string) ([]string, error) {
+	return root.ListReader(rt, path)
+}
+
 // Root returns the default root directory. Any attempt to move or remove the
 // root directory will return an error.
 //
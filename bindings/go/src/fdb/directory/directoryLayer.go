@@ -71,7 +71,7 @@ func NewDirectoryLayer(nodeSS, contentSS subspace.Subspace, allowManualPrefixes
 	return dl
 }
 
-func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr *fdb.Transaction, path []string, layer []byte, prefix []byte, allowCreate, allowOpen bool) (DirectorySubspace, error) {
+func (dl directoryLayer) createOrOpen(rtr fdb.Reader, tr fdb.ReadWriter, path []string, layer []byte, prefix []byte, allowCreate, allowOpen bool) (DirectorySubspace, error) {
 	if e := dl.checkVersion(rtr, nil); e != nil {
 		return nil, e
 	}
@@ -120,7 +120,7 @@ func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr *fdb.Transacti
 	}
 
 	if prefix == nil {
-		newss, e := dl.allocator.allocate(*tr, dl.contentSS)
+		newss, e := dl.allocator.allocate(tr, dl.contentSS)
 		if e != nil {
 			return nil, fmt.Errorf("unable to allocate new directory prefix (%s)", e.Error())
 		}
@@ -131,7 +131,7 @@ func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr *fdb.Transacti
 
 		prefix = newss.Bytes()
 
-		pf, e := dl.isPrefixFree(rtr.Snapshot(), prefix)
+		pf, e := dl.isPrefixFree(rtr.SnapshotReader(), prefix)
 		if e != nil {
 			return nil, e
 		}
@@ -177,8 +177,12 @@ func (dl directoryLayer) createOrOpen(rtr fdb.ReadTransaction, tr *fdb.Transacti
 }
 
 func (dl directoryLayer) CreateOrOpen(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error) {
-	r, e := t.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		return dl.createOrOpen(tr, &tr, path, layer, nil, true, true)
+	return dl.CreateOrOpenReadWriter(transactor{t}, path, layer)
+}
+
+func (dl directoryLayer) CreateOrOpenReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	r, e := t.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		return dl.createOrOpen(tr, tr, path, layer, nil, true, true)
 	})
 	if e != nil {
 		return nil, e
@@ -187,8 +191,12 @@ func (dl directoryLayer) CreateOrOpen(t fdb.Transactor, path []string, layer []b
 }
 
 func (dl directoryLayer) Create(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error) {
-	r, e := t.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		return dl.createOrOpen(tr, &tr, path, layer, nil, true, false)
+	return dl.CreateReadWriter(transactor{t}, path, layer)
+}
+
+func (dl directoryLayer) CreateReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	r, e := t.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		return dl.createOrOpen(tr, tr, path, layer, nil, true, false)
 	})
 	if e != nil {
 		return nil, e
@@ -197,11 +205,15 @@ func (dl directoryLayer) Create(t fdb.Transactor, path []string, layer []byte) (
 }
 
 func (dl directoryLayer) CreatePrefix(t fdb.Transactor, path []string, layer []byte, prefix []byte) (DirectorySubspace, error) {
+	return dl.CreatePrefixReadWriter(transactor{t}, path, layer, prefix)
+}
+
+func (dl directoryLayer) CreatePrefixReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte, prefix []byte) (DirectorySubspace, error) {
 	if prefix == nil {
 		prefix = []byte{}
 	}
-	r, e := t.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		return dl.createOrOpen(tr, &tr, path, layer, prefix, true, false)
+	r, e := t.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		return dl.createOrOpen(tr, tr, path, layer, prefix, true, false)
 	})
 	if e != nil {
 		return nil, e
@@ -210,7 +222,11 @@ func (dl directoryLayer) CreatePrefix(t fdb.Transactor, path []string, layer []b
 }
 
 func (dl directoryLayer) Open(rt fdb.ReadTransactor, path []string, layer []byte) (DirectorySubspace, error) {
-	r, e := rt.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+	return dl.OpenReader(readTransactor{rt}, path, layer)
+}
+
+func (dl directoryLayer) OpenReader(rt fdb.ReaderTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	r, e := rt.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 		return dl.createOrOpen(rtr, nil, path, layer, nil, false, true)
 	})
 	if e != nil {
@@ -220,7 +236,11 @@ func (dl directoryLayer) Open(rt fdb.ReadTransactor, path []string, layer []byte
 }
 
 func (dl directoryLayer) Exists(rt fdb.ReadTransactor, path []string) (bool, error) {
-	r, e := rt.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+	return dl.ExistsReader(readTransactor{rt}, path)
+}
+
+func (dl directoryLayer) ExistsReader(rt fdb.ReaderTransactor, path []string) (bool, error) {
+	r, e := rt.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 		if e := dl.checkVersion(rtr, nil); e != nil {
 			return false, e
 		}
@@ -235,7 +255,7 @@ func (dl directoryLayer) Exists(rt fdb.ReadTransactor, path []string) (bool, err
 			if e != nil {
 				return false, e
 			}
-			return nc.Exists(rtr, node.getPartitionSubpath())
+			return nc.(directoryPartition).ExistsReader(rtr, node.getPartitionSubpath())
 		}
 
 		return true, nil
@@ -247,7 +267,11 @@ func (dl directoryLayer) Exists(rt fdb.ReadTransactor, path []string) (bool, err
 }
 
 func (dl directoryLayer) List(rt fdb.ReadTransactor, path []string) ([]string, error) {
-	r, e := rt.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+	return dl.ListReader(readTransactor{rt}, path)
+}
+
+func (dl directoryLayer) ListReader(rt fdb.ReaderTransactor, path []string) ([]string, error) {
+	r, e := rt.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 		if e := dl.checkVersion(rtr, nil); e != nil {
 			return nil, e
 		}
@@ -262,7 +286,7 @@ func (dl directoryLayer) List(rt fdb.ReadTransactor, path []string) ([]string, e
 			if e != nil {
 				return nil, e
 			}
-			return nc.List(rtr, node.getPartitionSubpath())
+			return nc.(directoryPartition).ListReader(rtr, node.getPartitionSubpath())
 		}
 
 		return dl.subdirNames(rtr, node.subspace)
@@ -274,12 +298,20 @@ func (dl directoryLayer) List(rt fdb.ReadTransactor, path []string) ([]string, e
 }
 
 func (dl directoryLayer) MoveTo(t fdb.Transactor, newAbsolutePath []string) (DirectorySubspace, error) {
+	return dl.MoveToReadWriter(transactor{t}, newAbsolutePath)
+}
+
+func (dl directoryLayer) MoveToReadWriter(t fdb.ReadWriterTransactor, newAbsolutePath []string) (DirectorySubspace, error) {
 	return nil, errors.New("the root directory cannot be moved")
 }
 
 func (dl directoryLayer) Move(t fdb.Transactor, oldPath []string, newPath []string) (DirectorySubspace, error) {
-	r, e := t.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		if e := dl.checkVersion(tr, &tr); e != nil {
+	return dl.MoveReadWriter(transactor{t}, oldPath, newPath)
+}
+
+func (dl directoryLayer) MoveReadWriter(t fdb.ReadWriterTransactor, oldPath []string, newPath []string) (DirectorySubspace, error) {
+	r, e := t.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		if e := dl.checkVersion(tr, tr); e != nil {
 			return nil, e
 		}
 
@@ -307,7 +339,7 @@ func (dl directoryLayer) Move(t fdb.Transactor, oldPath []string, newPath []stri
 			if e != nil {
 				return nil, e
 			}
-			return nnc.Move(tr, oldNode.getPartitionSubpath(), newNode.getPartitionSubpath())
+			return nnc.(directoryPartition).MoveReadWriter(tr, oldNode.getPartitionSubpath(), newNode.getPartitionSubpath())
 		}
 
 		if newNode.exists() {
@@ -340,8 +372,12 @@ func (dl directoryLayer) Move(t fdb.Transactor, oldPath []string, newPath []stri
 }
 
 func (dl directoryLayer) Remove(t fdb.Transactor, path []string) (bool, error) {
-	r, e := t.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		if e := dl.checkVersion(tr, &tr); e != nil {
+	return dl.RemoveReadWriter(transactor{t}, path)
+}
+
+func (dl directoryLayer) RemoveReadWriter(t fdb.ReadWriterTransactor, path []string) (bool, error) {
+	r, e := t.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		if e := dl.checkVersion(tr, tr); e != nil {
 			return false, e
 		}
 
@@ -360,7 +396,7 @@ func (dl directoryLayer) Remove(t fdb.Transactor, path []string) (bool, error) {
 			if e != nil {
 				return false, e
 			}
-			return nc.(directoryPartition).Remove(tr, node.getPartitionSubpath())
+			return nc.(directoryPartition).RemoveReadWriter(tr, node.getPartitionSubpath())
 		}
 
 		if e := dl.removeRecursive(tr, node.subspace); e != nil {
@@ -376,7 +412,7 @@ func (dl directoryLayer) Remove(t fdb.Transactor, path []string) (bool, error) {
 	return r.(bool), nil
 }
 
-func (dl directoryLayer) removeRecursive(tr fdb.Transaction, node subspace.Subspace) error {
+func (dl directoryLayer) removeRecursive(tr fdb.ReadWriter, node subspace.Subspace) error {
 	nodes := dl.subdirNodes(tr, node)
 	for i := range nodes {
 		if e := dl.removeRecursive(tr, nodes[i]); e != nil {
@@ -399,7 +435,7 @@ func (dl directoryLayer) removeRecursive(tr fdb.Transaction, node subspace.Subsp
 	return nil
 }
 
-func (dl directoryLayer) removeFromParent(tr fdb.Transaction, path []string) {
+func (dl directoryLayer) removeFromParent(tr fdb.ReadWriter, path []string) {
 	parent := dl.find(tr, path[:len(path)-1])
 	tr.Clear(parent.subspace.Sub(_SUBDIRS, path[len(path)-1]))
 }
@@ -412,7 +448,7 @@ func (dl directoryLayer) GetPath() []string {
 	return dl.path
 }
 
-func (dl directoryLayer) subdirNames(rtr fdb.ReadTransaction, node subspace.Subspace) ([]string, error) {
+func (dl directoryLayer) subdirNames(rtr fdb.Reader, node subspace.Subspace) ([]string, error) {
 	sd := node.Sub(_SUBDIRS)
 
 	rr := rtr.GetRange(sd, fdb.RangeOptions{})
@@ -437,7 +473,7 @@ func (dl directoryLayer) subdirNames(rtr fdb.ReadTransaction, node subspace.Subs
 	return ret, nil
 }
 
-func (dl directoryLayer) subdirNodes(tr fdb.Transaction, node subspace.Subspace) []subspace.Subspace {
+func (dl directoryLayer) subdirNodes(tr fdb.ReadWriter, node subspace.Subspace) []subspace.Subspace {
 	sd := node.Sub(_SUBDIRS)
 
 	rr := tr.GetRange(sd, fdb.RangeOptions{})
@@ -454,7 +490,7 @@ func (dl directoryLayer) subdirNodes(tr fdb.Transaction, node subspace.Subspace)
 	return ret
 }
 
-func (dl directoryLayer) nodeContainingKey(rtr fdb.ReadTransaction, key []byte) (subspace.Subspace, error) {
+func (dl directoryLayer) nodeContainingKey(rtr fdb.Reader, key []byte) (subspace.Subspace, error) {
 	if bytes.HasPrefix(key, dl.nodeSS.Bytes()) {
 		return dl.rootNode, nil
 	}
@@ -480,7 +516,7 @@ func (dl directoryLayer) nodeContainingKey(rtr fdb.ReadTransaction, key []byte)
 	return nil, nil
 }
 
-func (dl directoryLayer) isPrefixFree(rtr fdb.ReadTransaction, prefix []byte) (bool, error) {
+func (dl directoryLayer) isPrefixFree(rtr fdb.Reader, prefix []byte) (bool, error) {
 	if len(prefix) == 0 {
 		return false, nil
 	}
@@ -506,7 +542,7 @@ func (dl directoryLayer) isPrefixFree(rtr fdb.ReadTransaction, prefix []byte) (b
 	return true, nil
 }
 
-func (dl directoryLayer) checkVersion(rtr fdb.ReadTransaction, tr *fdb.Transaction) error {
+func (dl directoryLayer) checkVersion(rtr fdb.Reader, tr fdb.ReadWriter) error {
 	version, err := rtr.Get(dl.rootNode.Sub([]byte("version"))).Get()
 	if err != nil {
 		return err
@@ -514,7 +550,7 @@ func (dl directoryLayer) checkVersion(rtr fdb.ReadTransaction, tr *fdb.Transacti
 
 	if version == nil {
 		if tr != nil {
-			dl.initializeDirectory(*tr)
+			dl.initializeDirectory(tr)
 		}
 		return nil
 	}
@@ -542,7 +578,7 @@ func (dl directoryLayer) checkVersion(rtr fdb.ReadTransaction, tr *fdb.Transacti
 	return nil
 }
 
-func (dl directoryLayer) initializeDirectory(tr fdb.Transaction) {
+func (dl directoryLayer) initializeDirectory(tr fdb.ReadWriter) {
 	buf := new(bytes.Buffer)
 
 	// bytes.Buffer claims that Write will always return a nil error, which
@@ -588,7 +624,7 @@ func (dl directoryLayer) nodeWithPrefix(prefix []byte) subspace.Subspace {
 	return dl.nodeSS.Sub(prefix)
 }
 
-func (dl directoryLayer) find(rtr fdb.ReadTransaction, path []string) *node {
+func (dl directoryLayer) find(rtr fdb.Reader, path []string) *node {
 	n := &node{dl.rootNode, []string{}, path, nil}
 	for i := range path {
 		n = &node{dl.nodeWithPrefix(rtr.Get(n.subspace.Sub(_SUBDIRS, path[i])).MustGet()), path[:i+1], path, nil}
@@ -606,7 +642,7 @@ func (dl directoryLayer) partitionSubpath(lpath, rpath []string) []string {
 	return r
 }
 
-func isRangeEmpty(rtr fdb.ReadTransaction, r fdb.Range) bool {
+func isRangeEmpty(rtr fdb.Reader, r fdb.Range) bool {
 	kvs := rtr.GetRange(r, fdb.RangeOptions{Limit: 1}).GetSliceOrPanic()
 
 	return len(kvs) == 0
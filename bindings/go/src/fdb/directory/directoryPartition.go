@@ -77,15 +77,27 @@ func (dp directoryPartition) getLayerForPath(path []string) directoryLayer {
 }
 
 func (dp directoryPartition) MoveTo(t fdb.Transactor, newAbsolutePath []string) (DirectorySubspace, error) {
+	return dp.MoveToReadWriter(transactor{t}, newAbsolutePath)
+}
+
+func (dp directoryPartition) MoveToReadWriter(t fdb.ReadWriterTransactor, newAbsolutePath []string) (DirectorySubspace, error) {
 	return moveTo(t, dp.parentDirectoryLayer, dp.path, newAbsolutePath)
 }
 
 func (dp directoryPartition) Remove(t fdb.Transactor, path []string) (bool, error) {
+	return dp.RemoveReadWriter(transactor{t}, path)
+}
+
+func (dp directoryPartition) RemoveReadWriter(t fdb.ReadWriterTransactor, path []string) (bool, error) {
 	dl := dp.getLayerForPath(path)
-	return dl.Remove(t, dl.partitionSubpath(dp.path, path))
+	return dl.RemoveReadWriter(t, dl.partitionSubpath(dp.path, path))
 }
 
 func (dp directoryPartition) Exists(rt fdb.ReadTransactor, path []string) (bool, error) {
+	return dp.ExistsReader(readTransactor{rt}, path)
+}
+
+func (dp directoryPartition) ExistsReader(rt fdb.ReaderTransactor, path []string) (bool, error) {
 	dl := dp.getLayerForPath(path)
-	return dl.Exists(rt, dl.partitionSubpath(dp.path, path))
+	return dl.ExistsReader(rt, dl.partitionSubpath(dp.path, path))
 }
@@ -44,39 +44,75 @@ type directorySubspace struct {
 }
 
 func (d directorySubspace) CreateOrOpen(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error) {
-	return d.dl.CreateOrOpen(t, d.dl.partitionSubpath(d.path, path), layer)
+	return d.CreateOrOpenReadWriter(transactor{t}, path, layer)
+}
+
+func (d directorySubspace) CreateOrOpenReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return d.dl.CreateOrOpenReadWriter(t, d.dl.partitionSubpath(d.path, path), layer)
 }
 
 func (d directorySubspace) Create(t fdb.Transactor, path []string, layer []byte) (DirectorySubspace, error) {
-	return d.dl.Create(t, d.dl.partitionSubpath(d.path, path), layer)
+	return d.CreateReadWriter(transactor{t}, path, layer)
+}
+
+func (d directorySubspace) CreateReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return d.dl.CreateReadWriter(t, d.dl.partitionSubpath(d.path, path), layer)
 }
 
 func (d directorySubspace) CreatePrefix(t fdb.Transactor, path []string, layer []byte, prefix []byte) (DirectorySubspace, error) {
-	return d.dl.CreatePrefix(t, d.dl.partitionSubpath(d.path, path), layer, prefix)
+	return d.CreatePrefixReadWriter(transactor{t}, path, layer, prefix)
+}
+
+func (d directorySubspace) CreatePrefixReadWriter(t fdb.ReadWriterTransactor, path []string, layer []byte, prefix []byte) (DirectorySubspace, error) {
+	return d.dl.CreatePrefixReadWriter(t, d.dl.partitionSubpath(d.path, path), layer, prefix)
 }
 
 func (d directorySubspace) Open(rt fdb.ReadTransactor, path []string, layer []byte) (DirectorySubspace, error) {
-	return d.dl.Open(rt, d.dl.partitionSubpath(d.path, path), layer)
+	return d.OpenReader(readTransactor{rt}, path, layer)
+}
+
+func (d directorySubspace) OpenReader(rt fdb.ReaderTransactor, path []string, layer []byte) (DirectorySubspace, error) {
+	return d.dl.OpenReader(rt, d.dl.partitionSubpath(d.path, path), layer)
 }
 
 func (d directorySubspace) MoveTo(t fdb.Transactor, newAbsolutePath []string) (DirectorySubspace, error) {
+	return d.MoveToReadWriter(transactor{t}, newAbsolutePath)
+}
+
+func (d directorySubspace) MoveToReadWriter(t fdb.ReadWriterTransactor, newAbsolutePath []string) (DirectorySubspace, error) {
 	return moveTo(t, d.dl, d.path, newAbsolutePath)
 }
 
 func (d directorySubspace) Move(t fdb.Transactor, oldPath []string, newPath []string) (DirectorySubspace, error) {
-	return d.dl.Move(t, d.dl.partitionSubpath(d.path, oldPath), d.dl.partitionSubpath(d.path, newPath))
+	return d.MoveReadWriter(transactor{t}, oldPath, newPath)
+}
+
+func (d directorySubspace) MoveReadWriter(t fdb.ReadWriterTransactor, oldPath []string, newPath []string) (DirectorySubspace, error) {
+	return d.dl.MoveReadWriter(t, d.dl.partitionSubpath(d.path, oldPath), d.dl.partitionSubpath(d.path, newPath))
 }
 
 func (d directorySubspace) Remove(t fdb.Transactor, path []string) (bool, error) {
-	return d.dl.Remove(t, d.dl.partitionSubpath(d.path, path))
+	return d.RemoveReadWriter(transactor{t}, path)
+}
+
+func (d directorySubspace) RemoveReadWriter(t fdb.ReadWriterTransactor, path []string) (bool, error) {
+	return d.dl.RemoveReadWriter(t, d.dl.partitionSubpath(d.path, path))
 }
 
 func (d directorySubspace) Exists(rt fdb.ReadTransactor, path []string) (bool, error) {
-	return d.dl.Exists(rt, d.dl.partitionSubpath(d.path, path))
+	return d.ExistsReader(readTransactor{rt}, path)
+}
+
+func (d directorySubspace) ExistsReader(rt fdb.ReaderTransactor, path []string) (bool, error) {
+	return d.dl.ExistsReader(rt, d.dl.partitionSubpath(d.path, path))
 }
 
 func (d directorySubspace) List(rt fdb.ReadTransactor, path []string) (subdirs []string, e error) {
-	return d.dl.List(rt, d.dl.partitionSubpath(d.path, path))
+	return d.ListReader(readTransactor{rt}, path)
+}
+
+func (d directorySubspace) ListReader(rt fdb.ReaderTransactor, path []string) (subdirs []string, e error) {
+	return d.dl.ListReader(rt, d.dl.partitionSubpath(d.path, path))
 }
 
 func (d directorySubspace) GetLayer() []byte {
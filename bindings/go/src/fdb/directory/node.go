@@ -42,14 +42,14 @@ func (n *node) exists() bool {
 	return true
 }
 
-func (n *node) prefetchMetadata(rtr fdb.ReadTransaction) *node {
+func (n *node) prefetchMetadata(rtr fdb.Reader) *node {
 	if n.exists() {
 		n.layer(rtr)
 	}
 	return n
 }
 
-func (n *node) layer(rtr fdb.ReadTransaction) fdb.FutureByteSlice {
+func (n *node) layer(rtr fdb.Reader) fdb.FutureByteSlice {
 	if n._layer == nil {
 		fv := rtr.Get(n.subspace.Sub([]byte("layer")))
 		n._layer = fv
@@ -58,7 +58,7 @@ func (n *node) layer(rtr fdb.ReadTransaction) fdb.FutureByteSlice {
 	return n._layer
 }
 
-func (n *node) isInPartition(tr *fdb.Transaction, includeEmptySubpath bool) bool {
+func (n *node) isInPartition(tr fdb.ReadWriter, includeEmptySubpath bool) bool {
 	return n.exists() && bytes.Compare(n._layer.MustGet(), []byte("partition")) == 0 && (includeEmptySubpath || len(n.targetPath) > len(n.path))
 }
 
@@ -66,7 +66,7 @@ func (n *node) getPartitionSubpath() []string {
 	return n.targetPath[len(n.path):]
 }
 
-func (n *node) getContents(dl directoryLayer, tr *fdb.Transaction) (DirectorySubspace, error) {
+func (n *node) getContents(dl directoryLayer, tr fdb.ReadWriter) (DirectorySubspace, error) {
 	l, err := n._layer.Get()
 	if err != nil {
 		return nil, err
@@ -79,6 +79,29 @@ and have multiple Future objects outstanding inside a single goroutine. All
 operations will execute in parallel, and the calling goroutine will not block
 until a blocking method on any one of the Futures is called.
 
+Every Future also provides a Ready method, returning a channel that is closed
+once the Future is ready. This allows a goroutine to wait on Futures in a select
+statement alongside other channels (such as timers), or to wait on several
+Futures at once with the WaitAny and WaitAll functions:
+
+    watch := tr.Watch(fdb.Key("foo"))
+    // (commit the transaction, then)
+    select {
+    case <-watch.Ready():
+        // foo changed (or the watch failed, see watch.Get())
+    case <-time.After(time.Minute):
+        watch.Cancel()
+    }
+
+The value-returning Futures also satisfy the generic TypedFuture interface, so
+they may be transformed with Map, FlatMap and Then without blocking:
+
+    length := fdb.Map(tr.Get(fdb.Key("foo")), func(v []byte) (int, error) {
+        return len(v), nil
+    })
+    // (issue other reads, then)
+    n, e := length.Get()
+
 On Panics
 
 Idiomatic Go code strongly frowns at panics that escape library/package
@@ -170,6 +193,27 @@ Given these complexities, it is generally best practice to use a single
 goroutine for each logical thread of interaction with FoundationDB, and allow
 each goroutine to block when necessary to wait for Futures to become ready.
 
+Pluggable Transactions
+
+Transaction, Snapshot and Database are concrete types backed by the
+FoundationDB C library. Code that should also run against other
+implementations of the transaction API (in-memory fakes such as the memdb
+package, or wrappers which record or trace operations) can instead be written
+against the Reader and ReadWriter interfaces, and accept a ReaderTransactor or
+ReadWriterTransactor in place of a ReadTransactor or Transactor:
+
+    func incr(t fdb.ReadWriterTransactor, key fdb.Key) error {
+        _, e := t.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+            tr.Add(key, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+            return nil, nil
+        })
+        return e
+    }
+
+Such functions may be called with a Database or Transaction exactly as before.
+The directory package provides entry points of this kind (such as
+directory.CreateOrOpenReadWriter) beside its Transactor-based functions.
+
 Streaming Modes
 
 When using GetRange methods in the FoundationDB API, clients can request large
@@ -27,6 +27,7 @@ package fdb
 import "C"
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -38,6 +39,19 @@ import (
 // error codes at https://apple.github.io/foundationdb/api-error-codes.html,
 // but generally an Error should be passed to (Transaction).OnError. When using
 // (Database).Transact, non-fatal errors will be retried automatically.
+//
+// Error values are comparable, so errors.Is may be used to test an error
+// (including one wrapped with fmt.Errorf and %w) against the sentinel values
+// below, and errors.As may be used to extract an Error:
+//
+//	if errors.Is(e, fdb.ErrTransactionTooOld) {
+//	    // start over with a new transaction
+//	}
+//
+//	var fe fdb.Error
+//	if errors.As(e, &fe) && fe.IsRetryable() {
+//	    // ...
+//	}
 type Error struct {
 	Code int
 }
@@ -46,6 +60,96 @@ func (e Error) Error() string {
 	return fmt.Sprintf("FoundationDB error code %d (%s)", e.Code, C.GoString(C.fdb_get_error(C.fdb_error_t(e.Code))))
 }
 
+func (e Error) predicate(p ErrorPredicate) bool {
+	return C.fdb_error_predicate(C.int(p), C.fdb_error_t(e.Code)) != 0
+}
+
+// IsRetryable returns true if the error indicates that the operation may be
+// retried (for example, after passing the error to (Transaction).OnError).
+func (e Error) IsRetryable() bool {
+	return e.predicate(ErrorPredicateRetryable)
+}
+
+// MaybeCommitted returns true if the error indicates that the transaction may
+// have been committed, though in a way that cannot be verified.
+func (e Error) MaybeCommitted() bool {
+	return e.predicate(ErrorPredicateMaybeCommitted)
+}
+
+// IsRetryableNotCommitted returns true if the error indicates that the
+// transaction was certainly not committed and may be retried.
+func (e Error) IsRetryableNotCommitted() bool {
+	return e.predicate(ErrorPredicateRetryableNotCommitted)
+}
+
+// Errors commonly encountered by FoundationDB client programs. See
+// https://apple.github.io/foundationdb/api-error-codes.html for the full list.
+var (
+	// ErrTransactionTooOld (transaction_too_old) is returned when a
+	// transaction has been open for longer than five seconds.
+	ErrTransactionTooOld = Error{1007}
+
+	// ErrFutureVersion (future_version) is returned when a read version is
+	// newer than any version known to the storage servers.
+	ErrFutureVersion = Error{1009}
+
+	// ErrNotCommitted (not_committed) is returned when a transaction conflicts
+	// with another transaction.
+	ErrNotCommitted = Error{1020}
+
+	// ErrCommitUnknownResult (commit_unknown_result) is returned when it
+	// cannot be determined whether a transaction was committed.
+	ErrCommitUnknownResult = Error{1021}
+
+	// ErrTransactionCancelled (transaction_cancelled) is returned when an
+	// operation is aborted because its transaction was cancelled.
+	ErrTransactionCancelled = Error{1025}
+
+	// ErrTransactionTimedOut (transaction_timed_out) is returned when an
+	// operation is aborted because its transaction timed out.
+	ErrTransactionTimedOut = Error{1031}
+
+	// ErrProcessBehind (process_behind) is returned when a storage server
+	// does not have recent mutations.
+	ErrProcessBehind = Error{1037}
+
+	// ErrDatabaseLocked (database_locked) is returned when the database is
+	// locked and the transaction is not lock aware.
+	ErrDatabaseLocked = Error{1038}
+
+	// ErrOperationCancelled (operation_cancelled) is returned when an
+	// asynchronous operation (such as a Future) has been cancelled.
+	ErrOperationCancelled = Error{1101}
+
+	// ErrUsedDuringCommit (used_during_commit) is returned when a transaction
+	// is used while its commit is outstanding.
+	ErrUsedDuringCommit = Error{2017}
+
+	// ErrTransactionTooLarge (transaction_too_large) is returned when a
+	// transaction exceeds the byte limit.
+	ErrTransactionTooLarge = Error{2101}
+
+	// ErrKeyTooLarge (key_too_large) is returned when a key exceeds the key
+	// length limit.
+	ErrKeyTooLarge = Error{2102}
+
+	// ErrValueTooLarge (value_too_large) is returned when a value exceeds the
+	// value length limit.
+	ErrValueTooLarge = Error{2103}
+)
+
+var (
+	// ErrNetworkStopped is returned when opening a database or running a
+	// transactional function after StopNetwork has been called, and by the
+	// futures which were not ready when the network stopped. The FoundationDB
+	// client network cannot be restarted once stopped.
+	ErrNetworkStopped = errors.New("the FoundationDB client network has been stopped")
+
+	// ErrDatabaseClosed is returned when creating a transaction on (or setting
+	// an option of) a Database after (Database).Close has been called.
+	ErrDatabaseClosed = errors.New("the database has been closed")
+)
+
 // SOMEDAY: these (along with others) should be coming from fdb.options?
 
 var (
@@ -0,0 +1,75 @@
+/*
+ * export_test.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import "context"
+
+// Parts of the retry loop of Database are exported here so that they can be
+// tested by package fdb_test against an in-memory memdb database (which
+// imports this package, and so cannot be used by its internal tests).
+
+type IdempotentCommit = idempotentCommit
+
+func NewIdempotentCommit(key Key) *IdempotentCommit {
+	return &idempotentCommit{key: key}
+}
+
+func (c *idempotentCommit) Committed(tr ReadWriter) (interface{}, bool) {
+	return c.committed(tr)
+}
+
+func (c *idempotentCommit) Prepare(tr ReadWriter, ret interface{}) {
+	c.prepare(tr, ret)
+}
+
+func (c *idempotentCommit) Observe(maybeCommitted bool) {
+	c.observe(maybeCommitted)
+}
+
+var ContextError = contextError
+
+func Retryable(ctx context.Context, wrapped func() (interface{}, error), onError func(Error) FutureNil, policy RetryPolicy) (interface{}, error) {
+	return retryable(ctx, wrapped, onError, policy, nil)
+}
+
+var BeginTransact = beginTransact
+
+// NewClosedDatabase returns a Database in the state left by (Database).Close,
+// but still cached by OpenDatabase for clusterFile, without the C library.
+func NewClosedDatabase(clusterFile string) Database {
+	d := Database{database: &database{}}
+
+	networkMutex.Lock()
+	defer networkMutex.Unlock()
+
+	openDatabases[clusterFile] = d
+	return d
+}
+
+func IsDatabaseCached(clusterFile string) bool {
+	networkMutex.Lock()
+	defer networkMutex.Unlock()
+
+	_, ok := openDatabases[clusterFile]
+	return ok
+}
@@ -30,21 +30,30 @@ import "C"
 import (
 	"bytes"
 	"fmt"
-	"log"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
 // Would put this in futures.go but for the documented issue with
 // exports and functions in preamble
 // (https://code.google.com/p/go-wiki/wiki/cgo#Global_functions)
-//export unlockMutex
-func unlockMutex(p unsafe.Pointer) {
-	m := (*sync.Mutex)(p)
-	m.Unlock()
+//export futureReady
+func futureReady(id C.uintptr_t) {
+	if s, ok := readyStates.LoadAndDelete(uintptr(id)); ok {
+		s.(*readyState).fire()
+	}
 }
 
+// readyStates holds the ready states of futures awaiting their callbacks, by
+// the ID passed to the callback. An ID is released by whichever of the
+// callback and the destruction of its future comes first.
+var (
+	readyStates sync.Map
+	nextReadyID atomic.Uintptr
+)
+
 // A Transactor can execute a function that requires a Transaction. Functions
 // written to accept a Transactor are called transactional functions, and may be
 // called with either a Database or a Transaction.
@@ -70,6 +79,45 @@ type ReadTransactor interface {
 	ReadTransact(func(ReadTransaction) (interface{}, error)) (interface{}, error)
 }
 
+// Transact calls t.Transact with f, returning the value returned by t.Transact
+// (that is, produced by f) as a T rather than as an interface{}. Retry, commit
+// and panic handling are exactly those of t.Transact, so Transact may be called
+// with a Database or a Transaction. In particular, with a Database using
+// WithIdempotency, the value is that of the attempt which committed.
+//
+// If t.Transact returns an error, Transact returns the zero value of T along
+// with that error.
+func Transact[T any](t Transactor, f func(Transaction) (T, error)) (T, error) {
+	r, e := t.Transact(func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	})
+	if e != nil {
+		var zero T
+		return zero, e
+	}
+	v, _ := r.(T)
+	return v, nil
+}
+
+// ReadTransact calls t.ReadTransact with f, returning the value produced by f
+// as a T rather than as an interface{}. Retry and panic handling are exactly
+// those of t.ReadTransact, so ReadTransact may be called with a Database,
+// Transaction or Snapshot.
+//
+// If t.ReadTransact returns an error, ReadTransact returns the zero value of T
+// along with that error.
+func ReadTransact[T any](t ReadTransactor, f func(ReadTransaction) (T, error)) (T, error) {
+	r, e := t.ReadTransact(func(rtr ReadTransaction) (interface{}, error) {
+		return f(rtr)
+	})
+	if e != nil {
+		var zero T
+		return zero, e
+	}
+	v, _ := r.(T)
+	return v, nil
+}
+
 func setOpt(setter func(*C.uint8_t, C.int) C.fdb_error_t, param []byte) error {
 	if err := setter(byteSliceToPtr(param), C.int(len(param))); err != 0 {
 		return Error{int(err)}
@@ -98,9 +146,13 @@ func (opt NetworkOptions) setOpt(code int, param []byte) error {
 		return errAPIVersionUnset
 	}
 
-	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
+	e := setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
 		return C.fdb_network_set_option(C.FDBNetworkOption(code), p, pl)
 	}, param)
+	if e != nil {
+		logOptionError(logger(), "network", code, e)
+	}
+	return e
 }
 
 // APIVersion determines the runtime behavior the fdb package. If the requested
@@ -200,20 +252,112 @@ func init() {
 	openDatabases = make(map[string]Database)
 }
 
+// networkDone is closed when the network goroutine started by startNetwork
+// returns. networkStopped records that StopNetwork has been called.
+var networkDone chan struct{}
+var networkStopped bool
+
+// networkHalted is set once the network has stopped, after which no future
+// which is not yet ready will become ready.
+var networkHalted atomic.Bool
+
+// transactMutex and transactWait track the transactional functions running in
+// (Database).transact, so that StopNetwork can wait for them to return before
+// stopping the network. Once transactStopping is set, no new transactional
+// functions are started.
+var transactMutex sync.RWMutex
+var transactWait sync.WaitGroup
+var transactStopping bool
+
 func startNetwork() error {
+	if networkStopped {
+		return ErrNetworkStopped
+	}
+
 	if e := C.fdb_setup_network(); e != 0 {
 		return Error{int(e)}
 	}
 
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
+
 		e := C.fdb_run_network()
 		if e != 0 {
-			log.Printf("Unhandled error in FoundationDB network thread: %v (%v)\n", C.GoString(C.fdb_get_error(e)), e)
+			logger().Error("fdb network thread failed", "code", int(e), "error", C.GoString(C.fdb_get_error(e)))
 		}
 	}()
 
+	networkDone = done
 	networkStarted = true
 
+	logger().Debug("fdb network started", "api_version", apiVersion)
+
+	return nil
+}
+
+// beginTransact registers a transactional function with StopNetwork, returning
+// a function to be called when it has returned, or ErrNetworkStopped if
+// StopNetwork has already been called.
+func beginTransact() (func(), error) {
+	transactMutex.RLock()
+	defer transactMutex.RUnlock()
+
+	if transactStopping {
+		return nil, ErrNetworkStopped
+	}
+
+	transactWait.Add(1)
+	return transactWait.Done, nil
+}
+
+// StopNetwork shuts down the FoundationDB client networking engine, allowing a
+// process to exit cleanly. It first waits for the transactional functions
+// already running in (Database).Transact and related methods to return (new
+// ones return ErrNetworkStopped immediately), then stops the network and waits
+// for the goroutine running it to exit.
+//
+// Futures which are not ready when the network stops can never receive their
+// values, so they become ready with ErrNetworkStopped, which their Get methods
+// return. StopNetwork should therefore only be called once all other use of
+// the fdb package has finished. It must not be called from within a
+// transactional function, which would never return.
+//
+// The network cannot be restarted: once StopNetwork has been called,
+// OpenDatabase and the transactional methods of Database return
+// ErrNetworkStopped. If the network has not been started, StopNetwork returns
+// an error.
+func StopNetwork() error {
+	networkMutex.Lock()
+	if !networkStarted {
+		networkMutex.Unlock()
+		if networkStopped {
+			return ErrNetworkStopped
+		}
+		return errNetworkNotSetup
+	}
+	networkStarted = false
+	networkStopped = true
+	done := networkDone
+	networkMutex.Unlock()
+
+	transactMutex.Lock()
+	transactStopping = true
+	transactMutex.Unlock()
+
+	transactWait.Wait()
+
+	if e := C.fdb_stop_network(); e != 0 {
+		return Error{int(e)}
+	}
+
+	<-done
+
+	networkHalted.Store(true)
+	failPendingFutures()
+
+	logger().Debug("fdb network stopped")
+
 	return nil
 }
 
@@ -334,10 +478,12 @@ func createDatabase(clusterFile string) (Database, error) {
 		return Database{}, Error{int(err)}
 	}
 
-	db := &database{outdb}
-	runtime.SetFinalizer(db, (*database).destroy)
+	db := &database{ptr: outdb}
+	runtime.SetFinalizer(db, (*database).finalize)
+
+	logger().Debug("fdb database opened", "cluster_file", clusterFile)
 
-	return Database{db}, nil
+	return Database{database: db}, nil
 }
 
 // Deprecated: Use OpenDatabase instead.
@@ -23,10 +23,23 @@
 package fdb_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/memdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 )
 
 func ExampleOpenDefault() {
@@ -188,6 +201,160 @@ func ExampleReadTransactor() {
 	// getOne called with: fdb.Snapshot
 }
 
+func ExampleDatabase_WithRetryPolicy() {
+	fdb.MustAPIVersion(400)
+	db := fdb.MustOpenDefault()
+
+	// Log every failed attempt, give up immediately on conflicts, and wait an
+	// extra 10ms (on top of the standard backoff) before each retry.
+	policy := fdb.RetryPolicyFunc(func(e fdb.Error, attempt int) (time.Duration, bool) {
+		fmt.Printf("attempt %d failed with error %d\n", attempt, e.Code)
+		if e == fdb.ErrNotCommitted {
+			return 0, false
+		}
+		return 10 * time.Millisecond, true
+	})
+
+	_, e := db.WithRetryPolicy(policy).Transact(func(tr fdb.Transaction) (interface{}, error) {
+		// We don't actually call tr.Set here to avoid mutating a real database.
+		// tr.Set(fdb.Key("foo"), []byte("bar"))
+		return nil, nil
+	})
+	if e != nil {
+		fmt.Println(e)
+	}
+}
+
+func ExampleDatabase_WithMetrics() {
+	fdb.MustAPIVersion(400)
+	db := fdb.MustOpenDefault()
+
+	// Report the attempts and commit latency of every transaction run by the
+	// "checkout" handle. The metrics package provides Metrics which can be
+	// served to Prometheus.
+	metrics := fdb.MetricsFunc(func(stats fdb.TransactionStats) {
+		for _, a := range stats.Attempts {
+			fmt.Printf("%s: commit took %v (error %v)\n", stats.Name, a.CommitLatency, a.Err)
+		}
+	})
+	checkout := db.WithName("checkout").WithMetrics(metrics)
+
+	_, e := checkout.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		// We don't actually call tr.Set here to avoid mutating a real database.
+		// tr.Set(fdb.Key("foo"), []byte("bar"))
+		return nil, nil
+	})
+	if e != nil {
+		fmt.Println(e)
+	}
+}
+
+// printTracer is a Tracer which prints its spans as they end.
+type printTracer struct{}
+
+type printSpan struct {
+	name  string
+	attrs []fdb.Attribute
+}
+
+func (printTracer) Start(ctx context.Context, name string) (context.Context, fdb.Span) {
+	return ctx, &printSpan{name: name}
+}
+
+func (s *printSpan) SetAttributes(attrs ...fdb.Attribute) { s.attrs = append(s.attrs, attrs...) }
+func (s *printSpan) RecordError(e error)                  {}
+func (s *printSpan) End()                                 { fmt.Println(s.name, s.attrs) }
+func (s *printSpan) TraceID() string                      { return "" }
+
+func ExampleDatabase_WithTracer() {
+	fdb.MustAPIVersion(400)
+	db := fdb.MustOpenDefault().WithName("example").WithTracer(printTracer{})
+
+	_, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		rtr.Get(fdb.Key("foo")).MustGet()
+		return rtr.GetRange(fdb.KeyRange{Begin: fdb.Key("a"), End: fdb.Key("b")}, fdb.RangeOptions{}).GetSliceWithError()
+	})
+	if e != nil {
+		fmt.Println(e)
+	}
+}
+
+func ExampleDatabase_WithLogger() {
+	fdb.MustAPIVersion(400)
+
+	// Report network and database events as JSON
+	fdb.SetLogger(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+
+	// Log the retries of the transactions of this handle, and those which
+	// take longer than a second or write more than 1MB
+	db := fdb.MustOpenDefault().
+		WithName("example").
+		WithLogger(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))).
+		WithSlowTransactionLog(time.Second, 1<<20)
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		// We don't actually call tr.Set here to avoid mutating a real database.
+		// tr.Set(fdb.Key("foo"), []byte("bar"))
+		return nil, nil
+	})
+	if e != nil {
+		fmt.Println(e)
+	}
+}
+
+func ExampleStopNetwork() {
+	fdb.MustAPIVersion(400)
+	db := fdb.MustOpenDefault()
+
+	// Release the database handle and stop the network when the program is
+	// done with FoundationDB, in the reverse order of opening them.
+	defer func() {
+		if e := fdb.StopNetwork(); e != nil {
+			fmt.Printf("Unable to stop the network (%v)\n", e)
+		}
+	}()
+	defer db.Close()
+
+	_, e := db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.Get(fdb.Key("hello")).Get()
+	})
+	if e != nil {
+		fmt.Printf("Unable to read FDB database value (%v)\n", e)
+	}
+}
+
+func ExampleReadTransact() {
+	fdb.MustAPIVersion(400)
+	db := fdb.MustOpenDefault()
+
+	// Unlike ReadTransactor.ReadTransact, the generic helper returns a []byte
+	// directly, so no type assertion is needed.
+	getOne := func(rt fdb.ReadTransactor, key fdb.Key) ([]byte, error) {
+		return fdb.ReadTransact(rt, func(rtr fdb.ReadTransaction) ([]byte, error) {
+			return rtr.Get(key).MustGet(), nil
+		})
+	}
+
+	getTwo := func(rt fdb.ReadTransactor, key1, key2 fdb.Key) ([][]byte, error) {
+		return fdb.ReadTransact(rt, func(rtr fdb.ReadTransaction) ([][]byte, error) {
+			r1, _ := getOne(rtr, key1)
+			r2, _ := getOne(rtr.Snapshot(), key2)
+			return [][]byte{r1, r2}, nil
+		})
+	}
+
+	values, e := getTwo(db, fdb.Key("foo"), fdb.Key("bar"))
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+
+	fmt.Println(len(values))
+
+	// Output:
+	// 2
+}
+
 func ExamplePrefixRange() {
 	fdb.MustAPIVersion(400)
 	db := fdb.MustOpenDefault()
@@ -280,7 +447,1122 @@ func TestKeyToString(t *testing.T) {
 	}
 }
 
+func TestErrorSentinels(t *testing.T) {
+	wrapped := fmt.Errorf("reading config: %w", fdb.Error{Code: 1020})
+
+	if !errors.Is(wrapped, fdb.ErrNotCommitted) {
+		t.Errorf("errors.Is(%v, ErrNotCommitted) = false, want true", wrapped)
+	}
+	if errors.Is(wrapped, fdb.ErrTransactionTooOld) {
+		t.Errorf("errors.Is(%v, ErrTransactionTooOld) = true, want false", wrapped)
+	}
+
+	var fe fdb.Error
+	if !errors.As(wrapped, &fe) || fe.Code != 1020 {
+		t.Errorf("errors.As(%v) = %v, want code 1020", wrapped, fe)
+	}
+}
+
 func ExamplePrintable() {
 	fmt.Println(fdb.Printable([]byte{0, 1, 2, 'a', 'b', 'c', '1', '2', '3', '!', '?', 255}))
 	// Output: \x00\x01\x02abc123!?\xff
 }
+
+func ExampleMap() {
+	// In real code the source future would typically come from a read, such
+	// as tr.Get(key). Resolved is used here so the example runs on its own.
+	var f fdb.TypedFuture[[]byte] = fdb.Resolved([]byte("42"), nil)
+
+	n := fdb.Map(f, func(v []byte) (int, error) {
+		return strconv.Atoi(string(v))
+	})
+	doubled := fdb.FlatMap(n, func(v int) fdb.TypedFuture[int] {
+		return fdb.Resolved(v*2, nil)
+	})
+	fallback := fdb.Then(fdb.Resolved(0, fdb.Error{Code: 1020}), func(v int, e error) (int, error) {
+		if e != nil {
+			return -1, nil
+		}
+		return v, nil
+	})
+
+	fmt.Println(n.MustGet(), doubled.MustGet(), fallback.MustGet())
+	// Output: 42 84 -1
+}
+
+// batchedRange returns a RangeResult over kvs (which must be sorted) which
+// reads them at most two at a time, counting the reads in *reads, and failing
+// with e (if not nil) once the key-value pairs are exhausted. The selectors of
+// each read are assumed to be those set by RangeIterator.
+func batchedRange(kvs []fdb.KeyValue, options fdb.RangeOptions, e error, reads *int) fdb.RangeResult {
+	return fdb.NewRangeResult(fdb.KeyRange{Begin: fdb.Key(""), End: fdb.Key("\xff")}, options,
+		func(sr fdb.SelectorRange, options fdb.RangeOptions, iteration int) ([]fdb.KeyValue, bool, error) {
+			*reads++
+			bsel, esel := sr.Begin.FDBKeySelector(), sr.End.FDBKeySelector()
+			var remaining []fdb.KeyValue
+			for _, kv := range kvs {
+				c := bytes.Compare(kv.Key, bsel.Key.FDBKey())
+				if (c > 0 || c == 0 && !bsel.OrEqual) && bytes.Compare(kv.Key, esel.Key.FDBKey()) < 0 {
+					remaining = append(remaining, kv)
+				}
+			}
+			if len(remaining) == 0 {
+				return nil, false, e
+			}
+			n := 2
+			if options.Limit > 0 && options.Limit < n {
+				n = options.Limit
+			}
+			if n > len(remaining) {
+				n = len(remaining)
+			}
+			var batch []fdb.KeyValue
+			for i := 0; i < n; i++ {
+				if options.Reverse {
+					batch = append(batch, remaining[len(remaining)-1-i])
+				} else {
+					batch = append(batch, remaining[i])
+				}
+			}
+			return batch, n < len(remaining) || e != nil, nil
+		})
+}
+
+// replayTransactor runs each transactional function twice, returning the
+// result of the first call, as a Database using WithIdempotency does when the
+// first attempt commits but reports commit_unknown_result.
+type replayTransactor struct{}
+
+func (replayTransactor) Transact(f func(fdb.Transaction) (interface{}, error)) (interface{}, error) {
+	ret, e := f(fdb.Transaction{})
+	if e != nil {
+		return nil, e
+	}
+	f(fdb.Transaction{})
+	return ret, nil
+}
+
+func (replayTransactor) ReadTransact(f func(fdb.ReadTransaction) (interface{}, error)) (interface{}, error) {
+	ret, e := f(fdb.Transaction{})
+	if e != nil {
+		return nil, e
+	}
+	f(fdb.Transaction{})
+	return ret, nil
+}
+
+func TestTransactGeneric(t *testing.T) {
+	calls := 0
+	v, e := fdb.Transact(replayTransactor{}, func(fdb.Transaction) (string, error) {
+		calls++
+		return fmt.Sprint("call ", calls), nil
+	})
+	if v != "call 1" || e != nil {
+		t.Errorf("Transact returned %q, %v; want the result of the first call", v, e)
+	}
+
+	calls = 0
+	n, e := fdb.ReadTransact(replayTransactor{}, func(fdb.ReadTransaction) (int, error) {
+		calls++
+		return calls, nil
+	})
+	if n != 1 || e != nil {
+		t.Errorf("ReadTransact returned %v, %v; want the result of the first call", n, e)
+	}
+
+	v, e = fdb.Transact(replayTransactor{}, func(fdb.Transaction) (string, error) {
+		return "ignored", fdb.ErrValueTooLarge
+	})
+	if v != "" || e != fdb.ErrValueTooLarge {
+		t.Errorf("failed Transact returned %q, %v; want \"\", value_too_large", v, e)
+	}
+}
+
+func TestTransactReadWriter(t *testing.T) {
+	db := memdb.New()
+	n, e := fdb.TransactReadWriter(db, func(tr fdb.ReadWriter) (int, error) {
+		tr.Set(fdb.Key("k"), []byte("v"))
+		return 42, nil
+	})
+	if n != 42 || e != nil {
+		t.Errorf("TransactReadWriter returned %v, %v; want 42", n, e)
+	}
+
+	v, e := fdb.ReadTransactReader(db, func(rtr fdb.Reader) ([]byte, error) {
+		return rtr.Get(fdb.Key("k")).Get()
+	})
+	if string(v) != "v" || e != nil {
+		t.Errorf("ReadTransactReader returned %q, %v; want \"v\"", v, e)
+	}
+
+	n, e = fdb.TransactReadWriter(db, func(tr fdb.ReadWriter) (int, error) {
+		return 1, fdb.ErrValueTooLarge
+	})
+	if n != 0 || e != fdb.ErrValueTooLarge {
+		t.Errorf("failed TransactReadWriter returned %v, %v; want 0, value_too_large", n, e)
+	}
+}
+
+func TestRangeResultAll(t *testing.T) {
+	ss := subspace.Sub("users")
+	var kvs []fdb.KeyValue
+	for i := 0; i < 5; i++ {
+		kvs = append(kvs, fdb.KeyValue{Key: ss.Pack(tuple.Tuple{int64(i)}), Value: []byte{byte(i)}})
+	}
+
+	var reads, n int
+	for kv, e := range batchedRange(kvs, fdb.RangeOptions{}, nil, &reads).All() {
+		if e != nil {
+			t.Fatal(e)
+		}
+		if kv.Value[0] != byte(n) {
+			t.Errorf("got value %v at position %d", kv.Value, n)
+		}
+		n++
+	}
+	if n != 5 || reads != 3 {
+		t.Errorf("iterated over %d key-value pairs in %d reads, want 5 in 3", n, reads)
+	}
+
+	// Breaking early stops reading after the prefetched batch
+	reads = 0
+	for range batchedRange(kvs, fdb.RangeOptions{}, nil, &reads).All() {
+		break
+	}
+	if reads != 1 {
+		t.Errorf("early break made %d reads, want 1", reads)
+	}
+
+	// Errors are yielded once, after the preceding key-value pairs
+	n = 0
+	var errs []error
+	for _, e := range batchedRange(kvs[:2], fdb.RangeOptions{}, fdb.ErrTransactionTooOld, new(int)).All() {
+		if e != nil {
+			errs = append(errs, e)
+		} else {
+			n++
+		}
+	}
+	if n != 2 || len(errs) != 1 || errs[0] != fdb.ErrTransactionTooOld {
+		t.Errorf("got %d key-value pairs and errors %v", n, errs)
+	}
+
+	n = 0
+	for kv, e := range subspace.All(ss, batchedRange(kvs, fdb.RangeOptions{}, nil, new(int))) {
+		if e != nil {
+			t.Fatal(e)
+		}
+		if len(kv.Key) != 1 || kv.Key[0] != int64(n) {
+			t.Errorf("got key %v at position %d", kv.Key, n)
+		}
+		n++
+	}
+	if n != 5 {
+		t.Errorf("iterated over %d unpacked key-value pairs, want 5", n)
+	}
+
+	other := append(kvs[:1:1], fdb.KeyValue{Key: fdb.Key("elsewhere")})
+	for _, e := range subspace.All(ss, batchedRange(other, fdb.RangeOptions{}, nil, new(int))) {
+		if e != nil {
+			return
+		}
+	}
+	t.Error("no error for a key outside the subspace")
+}
+
+func TestRangeReadAhead(t *testing.T) {
+	var kvs []fdb.KeyValue
+	for i := 0; i < 10; i++ {
+		kvs = append(kvs, fdb.KeyValue{Key: fdb.Key{byte(i)}, Value: []byte{byte(i)}})
+	}
+
+	var reads int
+	ri := batchedRange(kvs, fdb.RangeOptions{ReadAhead: 2}, nil, &reads).Iterator()
+	ri.Advance()
+	ri.MustGet()
+	if reads != 3 {
+		t.Errorf("made %d reads after the first key-value pair, want 3", reads)
+	}
+
+	for _, options := range []fdb.RangeOptions{
+		{ReadAhead: 3},
+		{ReadAhead: -1},
+		{ReadAhead: 3, Limit: 5},
+		{ReadAhead: 100, Limit: 5, Reverse: true},
+		{ReadAhead: 1, Mode: fdb.StreamingModeWantAll},
+	} {
+		want := len(kvs)
+		if options.Limit > 0 {
+			want = options.Limit
+		}
+		reads = 0
+		var got []fdb.KeyValue
+		ri := batchedRange(kvs, options, nil, &reads).Iterator()
+		for ri.Advance() {
+			got = append(got, ri.MustGet())
+		}
+		if len(got) != want {
+			t.Errorf("%+v: got %d key-value pairs, want %d", options, len(got), want)
+		}
+		for i, kv := range got {
+			k := byte(i)
+			if options.Reverse {
+				k = byte(len(kvs) - 1 - i)
+			}
+			if kv.Key[0] != k {
+				t.Errorf("%+v: got key %v at position %d", options, kv.Key, i)
+			}
+		}
+		if maxReads := (want+1)/2 + 1; reads > maxReads {
+			t.Errorf("%+v: made %d reads, want at most %d", options, reads, maxReads)
+		}
+	}
+}
+
+func TestParallelScan(t *testing.T) {
+	db := memdb.New()
+	_, e := db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+		for i := 0; i < 1000; i++ {
+			tr.Set(fdb.Key(fmt.Sprintf("k%04d", i)), []byte(strconv.Itoa(i)))
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	er := fdb.KeyRange{Begin: fdb.Key("k0100"), End: fdb.Key("k0900")}
+	boundaries := []fdb.Key{fdb.Key("a"), fdb.Key("k0100"), fdb.Key("k0150"), fdb.Key("k0400"), fdb.Key("k0401"), fdb.Key("k0700"), fdb.Key("z")}
+
+	for _, options := range []fdb.ParallelScanOptions{
+		{Ordered: true},
+		{Ordered: true, Workers: 2, Boundaries: boundaries},
+		{Workers: 3, Boundaries: boundaries},
+	} {
+		seen := make(map[string]bool)
+		var last fdb.Key
+		for kv, e := range fdb.ParallelScan(context.Background(), db, er, options) {
+			if e != nil {
+				t.Fatal(e)
+			}
+			if options.Ordered && last != nil && bytes.Compare(kv.Key, last) <= 0 {
+				t.Errorf("%+v: got key %s after %s", options, kv.Key, last)
+			}
+			last = kv.Key
+			seen[string(kv.Key)] = true
+		}
+		if len(seen) != 800 || !seen["k0100"] || !seen["k0899"] {
+			t.Errorf("%+v: scanned %d distinct keys, want 800", options, len(seen))
+		}
+	}
+
+	n := 0
+	for _, e := range fdb.ParallelScan(context.Background(), db, er, fdb.ParallelScanOptions{Boundaries: boundaries}) {
+		if e != nil {
+			t.Fatal(e)
+		}
+		if n++; n == 10 {
+			break
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var errs []error
+	for _, e := range fdb.ParallelScan(ctx, db, er, fdb.ParallelScanOptions{}) {
+		errs = append(errs, e)
+	}
+	if len(errs) != 1 || errs[0] != context.Canceled {
+		t.Errorf("got %v from a cancelled scan, want a single context.Canceled", errs)
+	}
+}
+
+func TestScanner(t *testing.T) {
+	db := memdb.New()
+	set := func(value string) {
+		_, e := db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+			for i := 0; i < 10; i++ {
+				tr.Set(fdb.Key{byte(i)}, []byte(value))
+			}
+			return nil, nil
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	scan := func(s *fdb.Scanner, max int) (keys []byte, values []string) {
+		for kv, e := range s.All(context.Background()) {
+			if e != nil {
+				t.Fatal(e)
+			}
+			keys = append(keys, kv.Key[0])
+			values = append(values, string(kv.Value))
+			if len(keys) == max {
+				break
+			}
+		}
+		return
+	}
+
+	set("old")
+	er := fdb.KeyRange{Begin: fdb.Key{2}, End: fdb.Key{8}}
+	for _, pin := range []bool{false, true} {
+		s := fdb.NewScanner(db, er, fdb.ScanOptions{PinReadVersion: pin})
+		if keys, _ := scan(s, 2); !bytes.Equal(keys, []byte{2, 3}) || !bytes.Equal(s.Cursor(), fdb.Key{3}) {
+			t.Errorf("pin %v: scanned %v to cursor %v, want [2 3] to [3]", pin, keys, s.Cursor())
+		}
+
+		set("new")
+		keys, values := scan(s, 0)
+		if !bytes.Equal(keys, []byte{4, 5, 6, 7}) {
+			t.Errorf("pin %v: resumed scan read %v, want [4 5 6 7]", pin, keys)
+		}
+		want := "new"
+		if pin {
+			want = "old"
+		}
+		for _, v := range values {
+			if v != want {
+				t.Errorf("pin %v: resumed scan read %q, want %q", pin, v, want)
+			}
+		}
+		if v, pinned := s.ReadVersion(); pinned != pin || (v != 0) != pin {
+			t.Errorf("pin %v: got read version %d, pinned %v", pin, v, pinned)
+		}
+		set("old")
+	}
+
+	s := fdb.NewScanner(db, er, fdb.ScanOptions{Cursor: fdb.Key{5}})
+	if keys, _ := scan(s, 0); !bytes.Equal(keys, []byte{6, 7}) {
+		t.Errorf("scan from cursor read %v, want [6 7]", keys)
+	}
+
+	// Scanning with a Transaction adds read conflicts, unless Snapshot is set
+	for _, snapshot := range []bool{false, true} {
+		tr, e := db.CreateTransaction()
+		if e != nil {
+			t.Fatal(e)
+		}
+		scan(fdb.NewScanner(tr, er, fdb.ScanOptions{Snapshot: snapshot}), 0)
+		tr.Set(fdb.Key("w"), nil)
+		set("changed")
+		if e := tr.Commit().Get(); (e == fdb.ErrNotCommitted) == snapshot {
+			t.Errorf("snapshot %v: commit returned %v", snapshot, e)
+		}
+	}
+}
+
+func TestBulkWriter(t *testing.T) {
+	db := memdb.New()
+	var mu sync.Mutex
+	var progress []fdb.BulkProgress
+	w := fdb.NewBulkWriter(db, fdb.BulkWriterOptions{
+		MaxBatchMutations: 100,
+		Progress: func(p fdb.BulkProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		},
+	})
+	for i := 0; i < 1000; i++ {
+		if e := w.Set(fdb.Key(fmt.Sprintf("k%04d", i)), []byte("v")); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if e := w.Flush(); e != nil {
+		t.Fatal(e)
+	}
+	w.ClearRange(fdb.KeyRange{Begin: fdb.Key("k0100"), End: fdb.Key("k0200")})
+	w.Add(fdb.Key("count"), []byte{3, 0, 0, 0})
+	if e := w.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	if len(progress) != 11 || progress[10].Batches != 11 || progress[10].TotalMutations != 1002 {
+		t.Errorf("got %d progress reports, last %+v; want 11 batches of 1002 mutations", len(progress), progress[len(progress)-1])
+	}
+	n, e := db.ReadTransact(func(rtr memdb.ReadTransaction) (interface{}, error) {
+		if v := rtr.Get(fdb.Key("count")).MustGet(); !bytes.Equal(v, []byte{3, 0, 0, 0}) {
+			t.Errorf("count is %v", v)
+		}
+		return len(rtr.GetRange(fdb.KeyRange{Begin: fdb.Key("k"), End: fdb.Key("l")}, fdb.RangeOptions{}).GetSliceOrPanic()), nil
+	})
+	if e != nil || n != 900 {
+		t.Errorf("read %v keys (%v), want 900", n, e)
+	}
+
+	// A batch over the transaction size limit is split
+	progress = nil
+	w = fdb.NewBulkWriter(db, fdb.BulkWriterOptions{
+		MaxBatchBytes: 100 << 20,
+		Concurrency:   1,
+		Progress:      func(p fdb.BulkProgress) { progress = append(progress, p) },
+	})
+	value := make([]byte, 90000)
+	for i := 0; i < 150; i++ {
+		w.Set(fdb.Key(fmt.Sprintf("big%03d", i)), value)
+	}
+	if e := w.Close(); e != nil {
+		t.Fatal(e)
+	}
+	if len(progress) < 2 || progress[len(progress)-1].TotalMutations != 150 {
+		t.Errorf("got progress %+v, want a split batch of 150 mutations", progress)
+	}
+
+	// Failed batches are reported and stop the writer
+	progress = nil
+	w = fdb.NewBulkWriter(db, fdb.BulkWriterOptions{
+		Progress: func(p fdb.BulkProgress) { progress = append(progress, p) },
+	})
+	w.Set(fdb.Key("toolarge"), make([]byte, 200000))
+	if e := w.Flush(); !errors.Is(e, fdb.ErrValueTooLarge) {
+		t.Errorf("flushed with error %v, want value_too_large", e)
+	}
+	if e := w.Set(fdb.Key("next"), nil); !errors.Is(e, fdb.ErrValueTooLarge) {
+		t.Errorf("set after a failed batch returned %v", e)
+	}
+	if e := w.Close(); !errors.Is(e, fdb.ErrValueTooLarge) || len(progress) != 1 || progress[0].Err == nil {
+		t.Errorf("closed with error %v and progress %+v", e, progress)
+	}
+
+	// The rest of a split batch is reported when its first half fails
+	progress = nil
+	injected := errors.New("injected")
+	w = fdb.NewBulkWriter(&failingTransactor{Database: db, fail: 2, err: injected}, fdb.BulkWriterOptions{
+		MaxBatchBytes: 100 << 20,
+		Concurrency:   1,
+		Progress:      func(p fdb.BulkProgress) { progress = append(progress, p) },
+	})
+	for i := 0; i < 150; i++ {
+		w.Set(fdb.Key(fmt.Sprintf("big%03d", i)), value)
+	}
+	if e := w.Close(); e != injected {
+		t.Errorf("closed with error %v, want %v", e, injected)
+	}
+	if len(progress) != 2 || progress[0].Mutations+progress[1].Mutations != 150 || progress[1].Err != injected {
+		t.Errorf("got progress %+v, want both halves of 150 mutations reported", progress)
+	}
+
+	// Mutations collected after a batch has failed are reported on Close
+	progress = nil
+	failed := make(chan struct{})
+	w = fdb.NewBulkWriter(&failingTransactor{Database: db, fail: 1, err: injected}, fdb.BulkWriterOptions{
+		MaxBatchMutations: 2,
+		Progress: func(p fdb.BulkProgress) {
+			progress = append(progress, p)
+			if len(progress) == 1 {
+				close(failed)
+			}
+		},
+	})
+	for _, k := range []string{"a", "b", "c"} {
+		w.Set(fdb.Key(k), nil)
+	}
+	<-failed
+	if e := w.Close(); e != injected {
+		t.Errorf("closed with error %v, want %v", e, injected)
+	}
+	if len(progress) != 2 || progress[1].Mutations != 1 || progress[1].Err != injected {
+		t.Errorf("got progress %+v, want the buffered mutation reported as failed", progress)
+	}
+
+	// A Transaction cannot commit batches
+	w = fdb.NewBulkWriter(fdb.Transaction{}, fdb.BulkWriterOptions{})
+	if e := w.Set(fdb.Key("k"), nil); e == nil {
+		t.Error("set with a Transaction as the transactor succeeded")
+	}
+	w.Close()
+}
+
+// failingTransactor fails the fail'th transaction with err.
+type failingTransactor struct {
+	memdb.Database
+	fail int
+	err  error
+}
+
+func (f *failingTransactor) TransactReadWriter(fn func(fdb.ReadWriter) (interface{}, error)) (interface{}, error) {
+	f.fail--
+	if f.fail == 0 {
+		return nil, f.err
+	}
+	return f.Database.TransactReadWriter(fn)
+}
+
+func TestDeleteWhere(t *testing.T) {
+	db := memdb.New()
+	_, e := db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+		for i := 0; i < 1000; i++ {
+			tr.Set(fdb.Key(fmt.Sprintf("k%04d", i)), []byte(strconv.Itoa(i)))
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	even := func(kv fdb.KeyValue) bool {
+		i, _ := strconv.Atoi(string(kv.Value))
+		return i%2 == 0
+	}
+	er := fdb.KeyRange{Begin: fdb.Key("k0100"), End: fdb.Key("k0900")}
+
+	// Stop part way through, as if the job had crashed
+	ctx, cancel := context.WithCancel(context.Background())
+	p, e := fdb.DeleteWhere(ctx, db, er, even, fdb.DeleteOptions{
+		BatchKeys: 150,
+		Progress: func(p fdb.DeleteProgress) {
+			if p.Examined >= 300 {
+				cancel()
+			}
+		},
+	})
+	if e != context.Canceled || p.Examined != 300 || p.Deleted != 150 || !bytes.Equal(p.Cursor, fdb.Key("k0399")) {
+		t.Fatalf("got progress %+v and error %v", p, e)
+	}
+
+	start := time.Now()
+	p, e = fdb.DeleteWhere(context.Background(), db, er, even, fdb.DeleteOptions{BatchKeys: 100, KeysPerSecond: 2000, Cursor: p.Cursor})
+	if e != nil || p.Examined != 500 || p.Deleted != 250 {
+		t.Fatalf("resumed with progress %+v and error %v", p, e)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("examined 500 keys at 2000 keys/s in %v", elapsed)
+	}
+
+	n, e := db.ReadTransact(func(rtr memdb.ReadTransaction) (interface{}, error) {
+		kvs := rtr.GetRange(fdb.KeyRange{Begin: fdb.Key("k"), End: fdb.Key("l")}, fdb.RangeOptions{}).GetSliceOrPanic()
+		for _, kv := range kvs {
+			if even(kv) && bytes.Compare(kv.Key, fdb.Key("k0100")) >= 0 && bytes.Compare(kv.Key, fdb.Key("k0900")) < 0 {
+				t.Errorf("%s was not deleted", kv.Key)
+			}
+		}
+		return len(kvs), nil
+	})
+	if e != nil || n != 600 {
+		t.Errorf("%v keys remain (%v), want 600", n, e)
+	}
+}
+
+func TestIdempotentCommit(t *testing.T) {
+	db := memdb.New()
+	counter := fdb.Key("counter")
+	c := fdb.NewIdempotentCommit(fdb.Key("id"))
+
+	attempt := func(ret string) memdb.Transaction {
+		tr, e := db.CreateTransaction()
+		if e != nil {
+			t.Fatal(e)
+		}
+		if v, done := c.Committed(tr); done {
+			t.Fatalf("attempt %s found an earlier attempt (%v) committed", ret, v)
+		}
+		tr.Add(counter, []byte{1})
+		c.Prepare(tr, ret)
+		return tr
+	}
+
+	// The first attempt reports commit_unknown_result, and its commit lands
+	// only after the second attempt has read the ID. The second attempt then
+	// conflicts, but also reports commit_unknown_result.
+	tr1 := attempt("first")
+	c.Observe(true)
+	tr2 := attempt("second")
+	if e := tr1.Commit().Get(); e != nil {
+		t.Fatal(e)
+	}
+	if e := tr2.Commit().Get(); e != fdb.ErrNotCommitted {
+		t.Fatalf("second attempt committed with %v, want not_committed", e)
+	}
+	c.Observe(true)
+
+	tr3, e := db.CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	if v, done := c.Committed(tr3); !done || v != "first" {
+		t.Errorf("third attempt found %v, %v; want the result of the first attempt", v, done)
+	}
+	if v := tr3.Get(counter).MustGet(); !bytes.Equal(v, []byte{1}) {
+		t.Errorf("counter is %v, want 1", v)
+	}
+}
+
+// lateCommitTransactor runs transactional functions like a Database using
+// WithIdempotency, except that the commit of the first attempt lands only after
+// the second attempt has run, and every attempt reports commit_unknown_result.
+// The functions are passed a zero Transaction, and their results are recorded
+// against a memdb database.
+type lateCommitTransactor struct {
+	memdb.Database
+}
+
+func (l lateCommitTransactor) Transact(f func(fdb.Transaction) (interface{}, error)) (interface{}, error) {
+	c := fdb.NewIdempotentCommit(fdb.Key("id"))
+	var first memdb.Transaction
+	for attempt := 1; ; attempt++ {
+		tr, e := l.CreateTransaction()
+		if e != nil {
+			return nil, e
+		}
+		if ret, done := c.Committed(tr); done {
+			return ret, nil
+		}
+		ret, e := f(fdb.Transaction{})
+		if e != nil {
+			return nil, e
+		}
+		tr.Add(fdb.Key("counter"), []byte{1})
+		c.Prepare(tr, ret)
+
+		switch attempt {
+		case 1:
+			first = tr
+		case 2:
+			if e := first.Commit().Get(); e != nil {
+				return nil, e
+			}
+			if e := tr.Commit().Get(); e != fdb.ErrNotCommitted {
+				return nil, fmt.Errorf("second attempt committed with %v, want not_committed", e)
+			}
+		}
+		c.Observe(true)
+	}
+}
+
+func (l lateCommitTransactor) ReadTransact(f func(fdb.ReadTransaction) (interface{}, error)) (interface{}, error) {
+	return f(fdb.Transaction{})
+}
+
+func TestIdempotentTransact(t *testing.T) {
+	db := memdb.New()
+	attempts := 0
+	v, e := fdb.Transact(lateCommitTransactor{db}, func(fdb.Transaction) (string, error) {
+		attempts++
+		return fmt.Sprint("attempt ", attempts), nil
+	})
+	if v != "attempt 1" || e != nil {
+		t.Errorf("Transact returned %q, %v; want the result of the first attempt", v, e)
+	}
+	if attempts != 2 {
+		t.Errorf("ran %d attempts, want 2", attempts)
+	}
+
+	n, e := db.ReadTransact(func(rtr memdb.ReadTransaction) (interface{}, error) {
+		return rtr.Get(fdb.Key("counter")).Get()
+	})
+	if e != nil || !bytes.Equal(n.([]byte), []byte{1}) {
+		t.Errorf("counter is %v (%v), want 1", n, e)
+	}
+}
+
+func TestRetryableContext(t *testing.T) {
+	tr, e := memdb.New().CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Cancelling the context stops the retry loop
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	_, e = fdb.Retryable(ctx, func() (interface{}, error) {
+		attempts++
+		cancel()
+		return nil, fdb.ErrNotCommitted
+	}, tr.OnError, nil)
+	if e != context.Canceled || attempts != 1 {
+		t.Errorf("cancelled retry loop returned %v after %d attempts, want context.Canceled after 1", e, attempts)
+	}
+
+	// The deadline of the context interrupts the delay of a retry policy
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	policy := fdb.RetryPolicyFunc(func(fdb.Error, int) (time.Duration, bool) {
+		return time.Hour, true
+	})
+	start := time.Now()
+	_, e = fdb.Retryable(ctx, func() (interface{}, error) {
+		return nil, fdb.ErrNotCommitted
+	}, tr.OnError, policy)
+	if e != context.DeadlineExceeded || time.Since(start) > time.Minute {
+		t.Errorf("retry loop returned %v after %v, want context.DeadlineExceeded at the deadline", e, time.Since(start))
+	}
+
+	// transaction_timed_out is translated only once the context is done
+	for _, tc := range []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want error
+	}{
+		{"Background", func() (context.Context, context.CancelFunc) {
+			return context.Background(), func() {}
+		}, fdb.ErrTransactionTimedOut},
+		{"BeforeDeadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), time.Hour)
+		}, fdb.ErrTransactionTimedOut},
+		{"AfterDeadline", func() (context.Context, context.CancelFunc) {
+			return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		}, context.DeadlineExceeded},
+		{"Cancelled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+			cancel()
+			return ctx, cancel
+		}, context.Canceled},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := tc.ctx()
+			defer cancel()
+			if e := fdb.ContextError(ctx, fdb.ErrTransactionTimedOut); e != tc.want {
+				t.Errorf("got %v, want %v", e, tc.want)
+			}
+			if e := fdb.ContextError(ctx, fdb.ErrNotCommitted); e != fdb.ErrNotCommitted {
+				t.Errorf("got %v for not_committed", e)
+			}
+		})
+	}
+}
+
+// pendingFuture returns a future of a transaction of db which is not ready
+// until the transaction is committed.
+func pendingFuture(t *testing.T, db memdb.Database) fdb.FutureKey {
+	tr, e := db.CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	tr.Set(fdb.Key("k"), nil)
+	return tr.GetVersionstamp()
+}
+
+func TestRetryPolicy(t *testing.T) {
+	tr, e := memdb.New().CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	type call struct {
+		code, attempt int
+	}
+	run := func(policy fdb.RetryPolicy, errs ...error) (int, error) {
+		attempts := 0
+		_, e := fdb.Retryable(context.Background(), func() (interface{}, error) {
+			attempts++
+			if attempts > len(errs) {
+				return nil, nil
+			}
+			return nil, errs[attempts-1]
+		}, tr.OnError, policy)
+		return attempts, e
+	}
+
+	// A policy sees every failed attempt, and can limit the retries
+	var calls []call
+	limit := fdb.RetryPolicyFunc(func(e fdb.Error, attempt int) (time.Duration, bool) {
+		calls = append(calls, call{e.Code, attempt})
+		return 0, attempt < 3
+	})
+	n, e := run(limit, fdb.ErrNotCommitted, fdb.ErrTransactionTooOld, fdb.ErrNotCommitted, fdb.ErrNotCommitted)
+	if n != 3 || e != fdb.ErrNotCommitted {
+		t.Errorf("limited retry loop made %d attempts and returned %v, want 3 and not_committed", n, e)
+	}
+	if want := []call{{1020, 1}, {1007, 2}, {1020, 3}}; fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("policy called with %v, want %v", calls, want)
+	}
+
+	// A policy can abort on a specific error
+	abort := fdb.RetryPolicyFunc(func(e fdb.Error, attempt int) (time.Duration, bool) {
+		return 0, e != fdb.ErrTransactionTooOld
+	})
+	if n, e := run(abort, fdb.ErrNotCommitted, fdb.ErrTransactionTooOld); n != 2 || e != fdb.ErrTransactionTooOld {
+		t.Errorf("aborted retry loop made %d attempts and returned %v, want 2 and transaction_too_old", n, e)
+	}
+
+	// A policy delays the next attempt
+	backoff := fdb.RetryPolicyFunc(func(e fdb.Error, attempt int) (time.Duration, bool) {
+		return time.Duration(attempt) * 10 * time.Millisecond, true
+	})
+	start := time.Now()
+	if n, e := run(backoff, fdb.ErrNotCommitted, fdb.ErrNotCommitted); n != 3 || e != nil {
+		t.Errorf("retry loop with backoff made %d attempts and returned %v, want 3 and success", n, e)
+	}
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("retry loop with backoff took %v, want at least 30ms", d)
+	}
+
+	// OnError still decides which errors are retried, and commit_unknown_result
+	// is retried as it is without a policy
+	always := fdb.RetryPolicyFunc(func(fdb.Error, int) (time.Duration, bool) { return 0, true })
+	if n, e := run(always, fdb.ErrCommitUnknownResult); n != 2 || e != nil {
+		t.Errorf("commit_unknown_result made %d attempts and returned %v, want 2 and success", n, e)
+	}
+	if n, e := run(always, fdb.ErrValueTooLarge, fdb.ErrNotCommitted); n != 1 || e != fdb.ErrValueTooLarge {
+		t.Errorf("non-retryable error made %d attempts and returned %v, want 1 and value_too_large", n, e)
+	}
+
+	// Errors which are not Errors are never retried, nor passed to the policy
+	calls = nil
+	if n, e := run(limit, errors.New("fail")); n != 1 || e == nil || len(calls) != 0 {
+		t.Errorf("non-Error made %d attempts, returned %v and called the policy %d times", n, e, len(calls))
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	// The network cannot be restarted within the process
+	switch e := fdb.StopNetwork(); e {
+	case fdb.ErrNetworkStopped:
+		t.Skip("the network has already been stopped")
+	case nil:
+		t.Fatal("stopping a network which was not started succeeded")
+	}
+
+	// A closed database creates no transactions
+	db := fdb.NewClosedDatabase("test.cluster")
+	if e := db.Close(); e != nil {
+		t.Errorf("closing a closed database returned %v", e)
+	}
+	if fdb.IsDatabaseCached("test.cluster") {
+		t.Error("closed database is still cached")
+	}
+	if _, e := db.CreateTransaction(); e != fdb.ErrDatabaseClosed {
+		t.Errorf("CreateTransaction on a closed database returned %v", e)
+	}
+	if _, e := db.Transact(func(fdb.Transaction) (interface{}, error) { return nil, nil }); e != fdb.ErrDatabaseClosed {
+		t.Errorf("Transact on a closed database returned %v", e)
+	}
+	if e := db.Options().SetTransactionRetryLimit(1); e != fdb.ErrDatabaseClosed {
+		t.Errorf("setting an option of a closed database returned %v", e)
+	}
+
+	fdb.MustAPIVersion(620)
+	if e := fdb.StartNetwork(); e != nil {
+		t.Fatal(e)
+	}
+
+	// A database whose only coordinator is unreachable never obtains a read
+	// version, leaving a future pending when the network stops. (The C
+	// library may be unable to open it, as when it is a stub.)
+	var pending fdb.FutureInt64
+	cf := filepath.Join(t.TempDir(), "unreachable.cluster")
+	if e := os.WriteFile(cf, []byte("test:test@127.0.0.1:1\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if udb, e := fdb.OpenDatabase(cf); e == nil {
+		if tr, e := udb.CreateTransaction(); e == nil {
+			pending = tr.GetReadVersion()
+		}
+	}
+
+	// StopNetwork waits for running transactional functions, and no more
+	// are started
+	done, e := fdb.BeginTransact()
+	if e != nil {
+		t.Fatal(e)
+	}
+	stopped := make(chan error)
+	go func() { stopped <- fdb.StopNetwork() }()
+	for {
+		_, e := db.ReadTransact(func(fdb.ReadTransaction) (interface{}, error) { return nil, nil })
+		if e == fdb.ErrNetworkStopped {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case e := <-stopped:
+		t.Fatalf("StopNetwork returned %v while a transactional function was running", e)
+	case <-time.After(10 * time.Millisecond):
+	}
+	done()
+	if e := <-stopped; e != nil {
+		t.Fatal(e)
+	}
+
+	// Futures which were not ready fail, rather than blocking forever
+	if pending != nil {
+		got := make(chan error, 1)
+		go func() {
+			_, e := pending.Get()
+			got <- e
+		}()
+		select {
+		case e := <-got:
+			if e != fdb.ErrNetworkStopped {
+				t.Errorf("pending future returned %v after StopNetwork, want ErrNetworkStopped", e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("pending future did not become ready after StopNetwork")
+		}
+		if !pending.IsReady() {
+			t.Error("pending future is not ready after StopNetwork")
+		}
+	} else {
+		t.Log("could not open a database to leave a future pending")
+	}
+
+	if _, e := fdb.OpenDatabase("test.cluster"); e != fdb.ErrNetworkStopped {
+		t.Errorf("OpenDatabase after StopNetwork returned %v", e)
+	}
+	if e := fdb.StopNetwork(); e != fdb.ErrNetworkStopped {
+		t.Errorf("second StopNetwork returned %v", e)
+	}
+}
+
+func TestFutureGetContext(t *testing.T) {
+	db := memdb.New()
+	pending := func() fdb.FutureKey { return pendingFuture(t, db) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	f := pending()
+	if _, e := f.GetContext(ctx); e != context.Canceled {
+		t.Errorf("GetContext with a cancelled context returned %v", e)
+	}
+	if _, e := f.Get(); e != fdb.ErrOperationCancelled {
+		t.Errorf("future cancelled by GetContext returned %v, want operation_cancelled", e)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	length := fdb.Map[fdb.Key](pending(), func(k fdb.Key) (int, error) { return len(k), nil })
+	if _, e := length.GetContext(ctx); e != context.DeadlineExceeded {
+		t.Errorf("GetContext of a mapped future past the deadline returned %v", e)
+	}
+}
+
+func TestFutureWaiting(t *testing.T) {
+	db := memdb.New()
+	pending := func() fdb.FutureKey { return pendingFuture(t, db) }
+
+	// WaitAny returns the index of a ready future, waiting for one if needed
+	if i := fdb.WaitAny(pending(), fdb.Resolved(1, nil), pending()); i != 1 {
+		t.Errorf("WaitAny returned %d, want 1", i)
+	}
+	tr, e := db.CreateTransaction()
+	if e != nil {
+		t.Fatal(e)
+	}
+	tr.Set(fdb.Key("k"), nil)
+	go tr.Commit()
+	if i := fdb.WaitAny(pending(), tr.GetVersionstamp()); i != 1 {
+		t.Errorf("WaitAny returned %d, want 1", i)
+	}
+	if i := fdb.WaitAny(); i != -1 {
+		t.Errorf("WaitAny of no futures returned %d", i)
+	}
+
+	// WaitAll returns the first error of the futures
+	if e := fdb.WaitAll(fdb.Resolved(1, nil), tr.GetVersionstamp()); e != nil {
+		t.Errorf("WaitAll of successful futures returned %v", e)
+	}
+	first := fdb.Map(fdb.Resolved(1, nil), func(int) (int, error) {
+		return 0, fdb.Error{Code: 1020}
+	})
+	second := fdb.Resolved(0, fdb.Error{Code: 1007})
+	if e := fdb.WaitAll(fdb.Resolved(1, nil), first, second); e != (fdb.Error{Code: 1020}) {
+		t.Errorf("WaitAll returned %v, want error 1020", e)
+	}
+	g := pending()
+	time.AfterFunc(10*time.Millisecond, g.Cancel)
+	if e := fdb.WaitAll(g, first, second); e != fdb.ErrOperationCancelled {
+		t.Errorf("WaitAll returned %v, want operation_cancelled", e)
+	}
+}
+
+func TestFlatMapReadiness(t *testing.T) {
+	db := memdb.New()
+	calls := 0
+	length := func(k fdb.Key) fdb.TypedFuture[int] {
+		calls++
+		return fdb.Resolved(len(k), nil)
+	}
+
+	// Neither IsReady nor Cancel calls fn while the source is pending
+	f := fdb.FlatMap(fdb.TypedFuture[fdb.Key](pendingFuture(t, db)), length)
+	if f.IsReady() {
+		t.Error("FlatMap of a pending future is ready")
+	}
+	f.Cancel()
+	<-f.Ready()
+	if _, e := f.Get(); e != fdb.ErrOperationCancelled || calls != 0 {
+		t.Errorf("cancelled FlatMap returned %v after %d calls, want operation_cancelled", e, calls)
+	}
+
+	// nor once the source is ready
+	g := fdb.FlatMap(fdb.Resolved(fdb.Key("k"), nil), length)
+	g.Cancel()
+	if _, e := g.Get(); e != fdb.ErrOperationCancelled || calls != 0 {
+		t.Errorf("cancelled FlatMap returned %v after %d calls, want operation_cancelled", e, calls)
+	}
+
+	// IsReady does not wait for fn, which is called once when the readiness
+	// of the future is observed
+	release := make(chan struct{})
+	h := fdb.FlatMap(fdb.Resolved(fdb.Key("key"), nil), func(k fdb.Key) fdb.TypedFuture[int] {
+		<-release
+		return length(k)
+	})
+	if h.IsReady() {
+		t.Error("FlatMap is ready before fn has returned")
+	}
+	close(release)
+	<-h.Ready()
+	if n, e := h.Get(); n != 3 || e != nil || calls != 1 {
+		t.Errorf("FlatMap returned %v, %v after %d calls; want 3 after 1 call", n, e, calls)
+	}
+}
+
+func TestTypedFuturePanics(t *testing.T) {
+	f := fdb.Map(fdb.Resolved(1, nil), func(v int) (int, error) {
+		return fdb.Resolved(0, fdb.Error{Code: 1007}).MustGet(), nil
+	})
+	if _, e := f.Get(); e != (fdb.Error{Code: 1007}) {
+		t.Errorf("got %v, want error 1007", e)
+	}
+	if _, e := f.Get(); e != (fdb.Error{Code: 1007}) {
+		t.Errorf("got %v on second call, want error 1007", e)
+	}
+
+	g := fdb.FlatMap(fdb.Resolved(1, nil), func(v int) fdb.TypedFuture[string] {
+		panic(fdb.Error{Code: 1020})
+	})
+	if _, e := g.GetContext(context.Background()); e != (fdb.Error{Code: 1020}) {
+		t.Errorf("got %v, want error 1020", e)
+	}
+}
+
+func TestTypedFutureNonErrorPanics(t *testing.T) {
+	mustPanic := func(name string, get func()) {
+		t.Helper()
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("%s panicked with %v, want boom", name, r)
+			}
+		}()
+		get()
+	}
+
+	f := fdb.Map(fdb.Resolved(1, nil), func(v int) (int, error) {
+		panic("boom")
+	})
+	mustPanic("Map", func() { f.Get() })
+	if _, e := f.Get(); e == nil || !strings.Contains(e.Error(), "boom") {
+		t.Errorf("got %v on second call, want an error describing the panic", e)
+	}
+
+	g := fdb.FlatMap(fdb.Resolved(1, nil), func(v int) fdb.TypedFuture[string] {
+		panic("boom")
+	})
+	mustPanic("FlatMap", func() { g.MustGet() })
+	if _, e := g.Get(); e == nil || !strings.Contains(e.Error(), "boom") {
+		t.Errorf("got %v on second call, want an error describing the panic", e)
+	}
+
+	h := fdb.FlatMap(fdb.Resolved(1, nil), func(v int) fdb.TypedFuture[string] {
+		panic("boom")
+	})
+	<-h.Ready()
+	if !h.IsReady() {
+		t.Error("FlatMap future is not ready")
+	}
+	if _, e := h.Get(); e == nil || !strings.Contains(e.Error(), "boom") {
+		t.Errorf("got %v after Ready, want an error describing the panic", e)
+	}
+}
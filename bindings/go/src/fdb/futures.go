@@ -25,22 +25,26 @@ package fdb
 //  #cgo LDFLAGS: -lfdb_c -lm
 //  #define FDB_API_VERSION 620
 //  #include <foundationdb/fdb_c.h>
+//  #include <stdlib.h>
 //  #include <string.h>
 //
-//  extern void unlockMutex(void*);
+//  extern void futureReady(uintptr_t);
 //
-//  void go_callback(FDBFuture* f, void* m) {
-//      unlockMutex(m);
+//  void go_callback(FDBFuture* f, void* id) {
+//      futureReady((uintptr_t)id);
 //  }
 //
-//  void go_set_callback(void* f, void* m) {
-//      fdb_future_set_callback(f, (FDBCallback)&go_callback, m);
+//  void go_set_callback(void* f, uintptr_t id) {
+//      fdb_future_set_callback(f, (FDBCallback)&go_callback, (void*)id);
 //  }
 import "C"
 
 import (
+	"context"
+	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -68,45 +72,169 @@ type Future interface {
 	// Note that even if a future is not ready, the associated asynchronous
 	// operation may already have completed and be unable to be cancelled.
 	Cancel()
+
+	// Ready returns a channel that is closed when the future becomes ready,
+	// allowing a select statement to wait on a future alongside other
+	// channels. Ready does not block.
+	Ready() <-chan struct{}
 }
 
 type future struct {
-	ptr *C.FDBFuture
+	ptr       *C.FDBFuture
+	ready     *readyState
+	readyOnce sync.Once
+	readyID   atomic.Uintptr
+}
+
+// readyState is what the callback of a future delivers when the future becomes
+// ready (or is destroyed): the closing of its ready channel, and any functions
+// passed to onReady.
+type readyState struct {
+	ch chan struct{}
+
+	mu    sync.Mutex
+	fired bool
+	fns   []func()
+}
+
+func (s *readyState) fire() {
+	s.mu.Lock()
+	fns := s.fns
+	s.fired, s.fns = true, nil
+	s.mu.Unlock()
+
+	close(s.ch)
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// add arranges for fn to be called when the state fires, or calls it now if
+// the state has already fired.
+func (s *readyState) add(fn func()) {
+	s.mu.Lock()
+	if !s.fired {
+		s.fns = append(s.fns, fn)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	fn()
 }
 
 func newFuture(ptr *C.FDBFuture) *future {
-	f := &future{ptr}
-	runtime.SetFinalizer(f, func(f *future) { C.fdb_future_destroy(f.ptr) })
+	f := &future{ptr: ptr}
+	runtime.SetFinalizer(f, (*future).destroy)
 	return f
 }
 
-// Note: This function guarantees the callback will be executed **at most once**.
-func fdb_future_block_until_ready(f *C.FDBFuture) {
-	if C.fdb_future_is_ready(f) != 0 {
-		return
+func (f *future) destroy() {
+	C.fdb_future_destroy(f.ptr)
+
+	// The callback of a future destroyed before it is ready may never fire,
+	// so release its state here (firing it, as the future is now cancelled)
+	if id := f.readyID.Load(); id != 0 {
+		futureReady(C.uintptr_t(id))
 	}
+}
 
-	// The mutex here is used as a signal that the callback is complete.
-	// We first lock it, then pass it to the callback, and then lock it
-	// again. The second call to lock won't return until the callback has
-	// fired.
-	//
-	// See https://groups.google.com/forum/#!topic/golang-nuts/SPjQEcsdORA
-	// for the history of why this pattern came to be used.
-	m := &sync.Mutex{}
-	m.Lock()
-	C.go_set_callback(unsafe.Pointer(f), unsafe.Pointer(m))
-	m.Lock()
+// readyState returns the state fired by the callback of f, setting the
+// callback on first use.
+func (f *future) readyState() *readyState {
+	defer runtime.KeepAlive(f)
+
+	// A future accepts only one callback, so every waiter shares the state
+	// fired by it. The callback is handed the ID of the state in readyStates
+	// rather than a Go pointer.
+	f.readyOnce.Do(func() {
+		f.ready = &readyState{ch: make(chan struct{})}
+		if C.fdb_future_is_ready(f.ptr) != 0 {
+			f.ready.fire()
+			return
+		}
+		id := nextReadyID.Add(1)
+		readyStates.Store(id, f.ready)
+		f.readyID.Store(id)
+		C.go_set_callback(unsafe.Pointer(f.ptr), C.uintptr_t(id))
+
+		// The callback will not be called if the network has stopped
+		if networkHalted.Load() {
+			futureReady(C.uintptr_t(id))
+		}
+	})
+
+	return f.ready
+}
+
+// failPendingFutures fires the ready states of all futures awaiting their
+// callbacks, once the network has stopped and the callbacks can no longer be
+// called. Getting the value of such a future returns ErrNetworkStopped.
+func failPendingFutures() {
+	readyStates.Range(func(id, _ interface{}) bool {
+		futureReady(C.uintptr_t(id.(uintptr)))
+		return true
+	})
+}
+
+func (f *future) Ready() <-chan struct{} {
+	return f.readyState().ch
+}
+
+// onReady arranges for fn to be called when f becomes ready, or immediately if
+// it is already ready. fn may be called on the network thread, so it must not
+// block; it is also called if f is destroyed first.
+func (f *future) onReady(fn func()) {
+	f.readyState().add(fn)
 }
 
 func (f *future) BlockUntilReady() {
 	defer runtime.KeepAlive(f)
-	fdb_future_block_until_ready(f.ptr)
+
+	if C.fdb_future_is_ready(f.ptr) != 0 {
+		return
+	}
+
+	<-f.Ready()
+}
+
+// wait blocks until f is ready, and returns ErrNetworkStopped if the network
+// stopped before it became ready.
+func (f *future) wait() error {
+	defer runtime.KeepAlive(f)
+
+	f.BlockUntilReady()
+	if C.fdb_future_is_ready(f.ptr) == 0 && networkHalted.Load() {
+		return ErrNetworkStopped
+	}
+	return nil
+}
+
+// blockUntilReadyContext is like BlockUntilReady, but returns ctx.Err() if ctx
+// is done before the future is ready. In that case the future is cancelled,
+// and blockUntilReadyContext waits for the cancellation to be delivered so
+// that the callback does not outlive the call.
+func (f *future) blockUntilReadyContext(ctx context.Context) error {
+	defer runtime.KeepAlive(f)
+
+	if C.fdb_future_is_ready(f.ptr) != 0 {
+		return nil
+	}
+
+	select {
+	case <-f.Ready():
+		return nil
+	case <-ctx.Done():
+		// Cancelling a future that is not yet ready sets it to an error,
+		// which fires the callback and closes the ready channel.
+		C.fdb_future_cancel(f.ptr)
+		<-f.Ready()
+		return ctx.Err()
+	}
 }
 
 func (f *future) IsReady() bool {
 	defer runtime.KeepAlive(f)
-	return C.fdb_future_is_ready(f.ptr) != 0
+	return C.fdb_future_is_ready(f.ptr) != 0 || networkHalted.Load()
 }
 
 func (f *future) Cancel() {
@@ -114,6 +242,71 @@ func (f *future) Cancel() {
 	C.fdb_future_cancel(f.ptr)
 }
 
+// WaitAny blocks the calling goroutine until at least one of the provided
+// futures is ready, and returns the index of a ready future. If no futures are
+// provided, WaitAny returns -1 without blocking.
+//
+// To wait on futures alongside other channels, use the channels returned by
+// (Future).Ready in a select statement.
+func WaitAny(futures ...Future) int {
+	if len(futures) == 0 {
+		return -1
+	}
+
+	for i, f := range futures {
+		if f.IsReady() {
+			return i
+		}
+	}
+
+	cases := make([]reflect.SelectCase, len(futures))
+	for i, f := range futures {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(f.Ready())}
+	}
+
+	i, _, _ := reflect.Select(cases)
+	return i
+}
+
+// WaitAll blocks the calling goroutine until all of the provided futures are
+// ready, and returns the error of the first of them (in the order provided)
+// which failed, or nil if none did. The error of a future is that returned by
+// its Get method; futures which are not of the types returned by this package
+// are taken not to have failed.
+func WaitAll(futures ...Future) error {
+	for _, f := range futures {
+		if !f.IsReady() {
+			<-f.Ready()
+		}
+	}
+
+	for _, f := range futures {
+		if e := futureError(f); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// futureError returns the error of the ready future f.
+func futureError(f Future) (e error) {
+	switch f := f.(type) {
+	case FutureNil:
+		e = f.Get()
+	case FutureByteSlice:
+		_, e = f.Get()
+	case FutureKey:
+		_, e = f.Get()
+	case FutureInt64:
+		_, e = f.Get()
+	case FutureStringSlice:
+		_, e = f.Get()
+	case interface{ err() error }:
+		e = f.err()
+	}
+	return
+}
+
 // FutureByteSlice represents the asynchronous result of a function that returns
 // a value from a database. FutureByteSlice is a lightweight object that may be
 // efficiently copied, and is safe for concurrent use by multiple goroutines.
@@ -130,6 +323,11 @@ type FutureByteSlice interface {
 	// future is ready.
 	MustGet() []byte
 
+	// GetContext is like Get, but returns ctx.Err() if ctx is done before the
+	// future is ready. In that case the future (and its associated
+	// asynchronous operation) is cancelled.
+	GetContext(ctx context.Context) ([]byte, error)
+
 	Future
 }
 
@@ -148,7 +346,10 @@ func (f *futureByteSlice) Get() ([]byte, error) {
 		var value *C.uint8_t
 		var length C.int
 
-		f.BlockUntilReady()
+		if e := f.wait(); e != nil {
+			f.e = e
+			return
+		}
 
 		if err := C.fdb_future_get_value(f.ptr, &present, &value, &length); err != 0 {
 			f.e = Error{int(err)}
@@ -165,6 +366,13 @@ func (f *futureByteSlice) Get() ([]byte, error) {
 	return f.v, f.e
 }
 
+func (f *futureByteSlice) GetContext(ctx context.Context) ([]byte, error) {
+	if e := f.blockUntilReadyContext(ctx); e != nil {
+		return nil, e
+	}
+	return f.Get()
+}
+
 func (f *futureByteSlice) MustGet() []byte {
 	val, err := f.Get()
 	if err != nil {
@@ -187,6 +395,11 @@ type FutureKey interface {
 	// goroutine will be blocked until the future is ready.
 	MustGet() Key
 
+	// GetContext is like Get, but returns ctx.Err() if ctx is done before the
+	// future is ready. In that case the future (and its associated
+	// asynchronous operation) is cancelled.
+	GetContext(ctx context.Context) (Key, error)
+
 	Future
 }
 
@@ -204,7 +417,10 @@ func (f *futureKey) Get() (Key, error) {
 		var value *C.uint8_t
 		var length C.int
 
-		f.BlockUntilReady()
+		if e := f.wait(); e != nil {
+			f.e = e
+			return
+		}
 
 		if err := C.fdb_future_get_key(f.ptr, &value, &length); err != 0 {
 			f.e = Error{int(err)}
@@ -218,6 +434,13 @@ func (f *futureKey) Get() (Key, error) {
 	return f.k, f.e
 }
 
+func (f *futureKey) GetContext(ctx context.Context) (Key, error) {
+	if e := f.blockUntilReadyContext(ctx); e != nil {
+		return nil, e
+	}
+	return f.Get()
+}
+
 func (f *futureKey) MustGet() Key {
 	val, err := f.Get()
 	if err != nil {
@@ -240,6 +463,11 @@ type FutureNil interface {
 	// until the future is ready.
 	MustGet()
 
+	// GetContext is like Get, but returns ctx.Err() if ctx is done before the
+	// future is ready. In that case the future (and its associated
+	// asynchronous operation) is cancelled.
+	GetContext(ctx context.Context) error
+
 	Future
 }
 
@@ -250,7 +478,9 @@ type futureNil struct {
 func (f *futureNil) Get() error {
 	defer runtime.KeepAlive(f.future)
 
-	f.BlockUntilReady()
+	if e := f.wait(); e != nil {
+		return e
+	}
 	if err := C.fdb_future_get_error(f.ptr); err != 0 {
 		return Error{int(err)}
 	}
@@ -258,6 +488,13 @@ func (f *futureNil) Get() error {
 	return nil
 }
 
+func (f *futureNil) GetContext(ctx context.Context) error {
+	if e := f.blockUntilReadyContext(ctx); e != nil {
+		return e
+	}
+	return f.Get()
+}
+
 func (f *futureNil) MustGet() {
 	if err := f.Get(); err != nil {
 		panic(err)
@@ -283,7 +520,9 @@ func stringRefToSlice(ptr unsafe.Pointer) []byte {
 func (f *futureKeyValueArray) Get() ([]KeyValue, bool, error) {
 	defer runtime.KeepAlive(f.future)
 
-	f.BlockUntilReady()
+	if e := f.wait(); e != nil {
+		return nil, false, e
+	}
 
 	var kvs *C.FDBKeyValue
 	var count C.int
@@ -319,6 +558,11 @@ type FutureInt64 interface {
 	// current goroutine will be blocked until the future is ready.
 	MustGet() int64
 
+	// GetContext is like Get, but returns ctx.Err() if ctx is done before the
+	// future is ready. In that case the future (and its associated
+	// asynchronous operation) is cancelled.
+	GetContext(ctx context.Context) (int64, error)
+
 	Future
 }
 
@@ -329,7 +573,9 @@ type futureInt64 struct {
 func (f *futureInt64) Get() (int64, error) {
 	defer runtime.KeepAlive(f.future)
 
-	f.BlockUntilReady()
+	if e := f.wait(); e != nil {
+		return 0, e
+	}
 
 	var ver C.int64_t
 	if err := C.fdb_future_get_int64(f.ptr, &ver); err != 0 {
@@ -339,6 +585,13 @@ func (f *futureInt64) Get() (int64, error) {
 	return int64(ver), nil
 }
 
+func (f *futureInt64) GetContext(ctx context.Context) (int64, error) {
+	if e := f.blockUntilReadyContext(ctx); e != nil {
+		return 0, e
+	}
+	return f.Get()
+}
+
 func (f *futureInt64) MustGet() int64 {
 	val, err := f.Get()
 	if err != nil {
@@ -362,6 +615,11 @@ type FutureStringSlice interface {
 	// current goroutine will be blocked until the future is ready.
 	MustGet() []string
 
+	// GetContext is like Get, but returns ctx.Err() if ctx is done before the
+	// future is ready. In that case the future (and its associated
+	// asynchronous operation) is cancelled.
+	GetContext(ctx context.Context) ([]string, error)
+
 	Future
 }
 
@@ -372,7 +630,9 @@ type futureStringSlice struct {
 func (f *futureStringSlice) Get() ([]string, error) {
 	defer runtime.KeepAlive(f.future)
 
-	f.BlockUntilReady()
+	if e := f.wait(); e != nil {
+		return nil, e
+	}
 
 	var strings **C.char
 	var count C.int
@@ -390,6 +650,13 @@ func (f *futureStringSlice) Get() ([]string, error) {
 	return ret, nil
 }
 
+func (f *futureStringSlice) GetContext(ctx context.Context) ([]string, error) {
+	if e := f.blockUntilReadyContext(ctx); e != nil {
+		return nil, e
+	}
+	return f.Get()
+}
+
 func (f *futureStringSlice) MustGet() []string {
 	val, err := f.Get()
 	if err != nil {
@@ -0,0 +1,195 @@
+/*
+ * idempotency.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"time"
+)
+
+// Idempotency IDs are stored as
+//
+//	prefix + 8-byte big-endian creation time (Unix nanoseconds) + 16 random bytes
+//
+// so that all IDs created before a given time form a single contiguous range
+// which can be cleared in one operation. The value of an ID is the 4-byte
+// big-endian number of the attempt which wrote it.
+const (
+	idempotencyTimeLen = 8
+	idempotencyRandLen = 16
+)
+
+// WithIdempotency returns a copy of d whose Transact and TransactContext
+// methods commit each transaction at most once, even if a commit fails with an
+// error (such as commit_unknown_result) indicating that it may have succeeded.
+// The original Database is unaffected.
+//
+// Each call to Transact is assigned a unique ID, which is written under prefix
+// as part of every commit attempt. If an attempt fails with an error for which
+// (Error).MaybeCommitted is true, the next attempt first reads the ID back. If
+// it is present, the earlier attempt was in fact committed, and Transact
+// returns the value produced by that attempt without calling the function
+// again. This makes it safe to retry non-idempotent mutations such as Add, at
+// the cost of one additional key written per transaction.
+//
+// prefix should be a range of keys (for example, a subspace) reserved for this
+// purpose. IDs accumulate until removed by PurgeIdempotencyIDs or
+// CollectIdempotencyIDs. A nil prefix disables idempotency.
+//
+// ReadTransact and ReadTransactContext are not affected.
+func (d Database) WithIdempotency(prefix KeyConvertible) Database {
+	var k Key
+	if prefix != nil {
+		k = append(Key{}, prefix.FDBKey()...)
+	}
+	return d.withConfig(func(c *databaseConfig) {
+		c.idempotencyPrefix = k
+	})
+}
+
+func (d Database) idempotencyPrefix() Key {
+	if d.config == nil {
+		return nil
+	}
+	return d.config.idempotencyPrefix
+}
+
+// PurgeIdempotencyIDs removes all idempotency IDs stored under prefix by
+// transactions that started before the provided time.
+//
+// IDs are timestamped with the local clock of the client that created them,
+// so before should allow generously for clock skew between clients as well as
+// for the longest time a transaction may spend retrying. Removing the ID of a
+// transaction that is still retrying forfeits its exactly-once guarantee.
+func (d Database) PurgeIdempotencyIDs(prefix KeyConvertible, before time.Time) error {
+	p := prefix.FDBKey()
+	end := make(Key, len(p)+idempotencyTimeLen)
+	copy(end, p)
+	binary.BigEndian.PutUint64(end[len(p):], uint64(before.UnixNano()))
+
+	_, e := d.Transact(func(tr Transaction) (interface{}, error) {
+		tr.ClearRange(KeyRange{p, end})
+		return nil, nil
+	})
+	return e
+}
+
+// CollectIdempotencyIDs calls PurgeIdempotencyIDs every interval to remove
+// idempotency IDs older than maxAge, until ctx is done. It is intended to be
+// run in its own goroutine:
+//
+//	go db.CollectIdempotencyIDs(ctx, prefix, time.Hour, time.Minute)
+//
+// CollectIdempotencyIDs returns ctx.Err() once ctx is done, or the first
+// error returned by PurgeIdempotencyIDs.
+func (d Database) CollectIdempotencyIDs(ctx context.Context, prefix KeyConvertible, maxAge, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if e := d.PurgeIdempotencyIDs(prefix, time.Now().Add(-maxAge)); e != nil {
+			return e
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// idempotentCommit tracks the state of a single idempotent call to
+// (Database).Transact across its attempts.
+type idempotentCommit struct {
+	key Key
+
+	// attempt is the number of the latest attempt to commit, and pending the
+	// result of the function in that attempt.
+	attempt uint32
+	pending interface{}
+
+	// rets holds the result of the function in each attempt which failed with
+	// an error that does not rule out that it was committed, by attempt
+	// number. Any of those attempts may be the one that took effect.
+	rets map[uint32]interface{}
+}
+
+// newIdempotentCommit returns a new ID for a call to Transact, or nil if
+// idempotency is not enabled.
+func (d Database) newIdempotentCommit() (*idempotentCommit, error) {
+	prefix := d.idempotencyPrefix()
+	if prefix == nil {
+		return nil, nil
+	}
+
+	key := make(Key, len(prefix)+idempotencyTimeLen+idempotencyRandLen)
+	copy(key, prefix)
+	binary.BigEndian.PutUint64(key[len(prefix):], uint64(time.Now().UnixNano()))
+	if _, e := rand.Read(key[len(prefix)+idempotencyTimeLen:]); e != nil {
+		return nil, e
+	}
+
+	return &idempotentCommit{key: key}, nil
+}
+
+// committed reports whether an earlier attempt has been committed, and if so
+// returns the value produced by the function in that attempt (identified by
+// the value of the ID). Reading the ID adds it to the read conflict ranges of
+// tr, so an earlier commit that lands after the read version of tr will cause
+// tr to conflict rather than be committed a second time.
+func (c *idempotentCommit) committed(tr ReadWriter) (interface{}, bool) {
+	if len(c.rets) == 0 {
+		return nil, false
+	}
+	v := tr.Get(c.key).MustGet()
+	if v == nil {
+		return nil, false
+	}
+	if len(v) != 4 {
+		return nil, true
+	}
+	return c.rets[binary.BigEndian.Uint32(v)], true
+}
+
+// prepare records the ID in tr prior to its commit.
+func (c *idempotentCommit) prepare(tr ReadWriter, ret interface{}) {
+	c.attempt++
+	tr.Set(c.key, binary.BigEndian.AppendUint32(nil, c.attempt))
+	c.pending = ret
+}
+
+// observe records the outcome of the commit of the latest attempt, which
+// failed with an error for which (Error).MaybeCommitted was true if
+// maybeCommitted is set.
+func (c *idempotentCommit) observe(maybeCommitted bool) {
+	if maybeCommitted {
+		if c.rets == nil {
+			c.rets = make(map[uint32]interface{})
+		}
+		c.rets[c.attempt] = c.pending
+	}
+	c.pending = nil
+}
@@ -0,0 +1,138 @@
+/*
+ * log.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+var defaultLogger atomic.Pointer[slog.Logger]
+
+// SetLogger sets the logger to which the fdb package reports events that are
+// not associated with a Database (such as the network starting and stopping),
+// and the events of every Database which has no logger of its own (see
+// (Database).WithLogger). A nil l restores the initial logger, slog.Default().
+//
+// The starting and stopping of the network, and the opening of databases, are
+// logged at level Debug, so that they are not reported by the default logger.
+// Failures of the network thread are logged at level Error.
+func SetLogger(l *slog.Logger) {
+	defaultLogger.Store(l)
+}
+
+func logger() *slog.Logger {
+	if l := defaultLogger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// WithLogger returns a copy of d which reports its events to l rather than
+// the logger set by SetLogger. A nil l restores that logger.
+//
+// A Database logs each retried attempt of its transactions (at level Debug,
+// with the code of the error), failures to set the options of its transactions
+// (at level Warn), and transactions which exceed the thresholds set by
+// WithSlowTransactionLog (at level Warn).
+func (d Database) WithLogger(l *slog.Logger) Database {
+	return d.withConfig(func(c *databaseConfig) {
+		c.logger = l
+	})
+}
+
+// WithSlowTransactionLog returns a copy of d which logs, at level Warn, every
+// transaction run by Transact and related methods which takes longer than
+// latency (including retries), or whose approximate size when committed is
+// more than size bytes. A zero latency or size disables that threshold.
+func (d Database) WithSlowTransactionLog(latency time.Duration, size int64) Database {
+	return d.withConfig(func(c *databaseConfig) {
+		c.slowLatency = latency
+		c.slowSize = size
+	})
+}
+
+func (d Database) logger() *slog.Logger {
+	if d.config != nil && d.config.logger != nil {
+		return d.config.logger
+	}
+	return logger()
+}
+
+func (d Database) slowThresholds() (time.Duration, int64) {
+	if d.config == nil {
+		return 0, 0
+	}
+	return d.config.slowLatency, d.config.slowSize
+}
+
+// logRetry logs that a transaction of d is being retried after attempt failed
+// with e.
+func (d Database) logRetry(ctx context.Context, e Error, attempt int) {
+	l := d.logger()
+	if !l.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	l.LogAttrs(ctx, slog.LevelDebug, "fdb transaction retry",
+		slog.String("name", d.Name()),
+		slog.Int("attempt", attempt),
+		slog.Int("code", e.Code),
+		slog.String("error", e.Error()),
+	)
+}
+
+// logSlowTransaction logs stats if they exceed the thresholds of d.
+func (d Database) logSlowTransaction(stats TransactionStats) {
+	latency, size := d.slowThresholds()
+
+	var bytes int64
+	if n := len(stats.Attempts); n > 0 {
+		bytes = stats.Attempts[n-1].Bytes
+	}
+	if (latency <= 0 || stats.Duration <= latency) && (size <= 0 || bytes <= size) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("name", d.Name()),
+		slog.Duration("duration", stats.Duration),
+		slog.Int64("bytes", bytes),
+		slog.Int("attempts", len(stats.Attempts)),
+		slog.Bool("read_only", stats.ReadOnly),
+	}
+	if stats.Err != nil {
+		attrs = append(attrs, slog.String("error", stats.Err.Error()))
+	}
+	d.logger().LogAttrs(context.Background(), slog.LevelWarn, "fdb slow transaction", attrs...)
+}
+
+// logOptionError logs the failure of an option to be set.
+func logOptionError(l *slog.Logger, scope string, code int, e error) {
+	l.LogAttrs(context.Background(), slog.LevelWarn, "fdb option failed",
+		slog.String("scope", scope),
+		slog.Int("option", code),
+		slog.String("error", e.Error()),
+	)
+}
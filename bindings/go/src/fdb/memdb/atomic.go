@@ -0,0 +1,160 @@
+/*
+ * atomic.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go In-Memory Database
+
+package memdb
+
+import (
+	"bytes"
+)
+
+// The semantics of the atomic operations follow fdbclient/Atomic.h (for API
+// version 510 and later). A nil value represents a key which is not present.
+
+// atomicFunc computes the new value of a key from its existing value and the
+// parameter of an atomic operation.
+type atomicFunc func(existing, param []byte) []byte
+
+func doAdd(existing, param []byte) []byte {
+	if len(existing) == 0 || len(param) == 0 {
+		return param
+	}
+	ret := make([]byte, len(param))
+	carry := 0
+	for i := range param {
+		sum := int(param[i]) + carry
+		if i < len(existing) {
+			sum += int(existing[i])
+		}
+		ret[i] = byte(sum)
+		carry = sum >> 8
+	}
+	return ret
+}
+
+func doBitAnd(existing, param []byte) []byte {
+	if existing == nil || len(param) == 0 {
+		return param
+	}
+	ret := make([]byte, len(param))
+	for i := 0; i < len(param) && i < len(existing); i++ {
+		ret[i] = existing[i] & param[i]
+	}
+	return ret
+}
+
+func doBitOr(existing, param []byte) []byte {
+	if len(existing) == 0 || len(param) == 0 {
+		return param
+	}
+	ret := append([]byte{}, param...)
+	for i := 0; i < len(param) && i < len(existing); i++ {
+		ret[i] = existing[i] | param[i]
+	}
+	return ret
+}
+
+func doBitXor(existing, param []byte) []byte {
+	if len(existing) == 0 || len(param) == 0 {
+		return param
+	}
+	ret := append([]byte{}, param...)
+	for i := 0; i < len(param) && i < len(existing); i++ {
+		ret[i] = existing[i] ^ param[i]
+	}
+	return ret
+}
+
+func doAppendIfFits(existing, param []byte) []byte {
+	if len(existing) == 0 {
+		return param
+	}
+	if len(param) == 0 || len(existing)+len(param) > valueSizeLimit {
+		return existing
+	}
+	return append(append([]byte{}, existing...), param...)
+}
+
+// compareLittleEndian compares existing (truncated or zero-extended to the
+// length of param) with param, both interpreted as little-endian unsigned
+// integers.
+func compareLittleEndian(existing, param []byte) int {
+	for i := len(param) - 1; i >= 0; i-- {
+		var b byte
+		if i < len(existing) {
+			b = existing[i]
+		}
+		if b < param[i] {
+			return -1
+		} else if b > param[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// resize returns existing truncated or zero-extended to n bytes.
+func resize(existing []byte, n int) []byte {
+	ret := make([]byte, n)
+	copy(ret, existing)
+	return ret
+}
+
+func doMax(existing, param []byte) []byte {
+	if len(existing) == 0 || len(param) == 0 {
+		return param
+	}
+	if compareLittleEndian(existing, param) > 0 {
+		return resize(existing, len(param))
+	}
+	return param
+}
+
+func doMin(existing, param []byte) []byte {
+	if existing == nil || len(param) == 0 {
+		return param
+	}
+	if compareLittleEndian(existing, param) < 0 {
+		return resize(existing, len(param))
+	}
+	return param
+}
+
+func doByteMax(existing, param []byte) []byte {
+	if existing == nil || bytes.Compare(existing, param) <= 0 {
+		return param
+	}
+	return existing
+}
+
+func doByteMin(existing, param []byte) []byte {
+	if existing == nil || bytes.Compare(existing, param) >= 0 {
+		return param
+	}
+	return existing
+}
+
+func doCompareAndClear(existing, param []byte) []byte {
+	if existing == nil || bytes.Equal(existing, param) {
+		return nil
+	}
+	return existing
+}
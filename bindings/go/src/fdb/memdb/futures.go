@@ -0,0 +1,137 @@
+/*
+ * futures.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go In-Memory Database
+
+package memdb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+)
+
+// future implements the fdb future interfaces for values computed in memory.
+// Most operations complete immediately, so their futures are created ready;
+// others (such as the versionstamp of a transaction) are set later.
+type future[T any] struct {
+	ready chan struct{}
+	once  sync.Once
+	v     T
+	e     error
+}
+
+func newFuture[T any]() *future[T] {
+	return &future[T]{ready: make(chan struct{})}
+}
+
+func readyFuture[T any](v T, e error) *future[T] {
+	f := newFuture[T]()
+	f.set(v, e)
+	return f
+}
+
+// set makes the future ready with the provided value and error. Only the first
+// call has any effect.
+func (f *future[T]) set(v T, e error) {
+	f.once.Do(func() {
+		f.v, f.e = v, e
+		close(f.ready)
+	})
+}
+
+func (f *future[T]) Get() (T, error) {
+	<-f.ready
+	return f.v, f.e
+}
+
+func (f *future[T]) GetContext(ctx context.Context) (T, error) {
+	select {
+	case <-f.ready:
+	case <-ctx.Done():
+		f.Cancel()
+		var zero T
+		return zero, ctx.Err()
+	}
+	return f.Get()
+}
+
+func (f *future[T]) MustGet() T {
+	v, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return v
+}
+
+func (f *future[T]) BlockUntilReady() {
+	<-f.ready
+}
+
+func (f *future[T]) IsReady() bool {
+	select {
+	case <-f.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *future[T]) Ready() <-chan struct{} {
+	return f.ready
+}
+
+func (f *future[T]) Cancel() {
+	var zero T
+	f.set(zero, fdb.ErrOperationCancelled)
+}
+
+// futureNil adapts a future with no value to the fdb.FutureNil interface.
+type futureNil struct {
+	*future[struct{}]
+}
+
+func readyFutureNil(e error) futureNil {
+	return futureNil{readyFuture(struct{}{}, e)}
+}
+
+func (f futureNil) Get() error {
+	_, e := f.future.Get()
+	return e
+}
+
+func (f futureNil) GetContext(ctx context.Context) error {
+	_, e := f.future.GetContext(ctx)
+	return e
+}
+
+func (f futureNil) MustGet() {
+	if e := f.Get(); e != nil {
+		panic(e)
+	}
+}
+
+var (
+	_ fdb.FutureByteSlice = (*future[[]byte])(nil)
+	_ fdb.FutureKey       = (*future[fdb.Key])(nil)
+	_ fdb.FutureInt64     = (*future[int64])(nil)
+	_ fdb.FutureNil       = futureNil{}
+)
@@ -0,0 +1,351 @@
+/*
+ * memdb.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go In-Memory Database
+
+// Package memdb provides an in-memory implementation of the FoundationDB
+// transaction API, written in Go, for use in unit tests.
+//
+// A memdb Database behaves like a single FoundationDB database: transactions
+// have serializable isolation (with optimistic conflict detection between
+// concurrent transactions), see their own writes, and support snapshot reads,
+// key selectors, range reads with all RangeOptions, atomic operations and
+// versionstamps. Reads return the same Future and RangeResult types as the fdb
+// package, and Database, Transaction and Snapshot satisfy the fdb.Reader,
+// fdb.ReadWriter and related transactor interfaces, so memdb may be used with
+// libraries (such as the directory package) written against them.
+//
+// memdb does not satisfy fdb.Transactor or fdb.ReadTransactor, whose
+// transactional functions are passed an fdb.Transaction: that is a handle to a
+// transaction of the C library, which memdb cannot provide. Code which is to
+// be tested with memdb should accept an fdb.ReadWriterTransactor or
+// fdb.ReaderTransactor instead.
+//
+// memdb does not need a FoundationDB cluster, does not start the FoundationDB
+// network thread, and does not require fdb.APIVersion to be called. (The fdb
+// package itself, which memdb uses for its types, must still be linked
+// against the C library.) Data is not persisted and is lost when the Database
+// is garbage collected.
+//
+// Versionstamped keys and values use the 4-byte offset format of API version
+// 520 and later. When they are built with tuple.PackWithVersionstamp, an API
+// version of at least 520 must therefore have been selected.
+package memdb
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+)
+
+// Limits matching those enforced by FoundationDB.
+const (
+	keySizeLimit         = 10000
+	valueSizeLimit       = 100000
+	transactionSizeLimit = 10000000
+
+	// mvccWindow is how long after being committed a version remains
+	// readable, and how long committed writes are retained for conflict
+	// detection. Transactions with an older read version fail with
+	// transaction_too_old.
+	mvccWindow = 5 * time.Second
+)
+
+// Error codes used by memdb which are not exported by the fdb package.
+var (
+	errKeyOutsideLegalRange = fdb.Error{Code: 2004}
+	errInvertedRange        = fdb.Error{Code: 2005}
+	errNoCommitVersion      = fdb.Error{Code: 2021}
+	errAccessedUnreadable   = fdb.Error{Code: 1036}
+)
+
+// maxKey is the end of the user keyspace; keys beginning with 0xFF are
+// reserved for the system and may not be read or written.
+var maxKey = fdb.Key{0xFF}
+
+// Database is an in-memory FoundationDB database. Database is a lightweight
+// object that may be efficiently copied, and is safe for concurrent use by
+// multiple goroutines.
+type Database struct {
+	*database
+}
+
+type database struct {
+	mu sync.Mutex
+
+	// version is the version of the most recent commit.
+	version int64
+
+	// keys holds, in sorted order, every key with an entry in history.
+	keys []string
+
+	// history holds the values of each key, ordered by version. A nil value
+	// records that the key was cleared.
+	history map[string][]entry
+
+	// commits holds the write conflict ranges of recent commits, ordered by
+	// version, for conflict detection.
+	commits []commitRecord
+
+	// oldest is the oldest version which may still be read.
+	oldest int64
+
+	// watches holds the watches which have not yet reported a change.
+	watches []*watch
+}
+
+type entry struct {
+	version int64
+	value   []byte
+}
+
+type commitRecord struct {
+	version int64
+	at      time.Time
+	writes  []keyRange
+}
+
+// keyRange is a half-open range of keys [begin, end).
+type keyRange struct {
+	begin, end string
+}
+
+func (r keyRange) intersects(o keyRange) bool {
+	return r.begin < o.end && o.begin < r.end
+}
+
+func (r keyRange) contains(key string) bool {
+	return r.begin <= key && key < r.end
+}
+
+// keyAfter returns the first key sorting after key.
+func keyAfter(key string) string {
+	return key + "\x00"
+}
+
+// New returns a new, empty in-memory database.
+func New() Database {
+	return Database{&database{
+		version: 1,
+		oldest:  1,
+		history: make(map[string][]entry),
+	}}
+}
+
+// CreateTransaction returns a new transaction on the database. It is generally
+// preferable to use the (Database).Transact method, which handles
+// automatically creating and committing a transaction with appropriate retry
+// behavior.
+func (d Database) CreateTransaction() (Transaction, error) {
+	t := &transaction{db: d.database}
+	t.reset()
+	return Transaction{t}, nil
+}
+
+// Transact runs a caller-provided function inside a retry loop, providing it
+// with a newly created Transaction, and commits the Transaction after the
+// function returns, in the same way as (fdb.Database).Transact. A panicked
+// fdb.Error is recovered and either retried or returned.
+func (d Database) Transact(f func(Transaction) (interface{}, error)) (interface{}, error) {
+	tr, e := d.CreateTransaction()
+	if e != nil {
+		return nil, e
+	}
+
+	wrapped := func() (ret interface{}, e error) {
+		defer panicToError(&e)
+
+		ret, e = f(tr)
+
+		if e == nil {
+			e = tr.Commit().Get()
+		}
+
+		return
+	}
+
+	return retryable(wrapped, tr.OnError)
+}
+
+// ReadTransact runs a caller-provided function inside a retry loop, providing
+// it with a newly created Transaction (as a ReadTransaction), in the same way
+// as (fdb.Database).ReadTransact.
+func (d Database) ReadTransact(f func(ReadTransaction) (interface{}, error)) (interface{}, error) {
+	return d.Transact(func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	})
+}
+
+// TransactReadWriter is equivalent to Transact, providing the caller-provided
+// function with the Transaction as an fdb.ReadWriter. It allows a memdb
+// Database to be used wherever an fdb.ReadWriterTransactor is accepted.
+func (d Database) TransactReadWriter(f func(fdb.ReadWriter) (interface{}, error)) (interface{}, error) {
+	return d.Transact(func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	})
+}
+
+// ReadTransactReader is equivalent to ReadTransact, providing the
+// caller-provided function with the Transaction as an fdb.Reader. It allows a
+// memdb Database to be used wherever an fdb.ReaderTransactor is accepted.
+func (d Database) ReadTransactReader(f func(fdb.Reader) (interface{}, error)) (interface{}, error) {
+	return d.Transact(func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	})
+}
+
+var (
+	_ fdb.ReadWriter           = Transaction{}
+	_ fdb.Reader               = Snapshot{}
+	_ fdb.ReadWriterTransactor = Database{}
+)
+
+func retryable(wrapped func() (interface{}, error), onError func(fdb.Error) fdb.FutureNil) (ret interface{}, e error) {
+	for {
+		ret, e = wrapped()
+
+		// No error means success!
+		if e == nil {
+			return
+		}
+
+		ep, ok := e.(fdb.Error)
+		if ok {
+			e = onError(ep).Get()
+		}
+
+		// If OnError returns an error, then it's not
+		// retryable; otherwise take another pass at things
+		if e != nil {
+			return
+		}
+	}
+}
+
+func panicToError(e *error) {
+	if r := recover(); r != nil {
+		fe, ok := r.(fdb.Error)
+		if ok {
+			*e = fe
+		} else {
+			panic(r)
+		}
+	}
+}
+
+// valueAt returns the value of key as of version, or nil if it is not present.
+// The caller must hold d.mu.
+func (d *database) valueAt(key string, version int64) []byte {
+	h := d.history[key]
+	i := sort.Search(len(h), func(i int) bool { return h[i].version > version })
+	if i == 0 {
+		return nil
+	}
+	return h[i-1].value
+}
+
+// keysIn returns the keys with any history in [begin, end). The caller must
+// hold d.mu.
+func (d *database) keysIn(r keyRange) []string {
+	lo := sort.SearchStrings(d.keys, r.begin)
+	hi := sort.SearchStrings(d.keys, r.end)
+	return d.keys[lo:hi]
+}
+
+// write records the value of key as of version, which must be no older than
+// any version already recorded. The caller must hold d.mu.
+func (d *database) write(key string, value []byte, version int64) {
+	h, ok := d.history[key]
+	if !ok {
+		i := sort.SearchStrings(d.keys, key)
+		d.keys = append(d.keys, "")
+		copy(d.keys[i+1:], d.keys[i:])
+		d.keys[i] = key
+	}
+	if n := len(h); n > 0 && h[n-1].version == version {
+		h[n-1].value = value
+	} else {
+		h = append(h, entry{version, value})
+	}
+	d.history[key] = h
+}
+
+// checkConflicts returns an error if a transaction with the provided read
+// version and read conflict ranges may not be committed. The caller must hold
+// d.mu.
+func (d *database) checkConflicts(readVersion int64, reads []keyRange) error {
+	if readVersion < d.oldest {
+		return fdb.ErrTransactionTooOld
+	}
+	i := sort.Search(len(d.commits), func(i int) bool { return d.commits[i].version > readVersion })
+	for _, c := range d.commits[i:] {
+		for _, w := range c.writes {
+			for _, r := range reads {
+				if w.intersects(r) {
+					return fdb.ErrNotCommitted
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// prune discards history which can no longer be read and commits which can no
+// longer cause conflicts. The caller must hold d.mu.
+func (d *database) prune(now time.Time) {
+	i := 0
+	for i < len(d.commits) && now.Sub(d.commits[i].at) > mvccWindow {
+		i++
+	}
+	if i == 0 {
+		return
+	}
+	d.oldest = d.commits[i-1].version
+	d.commits = append([]commitRecord{}, d.commits[i:]...)
+
+	keys := d.keys[:0]
+	for _, k := range d.keys {
+		h := d.history[k]
+		j := sort.Search(len(h), func(j int) bool { return h[j].version > d.oldest })
+		if j > 1 {
+			h = append([]entry{}, h[j-1:]...)
+		}
+		if len(h) == 1 && h[0].value == nil && h[0].version <= d.oldest {
+			delete(d.history, k)
+			continue
+		}
+		d.history[k] = h
+		keys = append(keys, k)
+	}
+	d.keys = keys
+}
+
+func checkKey(key []byte) error {
+	if bytes.Compare(key, maxKey) >= 0 {
+		return errKeyOutsideLegalRange
+	}
+	if len(key) > keySizeLimit {
+		return fdb.ErrKeyTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,455 @@
+/*
+ * memdb_test.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go In-Memory Database
+
+package memdb_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/directory"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/memdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/subspace"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+)
+
+func set(t *testing.T, db memdb.Database, kvs ...string) {
+	t.Helper()
+	_, e := db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+		for i := 0; i < len(kvs); i += 2 {
+			tr.Set(fdb.Key(kvs[i]), []byte(kvs[i+1]))
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+}
+
+func keys(kvs []fdb.KeyValue) string {
+	var b bytes.Buffer
+	for i, kv := range kvs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.Write(kv.Key)
+	}
+	return b.String()
+}
+
+func int64Bytes(v int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+func TestReadYourWrites(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "a", "1", "b", "2", "c", "3", "d", "4")
+
+	tr, _ := db.CreateTransaction()
+	tr.Set(fdb.Key("b"), []byte("two"))
+	tr.Clear(fdb.Key("c"))
+	tr.ClearRange(fdb.KeyRange{Begin: fdb.Key("d"), End: fdb.Key("e")})
+	tr.Set(fdb.Key("bb"), []byte("22"))
+
+	if v := tr.Get(fdb.Key("b")).MustGet(); string(v) != "two" {
+		t.Errorf("Get(b) = %q, want \"two\"", v)
+	}
+	if v := tr.Get(fdb.Key("c")).MustGet(); v != nil {
+		t.Errorf("Get(c) = %q, want nil", v)
+	}
+	kvs := tr.GetRange(fdb.KeyRange{Begin: fdb.Key(""), End: fdb.Key{0xFF}}, fdb.RangeOptions{}).GetSliceOrPanic()
+	if got := keys(kvs); got != "a b bb" {
+		t.Errorf("GetRange = %q, want \"a b bb\"", got)
+	}
+
+	// Nothing is visible to other transactions until commit
+	other, _ := db.CreateTransaction()
+	if v := other.Get(fdb.Key("bb")).MustGet(); v != nil {
+		t.Errorf("uncommitted write visible to another transaction: %q", v)
+	}
+
+	if e := tr.Commit().Get(); e != nil {
+		t.Fatal(e)
+	}
+	after, _ := db.CreateTransaction()
+	kvs = after.GetRange(fdb.KeyRange{Begin: fdb.Key(""), End: fdb.Key{0xFF}}, fdb.RangeOptions{}).GetSliceOrPanic()
+	if got := keys(kvs); got != "a b bb" {
+		t.Errorf("GetRange after commit = %q, want \"a b bb\"", got)
+	}
+}
+
+func TestGetKey(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "b", "", "d", "", "f", "")
+
+	tr, _ := db.CreateTransaction()
+	cases := []struct {
+		sel    fdb.Selectable
+		expect string
+	}{
+		{fdb.FirstGreaterOrEqual(fdb.Key("d")), "d"},
+		{fdb.FirstGreaterOrEqual(fdb.Key("c")), "d"},
+		{fdb.FirstGreaterThan(fdb.Key("d")), "f"},
+		{fdb.LastLessThan(fdb.Key("d")), "b"},
+		{fdb.LastLessOrEqual(fdb.Key("d")), "d"},
+		{fdb.LastLessThan(fdb.Key("b")), ""},
+		{fdb.FirstGreaterThan(fdb.Key("f")), "\xff"},
+		{fdb.KeySelector{Key: fdb.Key("b"), OrEqual: false, Offset: 3}, "f"},
+	}
+	for i, c := range cases {
+		if k := tr.GetKey(c.sel).MustGet(); string(k) != c.expect {
+			t.Errorf("case %d: GetKey = %q, want %q", i, k, c.expect)
+		}
+	}
+}
+
+func TestGetKeyOverWrites(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "b", "", "d", "", "f", "", "h", "")
+
+	// The transaction's writes and clears interleave with the keys in the
+	// database: it sees c d e h
+	tr, _ := db.CreateTransaction()
+	tr.Set(fdb.Key("c"), nil)
+	tr.Set(fdb.Key("e"), nil)
+	tr.Clear(fdb.Key("b"))
+	tr.ClearRange(fdb.KeyRange{Begin: fdb.Key("f"), End: fdb.Key("g")})
+
+	cases := []struct {
+		sel    fdb.Selectable
+		expect string
+	}{
+		{fdb.FirstGreaterOrEqual(fdb.Key("a")), "c"},
+		{fdb.FirstGreaterThan(fdb.Key("d")), "e"},
+		{fdb.KeySelector{Key: fdb.Key("c"), OrEqual: false, Offset: 4}, "h"},
+		{fdb.KeySelector{Key: fdb.Key("c"), OrEqual: false, Offset: 5}, "\xff"},
+		{fdb.LastLessThan(fdb.Key("g")), "e"},
+		{fdb.KeySelector{Key: fdb.Key("h"), OrEqual: true, Offset: -2}, "d"},
+		{fdb.KeySelector{Key: fdb.Key("h"), OrEqual: true, Offset: -4}, ""},
+	}
+	for i, c := range cases {
+		if k := tr.GetKey(c.sel).MustGet(); string(k) != c.expect {
+			t.Errorf("case %d: GetKey = %q, want %q", i, k, c.expect)
+		}
+	}
+
+	all := fdb.KeyRange{Begin: fdb.Key(""), End: fdb.Key{0xFF}}
+	if got := tr.GetRange(all, fdb.RangeOptions{Reverse: true}).GetSliceOrPanic(); keys(got) != "h e d c" {
+		t.Errorf("reverse GetRange = %q, want \"h e d c\"", keys(got))
+	}
+	if got := tr.GetRange(all, fdb.RangeOptions{Limit: 2, Reverse: true}).GetSliceOrPanic(); keys(got) != "h e" {
+		t.Errorf("reverse GetRange with limit = %q, want \"h e\"", keys(got))
+	}
+}
+
+func TestGetRangeOptions(t *testing.T) {
+	db := memdb.New()
+	var kvs []string
+	for i := 0; i < 100; i++ {
+		kvs = append(kvs, fmt.Sprintf("k%03d", i), "")
+	}
+	set(t, db, kvs...)
+
+	tr, _ := db.CreateTransaction()
+	pr, _ := fdb.PrefixRange([]byte("k"))
+
+	if got := tr.GetRange(pr, fdb.RangeOptions{Limit: 3, Reverse: true}).GetSliceOrPanic(); keys(got) != "k099 k098 k097" {
+		t.Errorf("reverse with limit = %q", keys(got))
+	}
+
+	// Iterating with the default streaming mode reads in several batches
+	n := 0
+	ri := tr.GetRange(pr, fdb.RangeOptions{Limit: 90}).Iterator()
+	for ri.Advance() {
+		kv := ri.MustGet()
+		if want := fmt.Sprintf("k%03d", n); string(kv.Key) != want {
+			t.Fatalf("iterator returned %q, want %q", kv.Key, want)
+		}
+		n++
+	}
+	if n != 90 {
+		t.Errorf("iterator returned %d key-value pairs, want 90", n)
+	}
+
+	sr := fdb.SelectorRange{Begin: fdb.FirstGreaterThan(fdb.Key("k010")), End: fdb.LastLessOrEqual(fdb.Key("k013"))}
+	if got := tr.GetRange(sr, fdb.RangeOptions{}).GetSliceOrPanic(); keys(got) != "k011 k012" {
+		t.Errorf("selector range = %q", keys(got))
+	}
+}
+
+func TestAtomicOps(t *testing.T) {
+	db := memdb.New()
+
+	_, e := db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+		tr.Add(fdb.Key("counter"), int64Bytes(5))
+		tr.Add(fdb.Key("counter"), int64Bytes(-2))
+		tr.Max(fdb.Key("max"), int64Bytes(3))
+		tr.Max(fdb.Key("max"), int64Bytes(7))
+		tr.Min(fdb.Key("max"), int64Bytes(6))
+		tr.ByteMin(fdb.Key("bytes"), []byte("b"))
+		tr.ByteMin(fdb.Key("bytes"), []byte("a"))
+		tr.BitOr(fdb.Key("bits"), []byte{0x01})
+		tr.BitXor(fdb.Key("bits"), []byte{0x03})
+		tr.AppendIfFits(fdb.Key("log"), []byte("x"))
+		tr.AppendIfFits(fdb.Key("log"), []byte("y"))
+
+		// Atomic operations are visible to reads in the same transaction
+		if v := tr.Get(fdb.Key("counter")).MustGet(); !bytes.Equal(v, int64Bytes(3)) {
+			t.Errorf("counter within transaction = %v, want 3", v)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// Operations on keys not yet read are applied to the committed value
+	_, e = db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+		tr.Add(fdb.Key("counter"), int64Bytes(10))
+		tr.CompareAndClear(fdb.Key("bytes"), []byte("a"))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tr, _ := db.CreateTransaction()
+	expect := map[string][]byte{
+		"counter": int64Bytes(13),
+		"max":     int64Bytes(6),
+		"bytes":   nil,
+		"bits":    {0x02},
+		"log":     []byte("xy"),
+	}
+	for k, want := range expect {
+		if v := tr.Get(fdb.Key(k)).MustGet(); !bytes.Equal(v, want) || (v == nil) != (want == nil) {
+			t.Errorf("%s = %v, want %v", k, v, want)
+		}
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "x", "0", "y", "0")
+
+	t1, _ := db.CreateTransaction()
+	t2, _ := db.CreateTransaction()
+	t3, _ := db.CreateTransaction()
+
+	t1.Get(fdb.Key("x")).MustGet()
+	t2.Get(fdb.Key("x")).MustGet()
+	t3.Snapshot().Get(fdb.Key("x")).MustGet()
+
+	t1.Set(fdb.Key("x"), []byte("1"))
+	t2.Set(fdb.Key("y"), []byte("2"))
+	t3.Set(fdb.Key("y"), []byte("3"))
+
+	if e := t1.Commit().Get(); e != nil {
+		t.Fatalf("first commit failed: %v", e)
+	}
+	if e := t2.Commit().Get(); e != fdb.ErrNotCommitted {
+		t.Errorf("conflicting commit returned %v, want not_committed", e)
+	}
+	if e := t3.Commit().Get(); e != nil {
+		t.Errorf("commit after snapshot read returned %v, want success", e)
+	}
+
+	// Transact retries after a conflict
+	attempts := 0
+	_, e := db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+		attempts++
+		tr.Get(fdb.Key("x")).MustGet()
+		if attempts == 1 {
+			set(t, db, "x", "interfering")
+		}
+		tr.Set(fdb.Key("y"), []byte("4"))
+		return nil, nil
+	})
+	if e != nil || attempts != 2 {
+		t.Errorf("Transact returned %v after %d attempts, want success after 2", e, attempts)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	db := memdb.New()
+	set(t, db, "w", "0")
+
+	tr, _ := db.CreateTransaction()
+	w := tr.Watch(fdb.Key("w"))
+	if w.IsReady() {
+		t.Fatal("watch ready before commit")
+	}
+	if e := tr.Commit().Get(); e != nil {
+		t.Fatal(e)
+	}
+
+	set(t, db, "w", "0")
+	if w.IsReady() {
+		t.Error("watch ready after the value was rewritten unchanged")
+	}
+	set(t, db, "w", "1")
+	if e := w.Get(); e != nil {
+		t.Errorf("watch returned %v after a change", e)
+	}
+
+	// Watches of transactions which do not commit fail
+	tr, _ = db.CreateTransaction()
+	w = tr.Watch(fdb.Key("w"))
+	tr.Reset()
+	if e := w.Get(); e != fdb.ErrTransactionCancelled {
+		t.Errorf("watch of reset transaction returned %v, want transaction_cancelled", e)
+	}
+
+	// A change made by the watching transaction is reported on commit
+	tr, _ = db.CreateTransaction()
+	w = tr.Watch(fdb.Key("w"))
+	tr.Clear(fdb.Key("w"))
+	if e := tr.Commit().Get(); e != nil {
+		t.Fatal(e)
+	}
+	if e := w.Get(); e != nil {
+		t.Errorf("watch returned %v after a change by its own transaction", e)
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	db := memdb.New()
+
+	dir, e := directory.CreateOrOpenReadWriter(db, []string{"app", "users"}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		tr.Set(dir.Pack(tuple.Tuple{"alice"}), []byte("1"))
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	opened, e := directory.OpenReader(db, []string{"app", "users"}, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !bytes.Equal(opened.Bytes(), dir.Bytes()) {
+		t.Errorf("opened directory has prefix %x, want %x", opened.Bytes(), dir.Bytes())
+	}
+
+	subdirs, e := directory.ListReader(db, []string{"app"})
+	if e != nil || len(subdirs) != 1 || subdirs[0] != "users" {
+		t.Errorf("List returned %v, %v", subdirs, e)
+	}
+
+	removed, e := directory.Root().(directory.ReadWriterDirectory).RemoveReadWriter(db, []string{"app"})
+	if e != nil || !removed {
+		t.Fatalf("Remove returned %v, %v", removed, e)
+	}
+	exists, e := directory.ExistsReader(db, []string{"app", "users"})
+	if e != nil || exists {
+		t.Errorf("Exists returned %v, %v after removal", exists, e)
+	}
+	v, e := db.ReadTransact(func(tr memdb.ReadTransaction) (interface{}, error) {
+		return tr.Get(dir.Pack(tuple.Tuple{"alice"})).Get()
+	})
+	if e != nil || v.([]byte) != nil {
+		t.Errorf("directory contents not removed: %q, %v", v, e)
+	}
+}
+
+func TestVersionstamps(t *testing.T) {
+	// Needed by tuple.PackWithVersionstamp to choose the offset format
+	fdb.MustAPIVersion(620)
+
+	db := memdb.New()
+	ss := subspace.Sub("log")
+
+	var vs fdb.FutureKey
+	_, e := db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+		k, e := tuple.Tuple{tuple.IncompleteVersionstamp(7)}.PackWithVersionstamp(ss.Bytes())
+		if e != nil {
+			return nil, e
+		}
+		tr.SetVersionstampedKey(fdb.Key(k), []byte("entry"))
+
+		v, e := tuple.Tuple{tuple.IncompleteVersionstamp(0)}.PackWithVersionstamp(nil)
+		if e != nil {
+			return nil, e
+		}
+		tr.SetVersionstampedValue(fdb.Key("latest"), v)
+
+		if _, e := tr.Get(fdb.Key("latest")).Get(); e == nil {
+			t.Errorf("read of versionstamped value within transaction succeeded")
+		}
+
+		vs = tr.GetVersionstamp()
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	stamp := vs.MustGet()
+	tr, _ := db.CreateTransaction()
+	kvs := tr.GetRange(ss, fdb.RangeOptions{}).GetSliceOrPanic()
+	if len(kvs) != 1 {
+		t.Fatalf("found %d versionstamped keys, want 1", len(kvs))
+	}
+	tup, e := ss.Unpack(kvs[0].Key)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if v := tup[0].(tuple.Versionstamp); !bytes.Equal(v.TransactionVersion[:], stamp) || v.UserVersion != 7 {
+		t.Errorf("versionstamped key = %v, want stamp %v with user version 7", v, stamp)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	db := memdb.New()
+
+	cases := []struct {
+		f      func(memdb.Transaction)
+		expect fdb.Error
+	}{
+		{func(tr memdb.Transaction) { tr.Set(make(fdb.Key, 10001), nil) }, fdb.ErrKeyTooLarge},
+		{func(tr memdb.Transaction) { tr.Set(fdb.Key("a"), make([]byte, 100001)) }, fdb.ErrValueTooLarge},
+		{func(tr memdb.Transaction) {
+			for i := 0; i < 101; i++ {
+				tr.Set(fdb.Key(fmt.Sprint(i)), make([]byte, 100000))
+			}
+		}, fdb.ErrTransactionTooLarge},
+	}
+	for i, c := range cases {
+		_, e := db.Transact(func(tr memdb.Transaction) (interface{}, error) {
+			c.f(tr)
+			return nil, nil
+		})
+		if e != c.expect {
+			t.Errorf("case %d: got %v, want %v", i, e, c.expect)
+		}
+	}
+}
@@ -0,0 +1,1050 @@
+/*
+ * transaction.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go In-Memory Database
+
+package memdb
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+)
+
+// ReadTransaction is an interface implemented by both Transaction and
+// Snapshot, corresponding to fdb.ReadTransaction.
+type ReadTransaction interface {
+	Get(key fdb.KeyConvertible) fdb.FutureByteSlice
+	GetKey(sel fdb.Selectable) fdb.FutureKey
+	GetRange(r fdb.Range, options fdb.RangeOptions) fdb.RangeResult
+	GetReadVersion() fdb.FutureInt64
+	Snapshot() Snapshot
+
+	ReadTransact(func(ReadTransaction) (interface{}, error)) (interface{}, error)
+}
+
+// Transaction is a handle to a transaction on an in-memory Database, with the
+// same methods and semantics as fdb.Transaction. Transaction is a lightweight
+// object that may be efficiently copied, and is safe for concurrent use by
+// multiple goroutines.
+type Transaction struct {
+	*transaction
+}
+
+// Snapshot is a handle to a Transaction for performing snapshot reads, which
+// do not cause the transaction to conflict with concurrent writes, in the same
+// way as fdb.Snapshot.
+type Snapshot struct {
+	*transaction
+}
+
+type transaction struct {
+	db *database
+	mu sync.Mutex
+
+	hasReadVersion bool
+	readVersion    int64
+
+	// writes holds the effect of this transaction on individual keys, and
+	// clears the ranges it has cleared (other than keys in writes).
+	writes map[string]*write
+	clears []keyRange
+
+	// mutations is the log of writes, in order, to be applied at commit.
+	mutations []mutation
+
+	reads     []keyRange
+	conflicts []keyRange
+	size      int
+
+	// err is a deferred error (such as an invalid key) reported by Commit.
+	err       error
+	cancelled bool
+
+	committed        bool
+	committedVersion int64
+	versionstamp     *future[fdb.Key]
+
+	// watches holds the watches created by this transaction, to be
+	// registered with the database when it commits.
+	watches []*watch
+}
+
+// write records the value of a key as seen by later reads in the same
+// transaction. If known is false, the value is that in the database with ops
+// applied.
+type write struct {
+	known      bool
+	value      []byte
+	ops        []pendingOp
+	unreadable bool
+}
+
+type pendingOp struct {
+	f     atomicFunc
+	param []byte
+}
+
+type mutationKind int
+
+const (
+	mutationSet mutationKind = iota
+	mutationClear
+	mutationClearRange
+	mutationAtomic
+	mutationVersionstampedKey
+	mutationVersionstampedValue
+)
+
+type mutation struct {
+	kind  mutationKind
+	key   string
+	end   string
+	param []byte
+	op    atomicFunc
+}
+
+func (t *transaction) reset() {
+	if t.versionstamp != nil {
+		t.versionstamp.set(nil, fdb.ErrTransactionCancelled)
+	}
+	t.failWatches(fdb.ErrTransactionCancelled)
+	t.hasReadVersion = false
+	t.readVersion = 0
+	t.writes = make(map[string]*write)
+	t.clears = nil
+	t.mutations = nil
+	t.reads = nil
+	t.conflicts = nil
+	t.size = 0
+	t.err = nil
+	t.cancelled = false
+	t.committed = false
+	t.committedVersion = -1
+	t.versionstamp = newFuture[fdb.Key]()
+}
+
+// Transact executes the caller-provided function, passing it the Transaction
+// receiver object. A panic of type fdb.Error during execution of the function
+// will be recovered and returned to the caller as an error, but Transact will
+// not retry the function or commit the Transaction.
+func (t Transaction) Transact(f func(Transaction) (interface{}, error)) (r interface{}, e error) {
+	defer panicToError(&e)
+
+	r, e = f(t)
+	return
+}
+
+// ReadTransact executes the caller-provided function, passing it the
+// Transaction receiver object (as a ReadTransaction).
+func (t Transaction) ReadTransact(f func(ReadTransaction) (interface{}, error)) (r interface{}, e error) {
+	defer panicToError(&e)
+
+	r, e = f(t)
+	return
+}
+
+// ReadTransact executes the caller-provided function, passing it the Snapshot
+// receiver object (as a ReadTransaction).
+func (s Snapshot) ReadTransact(f func(ReadTransaction) (interface{}, error)) (r interface{}, e error) {
+	defer panicToError(&e)
+
+	r, e = f(s)
+	return
+}
+
+// TransactReadWriter executes the caller-provided function, passing it the
+// Transaction receiver object (as an fdb.ReadWriter).
+func (t Transaction) TransactReadWriter(f func(fdb.ReadWriter) (interface{}, error)) (r interface{}, e error) {
+	defer panicToError(&e)
+
+	r, e = f(t)
+	return
+}
+
+// ReadTransactReader executes the caller-provided function, passing it the
+// Transaction receiver object (as an fdb.Reader).
+func (t Transaction) ReadTransactReader(f func(fdb.Reader) (interface{}, error)) (r interface{}, e error) {
+	defer panicToError(&e)
+
+	r, e = f(t)
+	return
+}
+
+// ReadTransactReader executes the caller-provided function, passing it the
+// Snapshot receiver object (as an fdb.Reader).
+func (s Snapshot) ReadTransactReader(f func(fdb.Reader) (interface{}, error)) (r interface{}, e error) {
+	defer panicToError(&e)
+
+	r, e = f(s)
+	return
+}
+
+// Snapshot returns a Snapshot object, suitable for performing snapshot
+// reads.
+func (t Transaction) Snapshot() Snapshot {
+	return Snapshot{t.transaction}
+}
+
+// Snapshot returns the receiver.
+func (s Snapshot) Snapshot() Snapshot {
+	return s
+}
+
+// SnapshotReader returns the Snapshot of the transaction as an fdb.Reader.
+func (t Transaction) SnapshotReader() fdb.Reader {
+	return t.Snapshot()
+}
+
+// SnapshotReader returns the receiver as an fdb.Reader.
+func (s Snapshot) SnapshotReader() fdb.Reader {
+	return s
+}
+
+// Get returns the (future) value associated with the specified key.
+func (t Transaction) Get(key fdb.KeyConvertible) fdb.FutureByteSlice {
+	return t.get(key.FDBKey(), false)
+}
+
+// Get is equivalent to (Transaction).Get, performed as a snapshot read.
+func (s Snapshot) Get(key fdb.KeyConvertible) fdb.FutureByteSlice {
+	return s.get(key.FDBKey(), true)
+}
+
+// GetKey returns the future key referenced by the provided key selector.
+func (t Transaction) GetKey(sel fdb.Selectable) fdb.FutureKey {
+	return t.getKey(sel.FDBKeySelector(), false)
+}
+
+// GetKey is equivalent to (Transaction).GetKey, performed as a snapshot read.
+func (s Snapshot) GetKey(sel fdb.Selectable) fdb.FutureKey {
+	return s.getKey(sel.FDBKeySelector(), true)
+}
+
+// GetRange performs a range read, returning all key-value pairs in the range
+// described by r, subject to options.
+func (t Transaction) GetRange(r fdb.Range, options fdb.RangeOptions) fdb.RangeResult {
+	return t.getRange(r, options, false)
+}
+
+// GetRange is equivalent to (Transaction).GetRange, performed as a snapshot
+// read.
+func (s Snapshot) GetRange(r fdb.Range, options fdb.RangeOptions) fdb.RangeResult {
+	return s.getRange(r, options, true)
+}
+
+// GetReadVersion returns the (future) version of the database read by this
+// transaction.
+func (t Transaction) GetReadVersion() fdb.FutureInt64 {
+	return t.getReadVersion()
+}
+
+// GetReadVersion is equivalent to (Transaction).GetReadVersion.
+func (s Snapshot) GetReadVersion() fdb.FutureInt64 {
+	return s.getReadVersion()
+}
+
+// SetReadVersion sets the version of the database read by this transaction.
+// Reads fail with future_version if version has not yet been committed, or
+// with transaction_too_old if it is no longer retained.
+func (t Transaction) SetReadVersion(version int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.hasReadVersion = true
+	t.readVersion = version
+}
+
+// Set sets the value for the given key to the provided value.
+func (t Transaction) Set(key fdb.KeyConvertible, value []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key.FDBKey()
+	if !t.checkWrite(k, value) {
+		return
+	}
+	v := append([]byte{}, value...)
+	t.writes[string(k)] = &write{known: true, value: v}
+	t.addMutation(mutation{kind: mutationSet, key: string(k), param: v})
+}
+
+// Clear removes the specified key (and any associated value), if it exists.
+func (t Transaction) Clear(key fdb.KeyConvertible) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key.FDBKey()
+	if !t.checkWrite(k, nil) {
+		return
+	}
+	t.writes[string(k)] = &write{known: true}
+	t.addMutation(mutation{kind: mutationClear, key: string(k)})
+}
+
+// ClearRange removes all keys k such that begin <= k < end, and their
+// associated values.
+func (t Transaction) ClearRange(er fdb.ExactRange) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancelled {
+		return
+	}
+	r, e := exactRange(er)
+	if e != nil {
+		t.setErr(e)
+		return
+	}
+	for k := range t.writes {
+		if r.contains(k) {
+			delete(t.writes, k)
+		}
+	}
+	t.clears = append(t.clears, r)
+	t.mutations = append(t.mutations, mutation{kind: mutationClearRange, key: r.begin, end: r.end})
+	t.conflicts = append(t.conflicts, r)
+	t.size += len(r.begin) + len(r.end)
+}
+
+// GetVersionstamp returns a future which will contain the versionstamp used by
+// this transaction once it has been committed.
+func (t Transaction) GetVersionstamp() fdb.FutureKey {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.versionstamp
+}
+
+// GetCommittedVersion returns the version at which this transaction was
+// committed, or -1 if it was read-only or has not been committed.
+func (t Transaction) GetCommittedVersion() (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.committedVersion, nil
+}
+
+// GetApproximateSize returns the approximate size of the mutations and
+// conflict ranges of this transaction, which is checked against the
+// transaction size limit on commit.
+func (t Transaction) GetApproximateSize() fdb.FutureInt64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return readyFuture(int64(t.size), nil)
+}
+
+// AddReadConflictRange adds a range of keys to the read conflict ranges of
+// the transaction.
+func (t Transaction) AddReadConflictRange(er fdb.ExactRange) error {
+	r, e := exactRange(er)
+	if e != nil {
+		return e
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reads = append(t.reads, r)
+	return nil
+}
+
+// AddReadConflictKey adds a key to the read conflict ranges of the
+// transaction.
+func (t Transaction) AddReadConflictKey(key fdb.KeyConvertible) error {
+	k := string(key.FDBKey())
+	return t.AddReadConflictRange(fdb.KeyRange{Begin: fdb.Key(k), End: fdb.Key(keyAfter(k))})
+}
+
+// AddWriteConflictRange adds a range of keys to the write conflict ranges of
+// the transaction.
+func (t Transaction) AddWriteConflictRange(er fdb.ExactRange) error {
+	r, e := exactRange(er)
+	if e != nil {
+		return e
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.conflicts = append(t.conflicts, r)
+	t.size += len(r.begin) + len(r.end)
+	return nil
+}
+
+// AddWriteConflictKey adds a key to the write conflict ranges of the
+// transaction.
+func (t Transaction) AddWriteConflictKey(key fdb.KeyConvertible) error {
+	k := string(key.FDBKey())
+	return t.AddWriteConflictRange(fdb.KeyRange{Begin: fdb.Key(k), End: fdb.Key(keyAfter(k))})
+}
+
+// Cancel cancels the transaction. All pending or future uses of the
+// transaction will encounter an error, until the transaction is Reset.
+func (t Transaction) Cancel() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.cancelled = true
+	t.versionstamp.set(nil, fdb.ErrTransactionCancelled)
+	t.failWatches(fdb.ErrTransactionCancelled)
+}
+
+// Reset rolls back the transaction, returning it to the state it was in
+// when first created.
+func (t Transaction) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reset()
+}
+
+// OnError determines whether an error returned by a method of the
+// transaction is retryable. If it is, the transaction is reset and the
+// returned future is ready with no error; otherwise the returned future is
+// ready with the original error.
+func (t Transaction) OnError(e fdb.Error) fdb.FutureNil {
+	switch e {
+	case fdb.ErrTransactionTooOld, fdb.ErrFutureVersion, fdb.ErrNotCommitted,
+		fdb.ErrCommitUnknownResult, fdb.ErrProcessBehind:
+		t.Reset()
+		return readyFutureNil(nil)
+	}
+	return readyFutureNil(e)
+}
+
+// Commit attempts to commit the modifications made in the transaction to the
+// database. The commit fails with not_committed if any key read by the
+// transaction (outside of snapshot reads) has been modified by another
+// transaction committed since the read version of this transaction.
+func (t Transaction) Commit() fdb.FutureNil {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e := t.commit()
+	if e != nil {
+		t.failWatches(e)
+	}
+	return readyFutureNil(e)
+}
+
+func (t *transaction) commit() error {
+	if t.cancelled {
+		return fdb.ErrTransactionCancelled
+	}
+	if t.committed {
+		return fdb.ErrUsedDuringCommit
+	}
+	if t.err != nil {
+		return t.err
+	}
+
+	if len(t.mutations) == 0 && len(t.conflicts) == 0 {
+		t.committed = true
+		t.versionstamp.set(nil, errNoCommitVersion)
+
+		t.db.mu.Lock()
+		t.db.addWatches(t.watches)
+		t.db.mu.Unlock()
+		t.watches = nil
+
+		return nil
+	}
+
+	if t.size > transactionSizeLimit {
+		return fdb.ErrTransactionTooLarge
+	}
+
+	d := t.db
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	d.prune(now)
+
+	if t.hasReadVersion && len(t.reads) > 0 {
+		if e := d.checkConflicts(t.readVersion, t.reads); e != nil {
+			return e
+		}
+	}
+
+	version := d.version + 1
+	stamp := make([]byte, 10)
+	binary.BigEndian.PutUint64(stamp, uint64(version))
+
+	writes := append([]keyRange{}, t.conflicts...)
+
+	for _, m := range t.mutations {
+		switch m.kind {
+		case mutationSet:
+			d.write(m.key, m.param, version)
+		case mutationClear:
+			if d.valueAt(m.key, version) != nil {
+				d.write(m.key, nil, version)
+			}
+		case mutationClearRange:
+			keys := append([]string{}, d.keysIn(keyRange{m.key, m.end})...)
+			for _, k := range keys {
+				if d.valueAt(k, version) != nil {
+					d.write(k, nil, version)
+				}
+			}
+		case mutationAtomic:
+			existing := d.valueAt(m.key, version)
+			if v := m.op(existing, m.param); v != nil || existing != nil {
+				d.write(m.key, v, version)
+			}
+		case mutationVersionstampedKey:
+			k := string(placeVersionstamp([]byte(m.key), stamp))
+			d.write(k, m.param, version)
+			writes = append(writes, keyRange{k, keyAfter(k)})
+		case mutationVersionstampedValue:
+			d.write(m.key, placeVersionstamp(m.param, stamp), version)
+		}
+	}
+
+	d.version = version
+	d.commits = append(d.commits, commitRecord{version: version, at: now, writes: writes})
+
+	t.committed = true
+	t.committedVersion = version
+	t.versionstamp.set(fdb.Key(stamp), nil)
+
+	d.addWatches(t.watches)
+	t.watches = nil
+
+	return nil
+}
+
+// placeVersionstamp returns param with its trailing 4-byte little-endian
+// offset removed and the versionstamp written at that offset.
+func placeVersionstamp(param []byte, stamp []byte) []byte {
+	n := len(param) - 4
+	pos := int(int32(binary.LittleEndian.Uint32(param[n:])))
+	ret := append([]byte{}, param[:n]...)
+	copy(ret[pos:], stamp)
+	return ret
+}
+
+// validVersionstamp reports whether param has a trailing offset leaving room
+// for a versionstamp.
+func validVersionstamp(param []byte) bool {
+	if len(param) < 4 {
+		return false
+	}
+	n := len(param) - 4
+	pos := int(int32(binary.LittleEndian.Uint32(param[n:])))
+	return pos >= 0 && pos+10 <= n
+}
+
+// setErr records the first error encountered by a write, to be returned by
+// Commit.
+func (t *transaction) setErr(e error) {
+	if t.err == nil {
+		t.err = e
+	}
+}
+
+// checkWrite validates a key and value to be written, returning false if the
+// write should be ignored.
+func (t *transaction) checkWrite(key, value []byte) bool {
+	if t.cancelled {
+		return false
+	}
+	if e := checkKey(key); e != nil {
+		t.setErr(e)
+		return false
+	}
+	if len(value) > valueSizeLimit {
+		t.setErr(fdb.ErrValueTooLarge)
+		return false
+	}
+	return true
+}
+
+func (t *transaction) addMutation(m mutation) {
+	t.mutations = append(t.mutations, m)
+	t.conflicts = append(t.conflicts, keyRange{m.key, keyAfter(m.key)})
+	t.size += 2*len(m.key) + len(m.param)
+}
+
+// atomicOp applies f to key as part of the transaction. The result is visible
+// to later reads in the transaction, and f is applied to the value in the
+// database at the time of commit.
+func (t Transaction) atomicOp(key fdb.KeyConvertible, param []byte, f atomicFunc) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key.FDBKey()
+	if !t.checkWrite(k, param) {
+		return
+	}
+	p := append([]byte{}, param...)
+
+	w, ok := t.writes[string(k)]
+	if !ok {
+		w = &write{known: t.cleared(string(k))}
+		t.writes[string(k)] = w
+	}
+	switch {
+	case w.unreadable:
+	case w.known:
+		w.value = f(w.value, p)
+	default:
+		w.ops = append(w.ops, pendingOp{f, p})
+	}
+	t.addMutation(mutation{kind: mutationAtomic, key: string(k), param: p, op: f})
+}
+
+// Add performs an addition of little-endian integers. See
+// (fdb.Transaction).Add for details.
+func (t Transaction) Add(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doAdd)
+}
+
+// And is a deprecated alias of BitAnd.
+func (t Transaction) And(key fdb.KeyConvertible, param []byte) {
+	t.BitAnd(key, param)
+}
+
+// BitAnd performs a bitwise "and" operation. See (fdb.Transaction).BitAnd
+// for details.
+func (t Transaction) BitAnd(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doBitAnd)
+}
+
+// Or is a deprecated alias of BitOr.
+func (t Transaction) Or(key fdb.KeyConvertible, param []byte) {
+	t.BitOr(key, param)
+}
+
+// BitOr performs a bitwise "or" operation. See (fdb.Transaction).BitOr for
+// details.
+func (t Transaction) BitOr(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doBitOr)
+}
+
+// Xor is a deprecated alias of BitXor.
+func (t Transaction) Xor(key fdb.KeyConvertible, param []byte) {
+	t.BitXor(key, param)
+}
+
+// BitXor performs a bitwise "xor" operation. See (fdb.Transaction).BitXor
+// for details.
+func (t Transaction) BitXor(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doBitXor)
+}
+
+// AppendIfFits appends param to the end of the existing value if the result
+// would not exceed the value size limit. See (fdb.Transaction).AppendIfFits
+// for details.
+func (t Transaction) AppendIfFits(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doAppendIfFits)
+}
+
+// Max performs a little-endian comparison of byte strings, keeping the
+// larger. See (fdb.Transaction).Max for details.
+func (t Transaction) Max(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doMax)
+}
+
+// Min performs a little-endian comparison of byte strings, keeping the
+// smaller. See (fdb.Transaction).Min for details.
+func (t Transaction) Min(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doMin)
+}
+
+// ByteMax performs a lexicographic comparison of byte strings, keeping the
+// larger. See (fdb.Transaction).ByteMax for details.
+func (t Transaction) ByteMax(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doByteMax)
+}
+
+// ByteMin performs a lexicographic comparison of byte strings, keeping the
+// smaller. See (fdb.Transaction).ByteMin for details.
+func (t Transaction) ByteMin(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doByteMin)
+}
+
+// CompareAndClear clears the key if its value is equal to param. See
+// (fdb.Transaction).CompareAndClear for details.
+func (t Transaction) CompareAndClear(key fdb.KeyConvertible, param []byte) {
+	t.atomicOp(key, param, doCompareAndClear)
+}
+
+// SetVersionstampedKey transforms key using the versionstamp of the
+// transaction, and sets the result to param. The last 4 bytes of key are the
+// little-endian offset at which the 10-byte versionstamp is placed. Keys set
+// this way are not visible to reads in the same transaction.
+func (t Transaction) SetVersionstampedKey(key fdb.KeyConvertible, param []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key.FDBKey()
+	if !validVersionstamp(k) {
+		t.setErr(fdb.Error{Code: 2000}) // client_invalid_operation
+		return
+	}
+	if !t.checkWrite(k[:len(k)-4], param) {
+		return
+	}
+	t.mutations = append(t.mutations, mutation{kind: mutationVersionstampedKey, key: string(k), param: append([]byte{}, param...)})
+	t.size += len(k) + len(param)
+}
+
+// SetVersionstampedValue transforms param using the versionstamp of the
+// transaction, and sets key to the result. The last 4 bytes of param are the
+// little-endian offset at which the 10-byte versionstamp is placed. Reading
+// key later in the same transaction fails with accessed_unreadable.
+func (t Transaction) SetVersionstampedValue(key fdb.KeyConvertible, param []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := key.FDBKey()
+	if !validVersionstamp(param) {
+		t.setErr(fdb.Error{Code: 2000}) // client_invalid_operation
+		return
+	}
+	if !t.checkWrite(k, param[:len(param)-4]) {
+		return
+	}
+	t.writes[string(k)] = &write{known: true, unreadable: true}
+	t.addMutation(mutation{kind: mutationVersionstampedValue, key: string(k), param: append([]byte{}, param...)})
+}
+
+func exactRange(er fdb.ExactRange) (keyRange, error) {
+	bk, ek := er.FDBRangeKeys()
+	b, e := bk.FDBKey(), ek.FDBKey()
+	if string(b) > string(e) {
+		return keyRange{}, errInvertedRange
+	}
+	if string(e) > string(maxKey) {
+		return keyRange{}, errKeyOutsideLegalRange
+	}
+	return keyRange{string(b), string(e)}, nil
+}
+
+// cleared reports whether key lies in a range cleared by the transaction.
+func (t *transaction) cleared(key string) bool {
+	for _, r := range t.clears {
+		if r.contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// readable returns an error if the transaction may not currently be read. It
+// acquires a read version if necessary. The caller must hold t.mu and
+// t.db.mu.
+func (t *transaction) readable() error {
+	if t.cancelled {
+		return fdb.ErrTransactionCancelled
+	}
+	if t.committed {
+		return fdb.ErrUsedDuringCommit
+	}
+	if !t.hasReadVersion {
+		t.hasReadVersion = true
+		t.readVersion = t.db.version
+	}
+	if t.readVersion > t.db.version {
+		return fdb.ErrFutureVersion
+	}
+	if t.readVersion < t.db.oldest {
+		return fdb.ErrTransactionTooOld
+	}
+	return nil
+}
+
+// value returns the value of key as seen by the transaction, and whether it
+// was read from the database (rather than determined entirely by the writes
+// of the transaction). The caller must hold t.mu and t.db.mu.
+func (t *transaction) value(key string) ([]byte, bool, error) {
+	w, ok := t.writes[key]
+	if ok && w.unreadable {
+		return nil, false, errAccessedUnreadable
+	}
+	if ok && w.known {
+		return w.value, false, nil
+	}
+	if !ok && t.cleared(key) {
+		return nil, false, nil
+	}
+	v := t.db.valueAt(key, t.readVersion)
+	if ok {
+		for _, op := range w.ops {
+			v = op.f(v, op.param)
+		}
+	}
+	return v, true, nil
+}
+
+// scan calls fn for each key-value pair present in the range r as seen by the
+// transaction, in order (or in reverse order), until fn returns false. Only
+// the keys visited are read, so the cost of a scan which stops early does not
+// depend on the size of the rest of r. The caller must hold t.mu and t.db.mu.
+func (t *transaction) scan(r keyRange, reverse bool, fn func(key string, value []byte) bool) error {
+	if r.end > string(maxKey) {
+		r.end = string(maxKey)
+	}
+	if r.begin >= r.end {
+		return nil
+	}
+
+	dbKeys := t.db.keysIn(r)
+	var writeKeys []string
+	for k := range t.writes {
+		if r.contains(k) {
+			writeKeys = append(writeKeys, k)
+		}
+	}
+	sort.Strings(writeKeys)
+
+	// Merge the two sorted lists of keys, from the front or the back
+	i, j := 0, 0
+	for i < len(dbKeys) || j < len(writeKeys) {
+		var k string
+		di, wj := i, j
+		if reverse {
+			di, wj = len(dbKeys)-1-i, len(writeKeys)-1-j
+		}
+		switch {
+		case j == len(writeKeys):
+			k = dbKeys[di]
+			i++
+		case i == len(dbKeys):
+			k = writeKeys[wj]
+			j++
+		case dbKeys[di] == writeKeys[wj]:
+			k = dbKeys[di]
+			i++
+			j++
+		case (dbKeys[di] < writeKeys[wj]) != reverse:
+			k = dbKeys[di]
+			i++
+		default:
+			k = writeKeys[wj]
+			j++
+		}
+
+		v, _, e := t.value(k)
+		if e != nil {
+			return e
+		}
+		if v != nil && !fn(k, v) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// resolve returns the key referenced by sel, as seen by the transaction. The
+// caller must hold t.mu and t.db.mu.
+func (t *transaction) resolve(sel fdb.KeySelector) (string, error) {
+	k := string(sel.Key.FDBKey())
+
+	// A selector with a positive offset references the offset'th key after
+	// its key (or at it, unless OrEqual), and otherwise the (1-offset)'th key
+	// before it (or at it, if OrEqual).
+	var r keyRange
+	var n int
+	reverse := sel.Offset <= 0
+	switch {
+	case !reverse && sel.OrEqual:
+		r, n = keyRange{keyAfter(k), string(maxKey)}, sel.Offset
+	case !reverse:
+		r, n = keyRange{k, string(maxKey)}, sel.Offset
+	case sel.OrEqual:
+		r, n = keyRange{"", keyAfter(k)}, 1-sel.Offset
+	default:
+		r, n = keyRange{"", k}, 1-sel.Offset
+	}
+
+	found := ""
+	e := t.scan(r, reverse, func(key string, _ []byte) bool {
+		n--
+		if n == 0 {
+			found = key
+			return false
+		}
+		return true
+	})
+	switch {
+	case e != nil:
+		return "", e
+	case n == 0:
+		return found, nil
+	case reverse:
+		return "", nil
+	default:
+		return string(maxKey), nil
+	}
+}
+
+func (t *transaction) lock() func() {
+	t.mu.Lock()
+	t.db.mu.Lock()
+	return func() {
+		t.db.mu.Unlock()
+		t.mu.Unlock()
+	}
+}
+
+func (t *transaction) get(key fdb.Key, snapshot bool) fdb.FutureByteSlice {
+	defer t.lock()()
+
+	if e := t.readable(); e != nil {
+		return readyFuture[[]byte](nil, e)
+	}
+	if e := checkKey(key); e != nil {
+		return readyFuture[[]byte](nil, e)
+	}
+
+	k := string(key)
+	v, fromDB, e := t.value(k)
+	if e != nil {
+		return readyFuture[[]byte](nil, e)
+	}
+	if fromDB && !snapshot {
+		t.reads = append(t.reads, keyRange{k, keyAfter(k)})
+	}
+	if v != nil {
+		v = append([]byte{}, v...)
+	}
+	return readyFuture(v, nil)
+}
+
+func (t *transaction) getKey(sel fdb.KeySelector, snapshot bool) fdb.FutureKey {
+	defer t.lock()()
+
+	if e := t.readable(); e != nil {
+		return readyFuture[fdb.Key](nil, e)
+	}
+
+	k, e := t.resolve(sel)
+	if e != nil {
+		return readyFuture[fdb.Key](nil, e)
+	}
+
+	if !snapshot {
+		lo, hi := string(sel.Key.FDBKey()), k
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		t.reads = append(t.reads, keyRange{lo, keyAfter(hi)})
+	}
+	return readyFuture(fdb.Key(k), nil)
+}
+
+func (t *transaction) getRange(r fdb.Range, options fdb.RangeOptions, snapshot bool) fdb.RangeResult {
+	return fdb.NewRangeResult(r, options, func(sr fdb.SelectorRange, options fdb.RangeOptions, iteration int) ([]fdb.KeyValue, bool, error) {
+		return t.readRange(sr, options, iteration, snapshot)
+	})
+}
+
+// batchSize returns the number of key-value pairs returned by a single batch
+// of a range read, imitating the growth of batches in FoundationDB so that
+// iterators are exercised across batch boundaries.
+func batchSize(mode fdb.StreamingMode, iteration int) int {
+	switch mode {
+	case fdb.StreamingModeWantAll, fdb.StreamingModeExact:
+		return 0
+	}
+	if iteration > 8 {
+		iteration = 8
+	}
+	return 16 << uint(iteration)
+}
+
+func (t *transaction) readRange(sr fdb.SelectorRange, options fdb.RangeOptions, iteration int, snapshot bool) ([]fdb.KeyValue, bool, error) {
+	defer t.lock()()
+
+	if e := t.readable(); e != nil {
+		return nil, false, e
+	}
+	if options.Limit < 0 {
+		return nil, false, fdb.Error{Code: 2012} // range_limits_invalid
+	}
+
+	b, e := t.resolve(sr.Begin.FDBKeySelector())
+	if e != nil {
+		return nil, false, e
+	}
+	end, e := t.resolve(sr.End.FDBKeySelector())
+	if e != nil {
+		return nil, false, e
+	}
+	if b >= end {
+		return nil, false, nil
+	}
+
+	// Read one more pair than the limit and the batch allow, to learn whether
+	// either truncates the range
+	want := options.Limit
+	if n := batchSize(options.Mode, iteration); n > 0 && (want == 0 || n < want) {
+		want = n
+	}
+	var kvs []fdb.KeyValue
+	e = t.scan(keyRange{b, end}, options.Reverse, func(k string, v []byte) bool {
+		kvs = append(kvs, fdb.KeyValue{Key: fdb.Key(k), Value: v})
+		return want == 0 || len(kvs) <= want
+	})
+	if e != nil {
+		return nil, false, e
+	}
+
+	truncated, more := false, false
+	if options.Limit > 0 && len(kvs) > options.Limit {
+		kvs = kvs[:options.Limit]
+		truncated = true
+	}
+	if n := batchSize(options.Mode, iteration); n > 0 && len(kvs) > n {
+		kvs = kvs[:n]
+		truncated, more = true, true
+	}
+
+	if !snapshot {
+		switch {
+		case !truncated:
+			t.reads = append(t.reads, keyRange{b, end})
+		case options.Reverse:
+			t.reads = append(t.reads, keyRange{string(kvs[len(kvs)-1].Key), end})
+		default:
+			t.reads = append(t.reads, keyRange{b, keyAfter(string(kvs[len(kvs)-1].Key))})
+		}
+	}
+
+	for i := range kvs {
+		kvs[i].Value = append([]byte{}, kvs[i].Value...)
+	}
+	return kvs, more, nil
+}
+
+func (t *transaction) getReadVersion() fdb.FutureInt64 {
+	defer t.lock()()
+
+	if e := t.readable(); e != nil {
+		return readyFuture[int64](0, e)
+	}
+	return readyFuture(t.readVersion, nil)
+}
@@ -0,0 +1,113 @@
+/*
+ * watch.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go In-Memory Database
+
+package memdb
+
+import (
+	"bytes"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+)
+
+// watch is a pending watch on a key, which becomes ready when the value of the
+// key in the database differs from value.
+type watch struct {
+	key   string
+	value []byte
+	f     *future[struct{}]
+}
+
+// Watch creates a watch and returns a FutureNil that will become ready when the
+// watch reports a change to the value of the specified key, with the same
+// semantics as (fdb.Transaction).Watch.
+//
+// The value used for comparison is the value of the key as seen by the
+// transaction when the watch is created. The watch is registered with the
+// database when the transaction commits; a change made by the transaction
+// itself after the watch was created is therefore reported as soon as the
+// transaction commits. If the commit fails, the watch is set with the commit
+// error, and if the transaction is cancelled or reset before committing, the
+// watch is set with transaction_cancelled.
+//
+// Any watch that is no longer needed should be cancelled by calling
+// (FutureNil).Cancel on its returned future.
+func (t Transaction) Watch(key fdb.KeyConvertible) fdb.FutureNil {
+	defer t.lock()()
+
+	if e := t.readable(); e != nil {
+		return readyFutureNil(e)
+	}
+	kb := key.FDBKey()
+	if e := checkKey(kb); e != nil {
+		return readyFutureNil(e)
+	}
+
+	k := string(kb)
+	v, _, e := t.value(k)
+	if e != nil {
+		return readyFutureNil(e)
+	}
+
+	w := &watch{key: k, f: newFuture[struct{}]()}
+	if v != nil {
+		w.value = append([]byte{}, v...)
+	}
+	t.watches = append(t.watches, w)
+	return futureNil{w.f}
+}
+
+// failWatches sets the pending watches of the transaction with e. The caller
+// must hold t.mu.
+func (t *transaction) failWatches(e error) {
+	for _, w := range t.watches {
+		w.f.set(struct{}{}, e)
+	}
+	t.watches = nil
+}
+
+// addWatches registers ws with the database. The caller must hold d.mu.
+func (d *database) addWatches(ws []*watch) {
+	d.watches = append(d.watches, ws...)
+	d.fireWatches()
+}
+
+// fireWatches makes ready every watch whose key has changed, and discards
+// watches which are ready (including those which have been cancelled). The
+// caller must hold d.mu.
+func (d *database) fireWatches() {
+	pending := d.watches[:0]
+	for _, w := range d.watches {
+		if !w.f.IsReady() {
+			v := d.valueAt(w.key, d.version)
+			if (v == nil) != (w.value == nil) || !bytes.Equal(v, w.value) {
+				w.f.set(struct{}{}, nil)
+			}
+		}
+		if !w.f.IsReady() {
+			pending = append(pending, w)
+		}
+	}
+	for i := len(pending); i < len(d.watches); i++ {
+		d.watches[i] = nil
+	}
+	d.watches = pending
+}
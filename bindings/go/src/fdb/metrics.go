@@ -0,0 +1,249 @@
+/*
+ * metrics.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics receives measurements of the transactions run by (Database).Transact
+// and related methods. Metrics may be attached to a Database with
+// (Database).WithMetrics; the metrics package provides an implementation which
+// exports them in the Prometheus text format.
+type Metrics interface {
+	// ObserveTransaction is called once for each call to Transact,
+	// ReadTransact, TransactContext or ReadTransactContext, after the retry
+	// loop has finished. It may be called concurrently from multiple
+	// goroutines.
+	ObserveTransaction(stats TransactionStats)
+}
+
+// MetricsFunc is an adapter to allow the use of an ordinary function as
+// Metrics.
+type MetricsFunc func(stats TransactionStats)
+
+// ObserveTransaction calls f(stats).
+func (f MetricsFunc) ObserveTransaction(stats TransactionStats) {
+	f(stats)
+}
+
+// TransactionStats describes a transaction run by a Database retry loop.
+type TransactionStats struct {
+	// Name is the name of the Database (see (Database).WithName), and ReadOnly
+	// is true for ReadTransact and ReadTransactContext.
+	Name     string
+	ReadOnly bool
+
+	// Attempts holds one entry for each time the transactional function was
+	// run, in order. Every attempt but the last failed.
+	Attempts []AttemptStats
+
+	// Duration is the time taken by the whole retry loop, and Err the error
+	// it returned.
+	Duration time.Duration
+	Err      error
+}
+
+// AttemptStats describes a single attempt of a transaction.
+type AttemptStats struct {
+	// ReadVersionLatency is the time the attempt waited for its read
+	// version, from its first read (when the client requests the read
+	// version) until the read version was obtained. It is 0 for attempts
+	// which did not read, and for attempts which ended before their read
+	// version was obtained.
+	ReadVersionLatency time.Duration
+
+	// CommitLatency is the time taken by the commit, and Bytes the
+	// approximate size of the transaction (as reported by
+	// GetApproximateSize) when it was committed. Both are 0 for read-only
+	// transactions, and for attempts which failed before committing.
+	CommitLatency time.Duration
+	Bytes         int64
+
+	// Err is the error with which the attempt failed, or nil.
+	Err error
+}
+
+// Conflicts returns the number of attempts which failed with not_committed.
+func (s TransactionStats) Conflicts() int {
+	return s.count(ErrNotCommitted)
+}
+
+// Timeouts returns the number of attempts which failed with
+// transaction_timed_out.
+func (s TransactionStats) Timeouts() int {
+	return s.count(ErrTransactionTimedOut)
+}
+
+func (s TransactionStats) count(e Error) int {
+	n := 0
+	for _, a := range s.Attempts {
+		if ep, ok := a.Err.(Error); ok && ep == e {
+			n++
+		}
+	}
+	return n
+}
+
+// WithMetrics returns a copy of d whose transactional methods report to m. A
+// nil m removes any Metrics.
+func (d Database) WithMetrics(m Metrics) Database {
+	return d.withConfig(func(c *databaseConfig) {
+		c.metrics = m
+	})
+}
+
+// WithName returns a copy of d with the provided name, which identifies its
+// transactions to Metrics, Tracers and loggers. Handles for different purposes
+// (such as d.WithName("checkout") and d.WithName("inventory")) may share one
+// database.
+func (d Database) WithName(name string) Database {
+	return d.withConfig(func(c *databaseConfig) {
+		c.name = name
+	})
+}
+
+// Name returns the name given to d by WithName, or "" if it has none.
+func (d Database) Name() string {
+	if d.config == nil {
+		return ""
+	}
+	return d.config.name
+}
+
+func (d Database) metrics() Metrics {
+	if d.config == nil {
+		return nil
+	}
+	return d.config.metrics
+}
+
+// transactionRecorder collects the TransactionStats of a transaction. All of
+// its methods may be called on a nil *transactionRecorder, and do nothing.
+type transactionRecorder struct {
+	d     Database
+	m     Metrics
+	stats TransactionStats
+	start time.Time
+
+	// tr and rv are the transaction and read version timer of the current
+	// attempt.
+	tr *transaction
+	rv *readVersionTimer
+}
+
+// newTransactionRecorder returns a recorder for a transaction run by d, or nil
+// if d has neither Metrics nor a slow transaction log.
+func (d Database) newTransactionRecorder(readOnly bool) *transactionRecorder {
+	m := d.metrics()
+	if latency, size := d.slowThresholds(); m == nil && latency <= 0 && size <= 0 {
+		return nil
+	}
+	return &transactionRecorder{
+		d:     d,
+		m:     m,
+		stats: TransactionStats{Name: d.Name(), ReadOnly: readOnly},
+		start: time.Now(),
+	}
+}
+
+// beginAttempt starts a new attempt of tr.
+func (r *transactionRecorder) beginAttempt(tr Transaction) {
+	if r == nil {
+		return
+	}
+	r.stats.Attempts = append(r.stats.Attempts, AttemptStats{})
+	r.tr, r.rv = tr.transaction, &readVersionTimer{}
+	tr.readVersion.Store(r.rv)
+}
+
+// commit commits tr, recording the size of the transaction and the latency of
+// the commit.
+func (r *transactionRecorder) commit(tr Transaction) error {
+	if r == nil || r.stats.ReadOnly || len(r.stats.Attempts) == 0 {
+		return tr.Commit().Get()
+	}
+	a := &r.stats.Attempts[len(r.stats.Attempts)-1]
+	a.Bytes, _ = tr.GetApproximateSize().Get()
+	start := time.Now()
+	e := tr.Commit().Get()
+	a.CommitLatency = time.Since(start)
+	return e
+}
+
+// endAttempt records the outcome of the current attempt.
+func (r *transactionRecorder) endAttempt(e error) {
+	if r == nil || len(r.stats.Attempts) == 0 {
+		return
+	}
+	a := &r.stats.Attempts[len(r.stats.Attempts)-1]
+	a.Err = e
+	if r.rv != nil {
+		a.ReadVersionLatency = time.Duration(r.rv.latency.Load())
+		r.tr.readVersion.CompareAndSwap(r.rv, nil)
+		r.tr, r.rv = nil, nil
+	}
+}
+
+// finish reports the transaction, which returned e, to the Metrics and the
+// slow transaction log.
+func (r *transactionRecorder) finish(e error) {
+	if r == nil {
+		return
+	}
+	r.stats.Duration = time.Since(r.start)
+	r.stats.Err = e
+	if r.m != nil {
+		r.m.ObserveTransaction(r.stats)
+	}
+	r.d.logSlowTransaction(r.stats)
+}
+
+// readVersionTimer measures how long an attempt waits for its read version.
+// It starts at the first read of the attempt rather than when the attempt
+// begins, since the transactional function may set options which affect the
+// read version (such as its priority), or call SetReadVersion, before it
+// reads. Requesting the read version at the start of the attempt would
+// disregard them.
+type readVersionTimer struct {
+	once    sync.Once
+	latency atomic.Int64
+}
+
+// start calls grv for the read version of the attempt, and records its
+// latency when it is obtained. Only the first call has any effect.
+func (rt *readVersionTimer) start(grv func() *future) {
+	rt.once.Do(func() {
+		start := time.Now()
+		f := grv()
+		// The callback holds f, so that it is not destroyed (firing the
+		// callback) before it is ready.
+		f.onReady(func() {
+			rt.latency.Store(int64(time.Since(start)))
+			runtime.KeepAlive(f)
+		})
+	})
+}
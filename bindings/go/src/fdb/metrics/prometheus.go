@@ -0,0 +1,255 @@
+/*
+ * prometheus.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go Metrics
+
+// Package metrics provides an implementation of fdb.Metrics which aggregates
+// the statistics of FoundationDB transactions and exposes them in the
+// Prometheus text exposition format, without depending on a Prometheus client
+// library.
+//
+// A Prometheus collector is attached to one or more Database handles, whose
+// names (see (fdb.Database).WithName) label the exported series, and mounted
+// on an HTTP server:
+//
+//	m := metrics.NewPrometheus()
+//	checkout := db.WithName("checkout").WithMetrics(m)
+//	inventory := db.WithName("inventory").WithMetrics(m)
+//
+//	mux := http.NewServeMux()
+//	mux.Handle("/metrics", m)
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+)
+
+// Bucket upper bounds of the exported histograms.
+var (
+	attemptBuckets = []float64{1, 2, 3, 5, 10, 25}
+	bytesBuckets   = []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 5 << 20, 10 << 20}
+	secondsBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+)
+
+// Prometheus aggregates the TransactionStats reported to it, and serves them
+// over HTTP in the Prometheus text format. It is safe for concurrent use by
+// multiple goroutines, and may be shared by any number of Database handles.
+//
+// The following metrics are exported, each labelled by the name of the
+// Database:
+//
+//	fdb_transactions_total{outcome="committed"|"failed"}  counter
+//	fdb_transaction_attempts                              histogram
+//	fdb_transaction_errors_total{code}                    counter
+//	fdb_transaction_conflicts_total                       counter
+//	fdb_transaction_timeouts_total                        counter
+//	fdb_transaction_written_bytes                         histogram
+//	fdb_transaction_read_version_seconds                  histogram
+//	fdb_transaction_commit_seconds                        histogram
+//	fdb_transaction_duration_seconds                      histogram
+//
+// fdb_transaction_errors_total counts failed attempts by FoundationDB error
+// code. The read version latency is observed once for each attempt which
+// obtained a read version, and the commit latency and the written bytes once
+// for each attempt which committed or tried to commit a read-write
+// transaction.
+type Prometheus struct {
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series holds the metrics of the transactions of one name.
+type series struct {
+	committed, failed   uint64
+	conflicts, timeouts uint64
+	errors              map[int]uint64
+	attempts, bytes     histogram
+	readVersion, commit histogram
+	duration            histogram
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) histogram {
+	return histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// NewPrometheus returns a Prometheus with no recorded transactions.
+func NewPrometheus() *Prometheus {
+	return &Prometheus{series: make(map[string]*series)}
+}
+
+// ObserveTransaction records stats. It implements fdb.Metrics.
+func (p *Prometheus) ObserveTransaction(stats fdb.TransactionStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := p.series[stats.Name]
+	if !ok {
+		s = &series{
+			errors:      make(map[int]uint64),
+			attempts:    newHistogram(attemptBuckets),
+			bytes:       newHistogram(bytesBuckets),
+			readVersion: newHistogram(secondsBuckets),
+			commit:      newHistogram(secondsBuckets),
+			duration:    newHistogram(secondsBuckets),
+		}
+		p.series[stats.Name] = s
+	}
+
+	if stats.Err == nil {
+		s.committed++
+	} else {
+		s.failed++
+	}
+	s.conflicts += uint64(stats.Conflicts())
+	s.timeouts += uint64(stats.Timeouts())
+	s.attempts.observe(float64(len(stats.Attempts)))
+	s.duration.observe(stats.Duration.Seconds())
+
+	for _, a := range stats.Attempts {
+		if ep, ok := a.Err.(fdb.Error); ok {
+			s.errors[ep.Code]++
+		}
+		if a.ReadVersionLatency > 0 {
+			s.readVersion.observe(a.ReadVersionLatency.Seconds())
+		}
+		if a.CommitLatency > 0 {
+			s.commit.observe(a.CommitLatency.Seconds())
+			s.bytes.observe(float64(a.Bytes))
+		}
+	}
+}
+
+// ServeHTTP writes the current metrics in the Prometheus text format.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+// WriteTo writes the current metrics to w in the Prometheus text format.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.series))
+	for name := range p.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	counter := func(metric, help string, value func(*series) uint64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(bw, "%s{name=%s} %d\n", metric, quote(name), value(p.series[name]))
+		}
+	}
+	hist := func(metric, help string, value func(*series) *histogram) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", metric, help, metric)
+		for _, name := range names {
+			h := value(p.series[name])
+			for i, b := range h.bounds {
+				fmt.Fprintf(bw, "%s_bucket{name=%s,le=\"%s\"} %d\n", metric, quote(name), formatFloat(b), h.counts[i])
+			}
+			fmt.Fprintf(bw, "%s_bucket{name=%s,le=\"+Inf\"} %d\n", metric, quote(name), h.count)
+			fmt.Fprintf(bw, "%s_sum{name=%s} %s\n", metric, quote(name), formatFloat(h.sum))
+			fmt.Fprintf(bw, "%s_count{name=%s} %d\n", metric, quote(name), h.count)
+		}
+	}
+
+	fmt.Fprintf(bw, "# HELP fdb_transactions_total Transactions completed by the retry loop.\n# TYPE fdb_transactions_total counter\n")
+	for _, name := range names {
+		s := p.series[name]
+		fmt.Fprintf(bw, "fdb_transactions_total{name=%s,outcome=\"committed\"} %d\n", quote(name), s.committed)
+		fmt.Fprintf(bw, "fdb_transactions_total{name=%s,outcome=\"failed\"} %d\n", quote(name), s.failed)
+	}
+
+	hist("fdb_transaction_attempts", "Attempts made by each transaction.", func(s *series) *histogram { return &s.attempts })
+
+	fmt.Fprintf(bw, "# HELP fdb_transaction_errors_total Failed transaction attempts by error code.\n# TYPE fdb_transaction_errors_total counter\n")
+	for _, name := range names {
+		s := p.series[name]
+		codes := make([]int, 0, len(s.errors))
+		for code := range s.errors {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(bw, "fdb_transaction_errors_total{name=%s,code=\"%d\"} %d\n", quote(name), code, s.errors[code])
+		}
+	}
+
+	counter("fdb_transaction_conflicts_total", "Transaction attempts which failed with not_committed.", func(s *series) uint64 { return s.conflicts })
+	counter("fdb_transaction_timeouts_total", "Transaction attempts which failed with transaction_timed_out.", func(s *series) uint64 { return s.timeouts })
+	hist("fdb_transaction_written_bytes", "Approximate size of transactions when committed.", func(s *series) *histogram { return &s.bytes })
+	hist("fdb_transaction_read_version_seconds", "Latency of obtaining a read version.", func(s *series) *histogram { return &s.readVersion })
+	hist("fdb_transaction_commit_seconds", "Latency of commits.", func(s *series) *histogram { return &s.commit })
+	hist("fdb_transaction_duration_seconds", "Duration of transactions, including retries.", func(s *series) *histogram { return &s.duration })
+
+	e := bw.Flush()
+	return cw.n, e
+}
+
+// quote returns s as a quoted Prometheus label value.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, e := c.w.Write(b)
+	c.n += int64(n)
+	return n, e
+}
@@ -0,0 +1,87 @@
+/*
+ * prometheus_test.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go Metrics
+
+package metrics_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/metrics"
+)
+
+func TestPrometheus(t *testing.T) {
+	m := metrics.NewPrometheus()
+	m.ObserveTransaction(fdb.TransactionStats{
+		Name: "checkout",
+		Attempts: []fdb.AttemptStats{
+			{ReadVersionLatency: time.Millisecond, CommitLatency: 3 * time.Millisecond, Bytes: 2000, Err: fdb.ErrNotCommitted},
+			{Err: fdb.ErrTransactionTimedOut},
+			{ReadVersionLatency: 20 * time.Millisecond, CommitLatency: 20 * time.Millisecond, Bytes: 2000},
+		},
+		Duration: 50 * time.Millisecond,
+	})
+	m.ObserveTransaction(fdb.TransactionStats{
+		Name:     `say "hi"`,
+		ReadOnly: true,
+		Attempts: []fdb.AttemptStats{{Err: fdb.Error{Code: 2000}}},
+		Err:      fdb.Error{Code: 2000},
+	})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %q", ct)
+	}
+
+	out := rec.Body.String()
+	for _, line := range []string{
+		`# TYPE fdb_transactions_total counter`,
+		`fdb_transactions_total{name="checkout",outcome="committed"} 1`,
+		`fdb_transactions_total{name="say \"hi\"",outcome="failed"} 1`,
+		`fdb_transaction_attempts_bucket{name="checkout",le="2"} 0`,
+		`fdb_transaction_attempts_bucket{name="checkout",le="3"} 1`,
+		`fdb_transaction_attempts_sum{name="checkout"} 3`,
+		`fdb_transaction_errors_total{name="checkout",code="1020"} 1`,
+		`fdb_transaction_errors_total{name="checkout",code="1031"} 1`,
+		`fdb_transaction_errors_total{name="say \"hi\"",code="2000"} 1`,
+		`fdb_transaction_conflicts_total{name="checkout"} 1`,
+		`fdb_transaction_timeouts_total{name="checkout"} 1`,
+		`fdb_transaction_written_bytes_count{name="checkout"} 2`,
+		`fdb_transaction_written_bytes_sum{name="checkout"} 4000`,
+		`fdb_transaction_read_version_seconds_bucket{name="checkout",le="0.001"} 1`,
+		`fdb_transaction_read_version_seconds_count{name="checkout"} 2`,
+		`fdb_transaction_commit_seconds_bucket{name="checkout",le="0.005"} 1`,
+		`fdb_transaction_commit_seconds_bucket{name="checkout",le="+Inf"} 2`,
+		`fdb_transaction_duration_seconds_bucket{name="checkout",le="0.05"} 1`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing %s", line)
+		}
+	}
+	if t.Failed() {
+		t.Log(out)
+	}
+}
@@ -28,6 +28,7 @@ import "C"
 
 import (
 	"fmt"
+	"iter"
 )
 
 // KeyValue represents a single key-value pair in the database.
@@ -42,6 +43,8 @@ type KeyValue struct {
 //
 // The zero value of RangeOptions represents the default range read
 // configuration (no limit, lexicographic order, to be used as an iterator).
+// RangeOptions literals should name their fields: fields may be added to
+// RangeOptions (as ReadAhead was), which breaks unkeyed literals.
 type RangeOptions struct {
 	// Limit restricts the number of key-value pairs returned as part of a range
 	// read. A value of 0 indicates no limit.
@@ -56,6 +59,16 @@ type RangeOptions struct {
 	// Limit is non-zero, the last Limit key-value pairs in the range are
 	// returned.
 	Reverse bool
+
+	// ReadAhead sets the number of batches of key-value pairs which a
+	// RangeIterator (or the All and GetSliceWithError methods of RangeResult)
+	// reads ahead of the batch being consumed, so that network round trips
+	// overlap with processing by the caller. A value of 0 (the default) reads
+	// each batch once the previous batch has been consumed, as does a negative
+	// value. Read-ahead is limited to 16 batches and to 8 MiB of key-value
+	// pairs which have been read but not yet consumed, and never reads beyond
+	// Limit.
+	ReadAhead int
 }
 
 // A Range describes all keys between a begin (inclusive) and end (exclusive)
@@ -120,16 +133,71 @@ func (sr SelectorRange) FDBRangeKeySelectors() (Selectable, Selectable) {
 // RangeResult is a handle to the asynchronous result of a range
 // read. RangeResult is safe for concurrent use by multiple goroutines.
 //
+// The key-value pairs of a RangeResult may be read all at once with
+// GetSliceWithError, incrementally with Iterator, or in a for-range loop with
+// All.
+//
 // A RangeResult should not be returned from a transactional function passed to
 // the Transact method of a Transactor.
 type RangeResult struct {
-	t        *transaction
-	sr       SelectorRange
-	options  RangeOptions
-	snapshot bool
-	f        *futureKeyValueArray
+	read    rangeReader
+	sr      SelectorRange
+	options RangeOptions
+	f       rangeFuture
+}
+
+// rangeReader issues one of the batched reads that make up a range read.
+type rangeReader func(sr SelectorRange, options RangeOptions, iteration int) rangeFuture
+
+// rangeFuture is the asynchronous result of a single batch of a range read.
+type rangeFuture interface {
+	Get() ([]KeyValue, bool, error)
+	IsReady() bool
+	Cancel()
+}
+
+// NewRangeResult returns a RangeResult for r which obtains its key-value pairs
+// by calling read. It allows implementations of ReadTransaction-like types
+// other than Transaction and Snapshot (such as in-memory fakes for testing) to
+// return a RangeResult from their GetRange methods.
+//
+// read is called once immediately, and then again each time more key-value
+// pairs are needed and the previous call returned more as true (when
+// options.ReadAhead is set, this may be before the key-value pairs returned by
+// the previous call have been consumed). On each call, sr and options are
+// adjusted to exclude the key-value pairs already returned, and iteration is
+// incremented (starting at 1, as for StreamingModeIterator).
+func NewRangeResult(r Range, options RangeOptions, read func(sr SelectorRange, options RangeOptions, iteration int) (kvs []KeyValue, more bool, e error)) RangeResult {
+	rr := func(sr SelectorRange, options RangeOptions, iteration int) rangeFuture {
+		kvs, more, e := read(sr, options, iteration)
+		return &readyRangeFuture{kvs, more, e}
+	}
+	begin, end := r.FDBRangeKeySelectors()
+	sr := SelectorRange{begin, end}
+	return RangeResult{
+		read:    rr,
+		sr:      sr,
+		options: options,
+		f:       rr(sr, options, 1),
+	}
+}
+
+type readyRangeFuture struct {
+	kvs  []KeyValue
+	more bool
+	e    error
+}
+
+func (f *readyRangeFuture) Get() ([]KeyValue, bool, error) {
+	return f.kvs, f.more, f.e
+}
+
+func (f *readyRangeFuture) IsReady() bool {
+	return true
 }
 
+func (f *readyRangeFuture) Cancel() {}
+
 // GetSliceWithError returns a slice of KeyValue objects satisfying the range
 // specified in the read that returned this RangeResult, or an error if any of
 // the asynchronous operations associated with this result did not successfully
@@ -150,9 +218,9 @@ func (rr RangeResult) GetSliceWithError() ([]KeyValue, error) {
 		if ri.err != nil {
 			return nil, ri.err
 		}
-		ret = append(ret, ri.kvs...)
+		ret = append(ret, ri.kvs[ri.index:]...)
 		ri.index = len(ri.kvs)
-		ri.fetchNextBatch()
+		ri.readAhead()
 	}
 
 	return ret, nil
@@ -175,12 +243,45 @@ func (rr RangeResult) GetSliceOrPanic() []KeyValue {
 // range specified in the read that returned this RangeResult.
 func (rr RangeResult) Iterator() *RangeIterator {
 	return &RangeIterator{
-		t:         rr.t,
+		read:      rr.read,
 		f:         rr.f,
 		sr:        rr.sr,
 		options:   rr.options,
 		iteration: 1,
-		snapshot:  rr.snapshot,
+		more:      true,
+	}
+}
+
+// All returns an iterator over the key-value pairs satisfying the range
+// specified in the read that returned this RangeResult, for use in a for-range
+// loop:
+//
+//	for kv, e := range tr.GetRange(r, fdb.RangeOptions{}).All() {
+//	    if e != nil {
+//	        return nil, e
+//	    }
+//	    // use kv
+//	}
+//
+// If one of the asynchronous operations associated with this range does not
+// successfully complete, its error is yielded with an empty KeyValue and the
+// iteration ends. Breaking out of the loop early cancels the read of the next
+// batch of key-value pairs, if one is already in flight.
+//
+// As with Iterator, each call to All starts a new iteration of the range, and
+// the returned iterator should not be used outside of the transactional
+// function in which the read was made.
+func (rr RangeResult) All() iter.Seq2[KeyValue, error] {
+	return func(yield func(KeyValue, error) bool) {
+		ri := rr.Iterator()
+		defer ri.cancel()
+
+		for ri.Advance() {
+			kv, e := ri.Get()
+			if !yield(kv, e) || e != nil {
+				return
+			}
+		}
 	}
 }
 
@@ -195,19 +296,42 @@ func (rr RangeResult) Iterator() *RangeIterator {
 // RangeResult and used concurrently. RangeIterator should not be returned from
 // a transactional function passed to the Transact method of a Transactor.
 type RangeIterator struct {
-	t         *transaction
-	f         *futureKeyValueArray
+	read rangeReader
+
+	// f is the read in flight, if any. sr, options (whose Limit is the number
+	// of key-value pairs still to be read) and iteration describe the most
+	// recently issued read until it completes, and then the read to follow
+	// it. more is false once no further reads are needed.
+	f         rangeFuture
 	sr        SelectorRange
 	options   RangeOptions
 	iteration int
-	done      bool
 	more      bool
-	kvs       []KeyValue
-	index     int
-	err       error
-	snapshot  bool
+
+	// kvs is the batch being consumed, and ahead the completed batches which
+	// follow it, holding aheadBytes bytes of keys and values.
+	kvs        []KeyValue
+	index      int
+	err        error
+	ahead      []rangeBatch
+	aheadBytes int
+	done       bool
 }
 
+// rangeBatch is a completed read of a RangeIterator which has not yet been
+// consumed.
+type rangeBatch struct {
+	kvs []KeyValue
+	err error
+}
+
+// Limits on the read-ahead of a RangeIterator, regardless of
+// RangeOptions.ReadAhead.
+const (
+	maxReadAhead      = 16
+	maxReadAheadBytes = 8 << 20
+)
+
 // Advance attempts to advance the iterator to the next key-value pair. Advance
 // returns true if there are more key-value pairs satisfying the range, or false
 // if the range has been exhausted. You must call this before every call to Get
@@ -217,42 +341,118 @@ func (ri *RangeIterator) Advance() bool {
 		return false
 	}
 
-	if ri.f == nil {
+	if ri.err != nil || ri.index < len(ri.kvs) {
 		return true
 	}
 
-	ri.kvs, ri.more, ri.err = ri.f.Get()
-	ri.index = 0
-	ri.f = nil
+	if len(ri.ahead) == 0 && ri.f == nil {
+		ri.readAhead()
+	}
+
+	var b rangeBatch
+	if len(ri.ahead) > 0 {
+		b = ri.ahead[0]
+		ri.ahead[0] = rangeBatch{}
+		ri.ahead = ri.ahead[1:]
+		ri.aheadBytes -= batchBytes(b.kvs)
+	} else if ri.f != nil {
+		b = ri.complete()
+	}
+
+	ri.kvs, ri.err, ri.index = b.kvs, b.err, 0
 
 	if ri.err != nil || len(ri.kvs) > 0 {
+		ri.readAhead()
 		return true
 	}
 
+	ri.done = true
 	return false
 }
 
-func (ri *RangeIterator) fetchNextBatch() {
-	if !ri.more || ri.index == ri.options.Limit {
-		ri.done = true
-		return
-	}
+// complete waits for the read in flight and prepares the read to follow it.
+func (ri *RangeIterator) complete() rangeBatch {
+	kvs, more, e := ri.f.Get()
+	ri.f = nil
+
+	ri.more = more && e == nil && len(kvs) > 0
 
 	if ri.options.Limit > 0 {
-		// Not worried about this being zero, checked equality above
-		ri.options.Limit -= ri.index
+		ri.options.Limit -= len(kvs)
+		if ri.options.Limit <= 0 {
+			ri.more = false
+		}
 	}
 
-	if ri.options.Reverse {
-		ri.sr.End = FirstGreaterOrEqual(ri.kvs[ri.index-1].Key)
-	} else {
-		ri.sr.Begin = FirstGreaterThan(ri.kvs[ri.index-1].Key)
+	if len(kvs) > 0 {
+		if ri.options.Reverse {
+			ri.sr.End = FirstGreaterOrEqual(kvs[len(kvs)-1].Key)
+		} else {
+			ri.sr.Begin = FirstGreaterThan(kvs[len(kvs)-1].Key)
+		}
 	}
 
-	ri.iteration++
+	return rangeBatch{kvs, e}
+}
 
-	f := ri.t.doGetRange(ri.sr, ri.options, ri.snapshot, ri.iteration)
-	ri.f = &f
+// readAhead issues reads until the number of batches following the current
+// one (whether completed or in flight) reaches the read-ahead depth. A read is
+// always issued once the current batch has been consumed, so that the next
+// batch is in flight while the caller processes the last key-value pair.
+// Completed reads are collected without blocking.
+func (ri *RangeIterator) readAhead() {
+	depth := ri.options.ReadAhead
+	if depth < 0 {
+		depth = 0
+	} else if depth > maxReadAhead {
+		depth = maxReadAhead
+	}
+	if ri.index >= len(ri.kvs) {
+		depth++
+	}
+
+	for {
+		if ri.f != nil {
+			if len(ri.ahead) >= depth || !ri.f.IsReady() {
+				return
+			}
+			b := ri.complete()
+			if b.err == nil && len(b.kvs) == 0 {
+				continue
+			}
+			ri.ahead = append(ri.ahead, b)
+			ri.aheadBytes += batchBytes(b.kvs)
+		}
+
+		if !ri.more || len(ri.ahead) >= depth || ri.aheadBytes >= maxReadAheadBytes {
+			return
+		}
+
+		ri.iteration++
+		ri.f = ri.read(ri.sr, ri.options, ri.iteration)
+	}
+}
+
+func batchBytes(kvs []KeyValue) int {
+	n := 0
+	for _, kv := range kvs {
+		n += len(kv.Key) + len(kv.Value)
+	}
+	return n
+}
+
+// cancel stops the iterator, cancelling the read in flight if there is one.
+// The first read is shared by every iterator of a RangeResult, but has always
+// completed (and so is never cancelled) by the time the iterator has returned
+// a key-value pair.
+func (ri *RangeIterator) cancel() {
+	if ri.f != nil && ri.iteration > 1 {
+		ri.f.Cancel()
+	}
+	ri.f = nil
+	ri.ahead = nil
+	ri.aheadBytes = 0
+	ri.done = true
 }
 
 // Get returns the next KeyValue in a range read, or an error if one of the
@@ -269,9 +469,7 @@ func (ri *RangeIterator) Get() (kv KeyValue, e error) {
 
 	ri.index++
 
-	if ri.index == len(ri.kvs) {
-		ri.fetchNextBatch()
-	}
+	ri.readAhead()
 
 	return
 }
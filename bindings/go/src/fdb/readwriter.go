@@ -0,0 +1,217 @@
+/*
+ * readwriter.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"context"
+)
+
+// A Reader can asynchronously read from a key-value store with the semantics
+// of a FoundationDB transaction. Reader is the subset of ReadTransaction which
+// does not depend on the concrete types of this package, so that it may also
+// be satisfied by alternative implementations of the transaction API (such as
+// in-memory fakes for testing, or wrappers which record or trace the
+// operations of another Reader). Transaction and Snapshot both satisfy the
+// Reader interface.
+//
+// All Readers satisfy the ReaderTransactor interface and may be used with
+// read-only transactional functions written against Reader.
+type Reader interface {
+	Get(key KeyConvertible) FutureByteSlice
+	GetKey(sel Selectable) FutureKey
+	GetRange(r Range, options RangeOptions) RangeResult
+	GetReadVersion() FutureInt64
+
+	// SnapshotReader returns a Reader which performs snapshot reads in the
+	// same transaction as the receiver. It corresponds to
+	// (ReadTransaction).Snapshot.
+	SnapshotReader() Reader
+
+	ReaderTransactor
+}
+
+// A ReadWriter can read from and write to a key-value store with the semantics
+// of a FoundationDB transaction. It extends Reader with the write, atomic,
+// conflict range and watch operations of Transaction, and, like Reader, does
+// not depend on the concrete types of this package. Transaction satisfies the
+// ReadWriter interface.
+//
+// All ReadWriters satisfy the ReadWriterTransactor interface and may be used
+// with transactional functions written against ReadWriter.
+type ReadWriter interface {
+	Reader
+
+	Set(key KeyConvertible, value []byte)
+	Clear(key KeyConvertible)
+	ClearRange(er ExactRange)
+
+	Add(key KeyConvertible, param []byte)
+	BitAnd(key KeyConvertible, param []byte)
+	BitOr(key KeyConvertible, param []byte)
+	BitXor(key KeyConvertible, param []byte)
+	AppendIfFits(key KeyConvertible, param []byte)
+	Max(key KeyConvertible, param []byte)
+	Min(key KeyConvertible, param []byte)
+	ByteMax(key KeyConvertible, param []byte)
+	ByteMin(key KeyConvertible, param []byte)
+	CompareAndClear(key KeyConvertible, param []byte)
+	SetVersionstampedKey(key KeyConvertible, param []byte)
+	SetVersionstampedValue(key KeyConvertible, param []byte)
+	GetVersionstamp() FutureKey
+
+	AddReadConflictRange(er ExactRange) error
+	AddReadConflictKey(key KeyConvertible) error
+	AddWriteConflictRange(er ExactRange) error
+	AddWriteConflictKey(key KeyConvertible) error
+
+	Watch(key KeyConvertible) FutureNil
+
+	ReadWriterTransactor
+}
+
+// A ReaderTransactor can execute a function that requires a Reader. It is the
+// counterpart of ReadTransactor for functions written against Reader, and is
+// satisfied by Database, Transaction and Snapshot as well as by alternative
+// implementations of the transaction API.
+type ReaderTransactor interface {
+	// ReadTransactReader executes the caller-provided function, providing it
+	// with a Reader (itself a ReaderTransactor, allowing composition of
+	// read-only transactional functions).
+	ReadTransactReader(func(Reader) (interface{}, error)) (interface{}, error)
+}
+
+// A ReadWriterTransactor can execute a function that requires a ReadWriter. It
+// is the counterpart of Transactor for functions written against ReadWriter,
+// and is satisfied by Database and Transaction as well as by alternative
+// implementations of the transaction API.
+//
+// Libraries which accept a ReadWriterTransactor rather than a Transactor (such
+// as the ReadWriter variants of the directory package functions) may be used
+// with any such implementation.
+type ReadWriterTransactor interface {
+	// TransactReadWriter executes the caller-provided function, providing it
+	// with a ReadWriter (itself a ReadWriterTransactor, allowing composition
+	// of transactional functions).
+	TransactReadWriter(func(ReadWriter) (interface{}, error)) (interface{}, error)
+
+	// All ReadWriterTransactors are also ReaderTransactors, allowing them to
+	// be used with read-only transactional functions.
+	ReaderTransactor
+}
+
+// TransactReadWriter calls t.TransactReadWriter with f, returning the value
+// produced by f as a T rather than as an interface{}. It is the counterpart of
+// Transact for functions written against ReadWriter.
+//
+// If t.TransactReadWriter returns an error, TransactReadWriter returns the zero
+// value of T along with that error.
+func TransactReadWriter[T any](t ReadWriterTransactor, f func(ReadWriter) (T, error)) (T, error) {
+	r, e := t.TransactReadWriter(func(tr ReadWriter) (interface{}, error) {
+		return f(tr)
+	})
+	if e != nil {
+		var zero T
+		return zero, e
+	}
+	v, _ := r.(T)
+	return v, nil
+}
+
+// ReadTransactReader calls t.ReadTransactReader with f, returning the value
+// produced by f as a T rather than as an interface{}. It is the counterpart of
+// ReadTransact for functions written against Reader.
+//
+// If t.ReadTransactReader returns an error, ReadTransactReader returns the
+// zero value of T along with that error.
+func ReadTransactReader[T any](t ReaderTransactor, f func(Reader) (T, error)) (T, error) {
+	r, e := t.ReadTransactReader(func(rtr Reader) (interface{}, error) {
+		return f(rtr)
+	})
+	if e != nil {
+		var zero T
+		return zero, e
+	}
+	v, _ := r.(T)
+	return v, nil
+}
+
+// SnapshotReader returns the Snapshot of the transaction as a Reader.
+func (t Transaction) SnapshotReader() Reader {
+	return t.Snapshot()
+}
+
+// SnapshotReader returns the receiver as a Reader.
+func (s Snapshot) SnapshotReader() Reader {
+	return s
+}
+
+// TransactReadWriter executes the caller-provided function, passing it the
+// Transaction receiver object (as a ReadWriter). It behaves exactly as
+// (Transaction).Transact.
+func (t Transaction) TransactReadWriter(f func(ReadWriter) (interface{}, error)) (interface{}, error) {
+	return t.Transact(func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	})
+}
+
+// ReadTransactReader executes the caller-provided function, passing it the
+// Transaction receiver object (as a Reader). It behaves exactly as
+// (Transaction).ReadTransact.
+func (t Transaction) ReadTransactReader(f func(Reader) (interface{}, error)) (interface{}, error) {
+	return t.ReadTransact(func(rtr ReadTransaction) (interface{}, error) {
+		return f(t)
+	})
+}
+
+// ReadTransactReader executes the caller-provided function, passing it the
+// Snapshot receiver object (as a Reader). It behaves exactly as
+// (Snapshot).ReadTransact.
+func (s Snapshot) ReadTransactReader(f func(Reader) (interface{}, error)) (interface{}, error) {
+	return s.ReadTransact(func(rtr ReadTransaction) (interface{}, error) {
+		return f(s)
+	})
+}
+
+// TransactReadWriter runs a caller-provided function inside a retry loop,
+// providing it with a newly created Transaction (as a ReadWriter). Retry,
+// commit and panic handling are exactly those of (Database).Transact.
+func (d Database) TransactReadWriter(f func(ReadWriter) (interface{}, error)) (interface{}, error) {
+	return d.Transact(func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	})
+}
+
+// ReadTransactReader runs a caller-provided function inside a retry loop,
+// providing it with a newly created Transaction (as a Reader). Retry and panic
+// handling are exactly those of (Database).ReadTransact.
+func (d Database) ReadTransactReader(f func(Reader) (interface{}, error)) (interface{}, error) {
+	return d.transact(context.Background(), func(tr Transaction) (interface{}, error) {
+		return f(tr)
+	}, true)
+}
+
+var (
+	_ ReadWriter           = Transaction{}
+	_ Reader               = Snapshot{}
+	_ ReadWriterTransactor = Database{}
+)
@@ -0,0 +1,420 @@
+/*
+ * scan.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"sync"
+)
+
+// ParallelScanOptions specify how ParallelScan reads a range.
+type ParallelScanOptions struct {
+	// Workers is the number of sub-ranges scanned concurrently. A value of 0
+	// scans 4 sub-ranges at a time.
+	Workers int
+
+	// Ordered delivers key-value pairs in key order. Otherwise key-value pairs
+	// are delivered as soon as they are read: in key order within each
+	// sub-range, but with the sub-ranges interleaved.
+	Ordered bool
+
+	// Boundaries, if not nil, are the keys at which the range is split into
+	// sub-ranges, instead of the shard boundaries reported by
+	// (Database).LocalityGetBoundaryKeys. Boundaries must be sorted; any
+	// outside the range are ignored.
+	Boundaries []Key
+
+	// Mode and ReadAhead are used for the range reads of each sub-range, as
+	// in RangeOptions.
+	Mode      StreamingMode
+	ReadAhead int
+
+	// Snapshot reads the range with snapshot reads, as ScanOptions.Snapshot.
+	Snapshot bool
+}
+
+// scanBatchSize is the number of key-value pairs delivered to the consumer of
+// a scan at a time.
+const scanBatchSize = 256
+
+// scanBatch is a group of consecutive key-value pairs read by a scan, or the
+// error which ended it.
+type scanBatch struct {
+	kvs []KeyValue
+	err error
+}
+
+// ParallelScan returns an iterator over the key-value pairs in er, for use in a
+// for-range loop. The range is split into sub-ranges at shard boundaries, and
+// up to options.Workers sub-ranges are read concurrently.
+//
+// When rt is a Database, the shard boundaries are found with
+// LocalityGetBoundaryKeys, and each sub-range is read with a series of
+// read-only transactions: a sub-range whose transaction becomes too old (such
+// as when the caller holds up the iteration for more than five seconds)
+// continues from the last key read, rather than starting over, so a scan is not
+// limited by the duration of a transaction. A scan is therefore not a
+// consistent snapshot of the range. When rt is not a Database and
+// options.Boundaries is nil, the range is read as a single sub-range.
+//
+// If a read fails, or ctx is done, the error is yielded with an empty KeyValue
+// and the iteration ends. Breaking out of the loop early stops all of the
+// sub-range reads.
+func ParallelScan(ctx context.Context, rt ReaderTransactor, er ExactRange, options ParallelScanOptions) iter.Seq2[KeyValue, error] {
+	return func(yield func(KeyValue, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		ranges, e := splitRange(rt, er, options.Boundaries)
+		if e != nil {
+			yield(KeyValue{}, e)
+			return
+		}
+
+		workers := options.Workers
+		if workers <= 0 {
+			workers = 4
+		}
+		if workers > len(ranges) {
+			workers = len(ranges)
+		}
+
+		ro := RangeOptions{Mode: options.Mode, ReadAhead: options.ReadAhead}
+
+		var results []chan scanBatch
+		if options.Ordered {
+			// Each sub-range has its own channel, consumed in order
+			results = make([]chan scanBatch, len(ranges))
+			for i := range results {
+				results[i] = make(chan scanBatch, 1)
+			}
+		} else {
+			shared := make(chan scanBatch, workers)
+			results = []chan scanBatch{shared}
+		}
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, workers)
+
+		go func() {
+			for i, kr := range ranges {
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+
+				out := results[0]
+				if options.Ordered {
+					out = results[i]
+				}
+
+				wg.Add(1)
+				go func(kr KeyRange, out chan scanBatch) {
+					defer wg.Done()
+					defer func() { <-sem }()
+					if options.Ordered {
+						defer close(out)
+					}
+
+					// A batch is kept by the worker until it is delivered, so
+					// a retried transaction may continue from the cursor
+					batch := make([]KeyValue, 0, scanBatchSize)
+					flush := func() bool {
+						select {
+						case out <- scanBatch{kvs: batch}:
+							batch = make([]KeyValue, 0, scanBatchSize)
+							return true
+						case <-ctx.Done():
+							return false
+						}
+					}
+
+					scan := &rangeScan{begin: kr.Begin.FDBKey(), end: kr.End.FDBKey(), options: ro, snapshot: options.Snapshot}
+					e := scan.run(ctx, rt, func(kv KeyValue) bool {
+						batch = append(batch, kv)
+						return len(batch) < scanBatchSize || flush()
+					})
+					if e == nil && len(batch) > 0 && !flush() {
+						return
+					}
+					if e != nil && e != errScanStopped {
+						select {
+						case out <- scanBatch{err: e}:
+						case <-ctx.Done():
+						}
+					}
+				}(kr, out)
+			}
+
+			if !options.Ordered {
+				wg.Wait()
+				close(results[0])
+			}
+		}()
+
+		for _, out := range results {
+			for {
+				if e := ctx.Err(); e != nil {
+					yield(KeyValue{}, e)
+					return
+				}
+
+				var b scanBatch
+				var ok bool
+				select {
+				case b, ok = <-out:
+				case <-ctx.Done():
+					yield(KeyValue{}, ctx.Err())
+					return
+				}
+				if !ok {
+					break
+				}
+				if b.err != nil {
+					yield(KeyValue{}, b.err)
+					return
+				}
+				for _, kv := range b.kvs {
+					if !yield(kv, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// splitRange splits er at boundaries or, if boundaries is nil and rt is a
+// Database, at the shard boundaries within er.
+func splitRange(rt ReaderTransactor, er ExactRange, boundaries []Key) ([]KeyRange, error) {
+	bk, ek := er.FDBRangeKeys()
+	begin, end := bk.FDBKey(), ek.FDBKey()
+
+	if boundaries == nil {
+		if d, ok := rt.(Database); ok {
+			var e error
+			boundaries, e = d.LocalityGetBoundaryKeys(er, 0, 0)
+			if e != nil {
+				return nil, e
+			}
+		}
+	}
+
+	var ranges []KeyRange
+	for _, b := range boundaries {
+		if bytes.Compare(b, begin) <= 0 || bytes.Compare(b, end) >= 0 {
+			continue
+		}
+		ranges = append(ranges, KeyRange{Begin: begin, End: b})
+		begin = b
+	}
+	ranges = append(ranges, KeyRange{Begin: begin, End: end})
+
+	return ranges, nil
+}
+
+// ScanOptions specify how a Scanner reads a range.
+type ScanOptions struct {
+	// Mode and ReadAhead are used for the range reads of the scan, as in
+	// RangeOptions.
+	Mode      StreamingMode
+	ReadAhead int
+
+	// Cursor, if not nil, is a key returned by (*Scanner).Cursor from an
+	// earlier scan of the same range. The scan starts after Cursor.
+	Cursor Key
+
+	// PinReadVersion makes every transaction of the scan read at the read
+	// version of the first, so that the scan sees a consistent snapshot of the
+	// range, for as long as that version remains readable (about five seconds
+	// after it was committed). After that the scan continues at current read
+	// versions, as if PinReadVersion were false; see (*Scanner).ReadVersion.
+	PinReadVersion bool
+
+	// Snapshot reads the range with snapshot reads (see (Transaction).Snapshot).
+	// This only matters when the Scanner reads with the transactions of a
+	// Transaction or other ReadWriter, which otherwise add the range read to
+	// their read conflict ranges, as GetRange does. The transactions created
+	// for a Database are read-only, so never conflict.
+	Snapshot bool
+}
+
+// A Scanner reads a range of keys with a series of transactions, keeping track
+// of the last key read. When a transaction fails with transaction_too_old (or
+// any other retryable error), the Scanner continues from the key after the last
+// one read with a new transaction, instead of starting over as a retried
+// (Database).ReadTransact would. A Scanner is therefore able to read a range
+// which takes longer than the five second transaction limit to read, or whose
+// caller takes that long to process it.
+//
+// The position of a Scanner may be saved with Cursor, and a later scan of the
+// same range started from it with ScanOptions.Cursor.
+//
+// When the Scanner reads with a Transaction, the range it reads is added to the
+// read conflict ranges of the Transaction, unless ScanOptions.Snapshot is set.
+//
+// A Scanner may not be used by multiple goroutines at once.
+type Scanner struct {
+	rt   ReaderTransactor
+	scan rangeScan
+}
+
+// NewScanner returns a Scanner which reads er using the transactions of rt.
+// When rt is a Database, each transaction of the scan is read-only and created
+// by rt; when rt is a Transaction or other ReaderTransactor, transactions are
+// retried only as rt allows.
+func NewScanner(rt ReaderTransactor, er ExactRange, options ScanOptions) *Scanner {
+	bk, ek := er.FDBRangeKeys()
+	return &Scanner{
+		rt: rt,
+		scan: rangeScan{
+			begin:    bk.FDBKey(),
+			end:      ek.FDBKey(),
+			options:  RangeOptions{Mode: options.Mode, ReadAhead: options.ReadAhead},
+			cursor:   options.Cursor,
+			pin:      options.PinReadVersion,
+			snapshot: options.Snapshot,
+		},
+	}
+}
+
+// All returns an iterator over the key-value pairs in the range of the
+// Scanner following its cursor, for use in a for-range loop. Each key-value
+// pair advances the cursor, so iterating again after breaking out of a loop
+// continues after the last key-value pair yielded.
+//
+// The loop body runs inside the transactions of the scan, but its key-value
+// pairs are not repeated when a transaction is retried. If the scan fails, or
+// ctx is done, the error is yielded with an empty KeyValue and the iteration
+// ends.
+func (s *Scanner) All(ctx context.Context) iter.Seq2[KeyValue, error] {
+	return func(yield func(KeyValue, error) bool) {
+		e := s.scan.run(ctx, s.rt, func(kv KeyValue) bool {
+			return yield(kv, nil)
+		})
+		if e != nil && e != errScanStopped {
+			yield(KeyValue{}, e)
+		}
+	}
+}
+
+// Cursor returns the last key read by the Scanner, or nil if it has not read
+// any key.
+func (s *Scanner) Cursor() Key {
+	return s.scan.cursor
+}
+
+// ReadVersion returns the read version to which the transactions of the
+// Scanner are pinned, if ScanOptions.PinReadVersion was set, and whether every
+// key-value pair read so far was read at that version. The version is 0 until
+// the first transaction has begun.
+func (s *Scanner) ReadVersion() (version int64, pinned bool) {
+	return s.scan.readVersion, s.scan.pin
+}
+
+// rangeScan is the state of a read of a range with a series of transactions.
+type rangeScan struct {
+	begin, end Key
+	options    RangeOptions
+	snapshot   bool
+
+	// cursor is the last key read, or nil if no key has been read.
+	cursor Key
+
+	// pin is true while every transaction should read at readVersion (which
+	// is 0 until it has been chosen by the first transaction).
+	pin         bool
+	readVersion int64
+}
+
+// errScanStopped is returned by run when send returns false.
+var errScanStopped = errors.New("scan stopped")
+
+// run reads the key-value pairs of the range following the cursor with as many
+// transactions as necessary, passing them to send one at a time and advancing
+// the cursor. A transaction which is retried (such as after
+// transaction_too_old) continues from the cursor, so no key-value pair is
+// passed to send twice. run stops early, returning errScanStopped, if send
+// returns false.
+//
+// If the pinned read version is no longer readable, it is abandoned and the
+// transaction is retried at a current read version.
+func (s *rangeScan) run(ctx context.Context, rt ReaderTransactor, send func(KeyValue) bool) error {
+	f := func(r Reader) (interface{}, error) {
+		if s.pin {
+			if s.readVersion == 0 {
+				v, e := r.GetReadVersion().Get()
+				if e != nil {
+					return nil, e
+				}
+				s.readVersion = v
+			} else if vs, ok := r.(interface{ SetReadVersion(int64) }); ok {
+				vs.SetReadVersion(s.readVersion)
+			}
+		}
+
+		sr := SelectorRange{Begin: FirstGreaterOrEqual(s.begin), End: FirstGreaterOrEqual(s.end)}
+		if s.cursor != nil {
+			sr.Begin = FirstGreaterThan(s.cursor)
+		}
+
+		if s.snapshot {
+			r = r.SnapshotReader()
+		}
+		for kv, e := range r.GetRange(sr, s.options).All() {
+			if e != nil {
+				if s.pin && errors.Is(e, ErrTransactionTooOld) {
+					s.pin = false
+				}
+				return nil, e
+			}
+			ok := send(kv)
+			s.cursor = kv.Key
+			if !ok {
+				return nil, errScanStopped
+			}
+		}
+
+		return nil, nil
+	}
+
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+
+	// A Database retry loop also stops retrying once ctx is done
+	var e error
+	if d, ok := rt.(Database); ok {
+		_, e = d.transact(ctx, func(tr Transaction) (interface{}, error) {
+			return f(tr)
+		}, true)
+	} else {
+		_, e = rt.ReadTransactReader(f)
+	}
+
+	return e
+}
@@ -35,6 +35,8 @@ package subspace
 import (
 	"bytes"
 	"errors"
+	"iter"
+
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 )
@@ -133,6 +135,45 @@ func (s subspace) FDBRangeKeySelectors() (fdb.Selectable, fdb.Selectable) {
 	return fdb.FirstGreaterOrEqual(begin), fdb.FirstGreaterOrEqual(end)
 }
 
+// KeyValue is a key-value pair whose key has been unpacked against a Subspace,
+// as yielded by All.
+type KeyValue struct {
+	Key   tuple.Tuple
+	Value []byte
+}
+
+// All returns an iterator over the key-value pairs of rr, yielding each key
+// unpacked against s, for use in a for-range loop:
+//
+//	for kv, e := range subspace.All(s, tr.GetRange(s, fdb.RangeOptions{})) {
+//	    if e != nil {
+//	        return nil, e
+//	    }
+//	    // use kv.Key (a tuple.Tuple) and kv.Value
+//	}
+//
+// If a read fails, or a key is not in s or does not encode a well-formed Tuple,
+// the error is yielded with an empty KeyValue and the iteration ends. Breaking
+// out of the loop early cancels any outstanding read, as for
+// (fdb.RangeResult).All.
+func All(s Subspace, rr fdb.RangeResult) iter.Seq2[KeyValue, error] {
+	return func(yield func(KeyValue, error) bool) {
+		for kv, e := range rr.All() {
+			var t tuple.Tuple
+			if e == nil {
+				t, e = s.Unpack(kv.Key)
+			}
+			if e != nil {
+				yield(KeyValue{}, e)
+				return
+			}
+			if !yield(KeyValue{Key: t, Value: kv.Value}, nil) {
+				return
+			}
+		}
+	}
+}
+
 func concat(a []byte, b ...byte) []byte {
 	r := make([]byte, len(a)+len(b))
 	copy(r, a)
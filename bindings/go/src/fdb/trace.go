@@ -0,0 +1,331 @@
+/*
+ * trace.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"context"
+	"sync"
+)
+
+// A Tracer creates the spans which trace the transactions of a Database. A
+// Tracer may be attached to a Database with (Database).WithTracer.
+//
+// Tracer and Span are modelled on the corresponding interfaces of
+// OpenTelemetry, so that an adapter to OpenTelemetry (or another tracing
+// library) need only forward each method.
+//
+// Each attempt of a transaction run by (Database).Transact and related methods
+// is traced by a span named "fdb.transaction", whose parent is taken from the
+// context passed to TransactContext or ReadTransactContext. Within it, the
+// reads and commit of the attempt are traced by child spans named "fdb.Get",
+// "fdb.GetRange" (one for each batch of a range read), "fdb.GetReadVersion"
+// and "fdb.Commit". A child span ends when its future is first waited on, or
+// when the attempt ends if the future is never waited on. Operations of a
+// Transaction created by (Database).CreateTransaction are traced by spans
+// without a parent.
+//
+// Span attributes include the keys read (in the form produced by Printable, and
+// truncated to 64 bytes), the number of key-value pairs returned, and the code
+// of any Error. If the span of an attempt has a trace ID, it is set as the
+// debug transaction identifier of the transaction, and transaction logging is
+// enabled, so that client trace logs can be correlated with the trace.
+type Tracer interface {
+	// Start creates a span with the provided name, whose parent is the span
+	// of ctx (if any), and returns a context containing the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span is a traced operation created by a Tracer.
+type Span interface {
+	// SetAttributes sets attributes of the span.
+	SetAttributes(attrs ...Attribute)
+
+	// RecordError records that the operation failed with e.
+	RecordError(e error)
+
+	// End completes the span. No other methods are called after End.
+	End()
+
+	// TraceID returns the ID of the trace to which the span belongs, or "" if
+	// it has none.
+	TraceID() string
+}
+
+// Attribute is a key-value pair describing a Span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// WithTracer returns a copy of d whose transactions are traced by t. A nil t
+// removes any Tracer.
+func (d Database) WithTracer(t Tracer) Database {
+	return d.withConfig(func(c *databaseConfig) {
+		c.tracer = t
+	})
+}
+
+func (d Database) tracer() Tracer {
+	if d.config == nil {
+		return nil
+	}
+	return d.config.tracer
+}
+
+// maxTraceKeyLength is the length to which keys are truncated in attributes.
+const maxTraceKeyLength = 64
+
+func keyAttribute(name string, key []byte) Attribute {
+	s := Printable(key)
+	if len(s) > maxTraceKeyLength {
+		s = s[:maxTraceKeyLength] + "..."
+	}
+	return Attribute{name, s}
+}
+
+// transactionTrace is the tracing state of a transaction: the context in which
+// its child spans are created, and those spans which have not yet ended.
+type transactionTrace struct {
+	tracer Tracer
+	ctx    context.Context
+
+	mu   sync.Mutex
+	open map[*childSpan]struct{}
+}
+
+func newTransactionTrace(tracer Tracer, ctx context.Context) *transactionTrace {
+	return &transactionTrace{tracer: tracer, ctx: ctx, open: make(map[*childSpan]struct{})}
+}
+
+// childSpan is the span of an operation of a transaction, which ends once.
+type childSpan struct {
+	tt   *transactionTrace
+	span Span
+	once sync.Once
+}
+
+// startSpan starts a child span of the transaction, or returns nil if the
+// transaction is not traced.
+func (t *transaction) startSpan(name string, attrs ...Attribute) *childSpan {
+	tt := t.trace.Load()
+	if tt == nil {
+		return nil
+	}
+
+	_, span := tt.tracer.Start(tt.ctx, name)
+	span.SetAttributes(attrs...)
+	c := &childSpan{tt: tt, span: span}
+
+	tt.mu.Lock()
+	tt.open[c] = struct{}{}
+	tt.mu.Unlock()
+
+	return c
+}
+
+// end ends the span with the outcome of its operation, unless it has already
+// ended.
+func (c *childSpan) end(e error, attrs ...Attribute) {
+	if c == nil {
+		return
+	}
+	c.once.Do(func() {
+		c.tt.mu.Lock()
+		delete(c.tt.open, c)
+		c.tt.mu.Unlock()
+
+		endSpan(c.span, e, attrs...)
+	})
+}
+
+// endAll ends the child spans which are still open.
+func (tt *transactionTrace) endAll() {
+	tt.mu.Lock()
+	open := make([]*childSpan, 0, len(tt.open))
+	for c := range tt.open {
+		open = append(open, c)
+	}
+	tt.mu.Unlock()
+
+	for _, c := range open {
+		c.end(nil, Attribute{"fdb.abandoned", true})
+	}
+}
+
+func endSpan(span Span, e error, attrs ...Attribute) {
+	if e != nil {
+		if ep, ok := e.(Error); ok {
+			attrs = append(attrs, Attribute{"fdb.error_code", ep.Code})
+		}
+		span.RecordError(e)
+	}
+	span.SetAttributes(attrs...)
+	span.End()
+}
+
+// attemptTrace is the span of one attempt of a transaction run by
+// (Database).transact.
+type attemptTrace struct {
+	span Span
+	tt   *transactionTrace
+	tr   *transaction
+}
+
+// beginAttemptTrace starts the span of an attempt of tr, and traces the
+// operations of tr as its children. It returns nil if d has no Tracer.
+func (d Database) beginAttemptTrace(ctx context.Context, tr Transaction, attempt int, readOnly bool) *attemptTrace {
+	tracer := d.tracer()
+	if tracer == nil {
+		return nil
+	}
+
+	ctx, span := tracer.Start(ctx, "fdb.transaction")
+	span.SetAttributes(
+		Attribute{"fdb.name", d.Name()},
+		Attribute{"fdb.attempt", attempt},
+		Attribute{"fdb.read_only", readOnly},
+	)
+
+	if id := span.TraceID(); id != "" {
+		tr.Options().SetDebugTransactionIdentifier(id)
+		tr.Options().SetLogTransaction()
+	}
+
+	at := &attemptTrace{span: span, tt: newTransactionTrace(tracer, ctx), tr: tr.transaction}
+	tr.trace.Store(at.tt)
+	return at
+}
+
+// end ends the span of the attempt, which failed with e (if not nil), along
+// with any of its child spans which are still open.
+func (at *attemptTrace) end(e error) {
+	if at == nil {
+		return
+	}
+	at.tr.trace.CompareAndSwap(at.tt, nil)
+	at.tt.endAll()
+	endSpan(at.span, e)
+}
+
+type tracedFutureByteSlice struct {
+	FutureByteSlice
+	s *childSpan
+}
+
+func (f tracedFutureByteSlice) Get() ([]byte, error) {
+	v, e := f.FutureByteSlice.Get()
+	f.s.end(e, Attribute{"fdb.present", v != nil}, Attribute{"fdb.value_size", len(v)})
+	return v, e
+}
+
+func (f tracedFutureByteSlice) GetContext(ctx context.Context) ([]byte, error) {
+	if e := waitContext(ctx, f); e != nil {
+		f.s.end(e)
+		return nil, e
+	}
+	return f.Get()
+}
+
+func (f tracedFutureByteSlice) MustGet() []byte {
+	v, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return v
+}
+
+func (f tracedFutureByteSlice) onReady(fn func()) {
+	whenReady(f.FutureByteSlice, fn)
+}
+
+type tracedFutureInt64 struct {
+	FutureInt64
+	s *childSpan
+}
+
+func (f tracedFutureInt64) Get() (int64, error) {
+	v, e := f.FutureInt64.Get()
+	f.s.end(e, Attribute{"fdb.version", v})
+	return v, e
+}
+
+func (f tracedFutureInt64) GetContext(ctx context.Context) (int64, error) {
+	if e := waitContext(ctx, f); e != nil {
+		f.s.end(e)
+		return 0, e
+	}
+	return f.Get()
+}
+
+func (f tracedFutureInt64) MustGet() int64 {
+	v, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return v
+}
+
+func (f tracedFutureInt64) onReady(fn func()) {
+	whenReady(f.FutureInt64, fn)
+}
+
+type tracedFutureNil struct {
+	FutureNil
+	s *childSpan
+}
+
+func (f tracedFutureNil) Get() error {
+	e := f.FutureNil.Get()
+	f.s.end(e)
+	return e
+}
+
+func (f tracedFutureNil) GetContext(ctx context.Context) error {
+	if e := waitContext(ctx, f); e != nil {
+		f.s.end(e)
+		return e
+	}
+	return f.Get()
+}
+
+func (f tracedFutureNil) MustGet() {
+	if e := f.Get(); e != nil {
+		panic(e)
+	}
+}
+
+type tracedRangeFuture struct {
+	rangeFuture
+	s *childSpan
+}
+
+func (f tracedRangeFuture) Get() ([]KeyValue, bool, error) {
+	kvs, more, e := f.rangeFuture.Get()
+	f.s.end(e, Attribute{"fdb.rows", len(kvs)}, Attribute{"fdb.more", more})
+	return kvs, more, e
+}
+
+func (f tracedRangeFuture) Cancel() {
+	f.rangeFuture.Cancel()
+	f.s.end(nil, Attribute{"fdb.cancelled", true})
+}
@@ -26,6 +26,10 @@ package fdb
 // #include <foundationdb/fdb_c.h>
 import "C"
 
+import (
+	"sync/atomic"
+)
+
 // A ReadTransaction can asynchronously read from a FoundationDB
 // database. Transaction and Snapshot both satisfy the ReadTransaction
 // interface.
@@ -69,6 +73,14 @@ type Transaction struct {
 type transaction struct {
 	ptr *C.FDBTransaction
 	db  Database
+
+	// trace is the tracing state of the transaction, or nil if it is not
+	// traced.
+	trace atomic.Pointer[transactionTrace]
+
+	// readVersion times the read version of the current attempt of a
+	// Database retry loop which records metrics, or is nil.
+	readVersion atomic.Pointer[readVersionTimer]
 }
 
 // TransactionOptions is a handle with which to set options that affect a
@@ -79,15 +91,31 @@ type TransactionOptions struct {
 }
 
 func (opt TransactionOptions) setOpt(code int, param []byte) error {
-	return setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
+	e := setOpt(func(p *C.uint8_t, pl C.int) C.fdb_error_t {
 		return C.fdb_transaction_set_option(opt.transaction.ptr, C.FDBTransactionOption(code), p, pl)
 	}, param)
+	if e != nil {
+		logOptionError(opt.transaction.db.logger(), "transaction", code, e)
+	}
+	return e
 }
 
 func (t *transaction) destroy() {
 	C.fdb_transaction_destroy(t.ptr)
 }
 
+// timeReadVersion starts the read version timer of the transaction, if any.
+// It is called before each read, as the client requests the read version of a
+// transaction for its first read and shares it with GetReadVersion, so timing
+// it requests no additional read version.
+func (t *transaction) timeReadVersion() {
+	if rt := t.readVersion.Load(); rt != nil {
+		rt.start(func() *future {
+			return newFuture(C.fdb_transaction_get_read_version(t.ptr))
+		})
+	}
+}
+
 // GetDatabase returns a handle to the database with which this transaction is
 // interacting.
 func (t Transaction) GetDatabase() Database {
@@ -198,9 +226,14 @@ func (t Transaction) OnError(e Error) FutureNil {
 // see
 // https://apple.github.io/foundationdb/developer-guide.html#transactions-with-unknown-results.
 func (t Transaction) Commit() FutureNil {
-	return &futureNil{
+	s := t.startSpan("fdb.Commit")
+	f := &futureNil{
 		future: newFuture(C.fdb_transaction_commit(t.ptr)),
 	}
+	if s != nil {
+		return tracedFutureNil{f, s}
+	}
+	return f
 }
 
 // Watch creates a watch and returns a FutureNil that will become ready when the
@@ -240,7 +273,9 @@ func (t Transaction) Watch(key KeyConvertible) FutureNil {
 }
 
 func (t *transaction) get(key []byte, snapshot int) FutureByteSlice {
-	return &futureByteSlice{
+	t.timeReadVersion()
+	s := t.startSpan("fdb.Get", keyAttribute("fdb.key", key), Attribute{"fdb.snapshot", snapshot != 0})
+	f := &futureByteSlice{
 		future: newFuture(C.fdb_transaction_get(
 			t.ptr,
 			byteSliceToPtr(key),
@@ -248,6 +283,10 @@ func (t *transaction) get(key []byte, snapshot int) FutureByteSlice {
 			C.fdb_bool_t(snapshot),
 		)),
 	}
+	if s != nil {
+		return tracedFutureByteSlice{f, s}
+	}
+	return f
 }
 
 // Get returns the (future) value associated with the specified key. The read is
@@ -264,6 +303,7 @@ func (t *transaction) doGetRange(r Range, options RangeOptions, snapshot bool, i
 	bkey := bsel.Key.FDBKey()
 	ekey := esel.Key.FDBKey()
 
+	t.timeReadVersion()
 	return futureKeyValueArray{
 		future: newFuture(C.fdb_transaction_get_range(
 			t.ptr,
@@ -285,14 +325,31 @@ func (t *transaction) doGetRange(r Range, options RangeOptions, snapshot bool, i
 }
 
 func (t *transaction) getRange(r Range, options RangeOptions, snapshot bool) RangeResult {
-	f := t.doGetRange(r, options, snapshot, 1)
+	read := func(sr SelectorRange, options RangeOptions, iteration int) rangeFuture {
+		var s *childSpan
+		if t.trace.Load() != nil {
+			begin, end := sr.FDBRangeKeySelectors()
+			s = t.startSpan("fdb.GetRange",
+				keyAttribute("fdb.begin", begin.FDBKeySelector().Key.FDBKey()),
+				keyAttribute("fdb.end", end.FDBKeySelector().Key.FDBKey()),
+				Attribute{"fdb.limit", options.Limit},
+				Attribute{"fdb.reverse", options.Reverse},
+				Attribute{"fdb.iteration", iteration},
+				Attribute{"fdb.snapshot", snapshot},
+			)
+		}
+		f := t.doGetRange(sr, options, snapshot, iteration)
+		if s != nil {
+			return tracedRangeFuture{&f, s}
+		}
+		return &f
+	}
 	begin, end := r.FDBRangeKeySelectors()
 	return RangeResult{
-		t:        t,
-		sr:       SelectorRange{begin, end},
-		options:  options,
-		snapshot: snapshot,
-		f:        &f,
+		read:    read,
+		sr:      SelectorRange{begin, end},
+		options: options,
+		f:       read(SelectorRange{begin, end}, options, 1),
 	}
 }
 
@@ -306,9 +363,15 @@ func (t Transaction) GetRange(r Range, options RangeOptions) RangeResult {
 }
 
 func (t *transaction) getReadVersion() FutureInt64 {
-	return &futureInt64{
+	t.timeReadVersion()
+	s := t.startSpan("fdb.GetReadVersion")
+	f := &futureInt64{
 		future: newFuture(C.fdb_transaction_get_read_version(t.ptr)),
 	}
+	if s != nil {
+		return tracedFutureInt64{f, s}
+	}
+	return f
 }
 
 // (Infrequently used) GetReadVersion returns the (future) transaction read version. The read is
@@ -403,6 +466,7 @@ func boolToInt(b bool) int {
 
 func (t *transaction) getKey(sel KeySelector, snapshot int) FutureKey {
 	key := sel.Key.FDBKey()
+	t.timeReadVersion()
 	return &futureKey{
 		future: newFuture(C.fdb_transaction_get_key(
 			t.ptr,
@@ -0,0 +1,258 @@
+/*
+ * compare.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go Tuple Layer
+
+package tuple
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Compare returns an integer comparing two tuples in the order of their packed
+// encodings: the result is 0 if a.Pack() and b.Pack() are equal, -1 if a.Pack()
+// sorts before b.Pack(), and +1 otherwise. Compare does not pack the tuples,
+// and is considerably faster than comparing their encodings.
+//
+// Tuples are ordered element by element, and a tuple sorts before any longer
+// tuple of which it is a prefix. Elements of different types are ordered by
+// type: nil, byte strings, strings, nested tuples, integers, float32s,
+// float64s, bools, UUIDs and Versionstamps. Integers are ordered by value
+// (whatever their Go types), and float32s and float64s in numeric order, with
+// NaNs ordered by sign beyond the infinities.
+//
+// Compare panics with an *EncodeError if it reaches an element which Pack could
+// not encode, except that it accepts incomplete Versionstamps, which are
+// ordered after every complete Versionstamp.
+func Compare(a, b Tuple) int {
+	c, err := compareTuples(a, b)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func compareTuples(a, b Tuple) (int, *EncodeError) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ea, err := normalize(a[i])
+		if err != nil {
+			return 0, newEncodeError(i, a[i], err)
+		}
+		eb, err := normalize(b[i])
+		if err != nil {
+			return 0, newEncodeError(i, b[i], err)
+		}
+
+		c, ee := compareElements(ea, eb)
+		if ee != nil {
+			return 0, ee.nest(i)
+		}
+		if c != 0 {
+			return c, nil
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1, nil
+	case len(a) > len(b):
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// normalize returns the element with which e would be unpacked (except that
+// integers may be returned as int64, uint64 or *big.Int whatever their values,
+// and nested tuples are not normalized).
+func normalize(e TupleElement) (TupleElement, error) {
+	switch e := e.(type) {
+	case nil, bool, int64, uint64, []byte, string, float32, float64, UUID, Versionstamp, Tuple:
+		return e, nil
+	case *big.Int:
+		if e == nil {
+			return nil, errNilBigInt
+		}
+		if len(e.Bytes()) > 0xff {
+			return nil, errBigIntTooLarge
+		}
+		return e, nil
+	case int:
+		return int64(e), nil
+	case int32:
+		return int64(e), nil
+	case uint:
+		return uint64(e), nil
+	case uint32:
+		return uint64(e), nil
+	}
+
+	p := packer{versionstampPos: -1}
+	if err := p.encodeElement(e, false, true); err != nil {
+		if ee, ok := err.(*EncodeError); ok {
+			return nil, ee.Err
+		}
+		return nil, err
+	}
+	t, _, err := decodeTuple(p.buf, false)
+	if err != nil {
+		return nil, err
+	}
+	return t[0], nil
+}
+
+// typeOrder returns the position in the order of types of the normalized
+// element e, which is its type code (or that of every integer, or of false).
+func typeOrder(e TupleElement) byte {
+	switch e.(type) {
+	case nil:
+		return nilCode
+	case []byte:
+		return bytesCode
+	case string:
+		return stringCode
+	case Tuple:
+		return nestedCode
+	case int64, uint64, *big.Int:
+		return intZeroCode
+	case float32:
+		return floatCode
+	case float64:
+		return doubleCode
+	case bool:
+		return falseCode
+	case UUID:
+		return uuidCode
+	case Versionstamp:
+		return versionstampCode
+	}
+	panic("unnormalized tuple element")
+}
+
+func compareElements(a, b TupleElement) (int, *EncodeError) {
+	ta, tb := typeOrder(a), typeOrder(b)
+	if ta != tb {
+		if ta < tb {
+			return -1, nil
+		}
+		return 1, nil
+	}
+
+	switch a := a.(type) {
+	case nil:
+		return 0, nil
+	case []byte:
+		return bytes.Compare(a, b.([]byte)), nil
+	case string:
+		return strings.Compare(a, b.(string)), nil
+	case Tuple:
+		return compareTuples(a, b.(Tuple))
+	case float32:
+		return compareUint64(uint64(orderedFloat32(a)), uint64(orderedFloat32(b.(float32)))), nil
+	case float64:
+		return compareUint64(orderedFloat64(a), orderedFloat64(b.(float64))), nil
+	case bool:
+		bb := b.(bool)
+		switch {
+		case a == bb:
+			return 0, nil
+		case bb:
+			return -1, nil
+		}
+		return 1, nil
+	case UUID:
+		bu := b.(UUID)
+		return bytes.Compare(a[:], bu[:]), nil
+	case Versionstamp:
+		bv := b.(Versionstamp)
+		if c := bytes.Compare(a.TransactionVersion[:], bv.TransactionVersion[:]); c != 0 {
+			return c, nil
+		}
+		return compareUint64(uint64(a.UserVersion), uint64(bv.UserVersion)), nil
+	}
+
+	return compareInts(a, b), nil
+}
+
+func compareUint64(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// orderedFloat32 and orderedFloat64 return the bits of f transformed as they
+// are when f is packed, so that they are ordered as f is in a packed tuple.
+func orderedFloat32(f float32) uint32 {
+	bits := math.Float32bits(f)
+	if bits&(1<<31) != 0 {
+		return ^bits
+	}
+	return bits | 1<<31
+}
+
+func orderedFloat64(f float64) uint64 {
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		return ^bits
+	}
+	return bits | 1<<63
+}
+
+// compareInts compares two integers, each of which is an int64, uint64 or
+// *big.Int.
+func compareInts(a, b TupleElement) int {
+	switch a := a.(type) {
+	case int64:
+		switch b := b.(type) {
+		case int64:
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		case uint64:
+			if a < 0 {
+				return -1
+			}
+			return compareUint64(uint64(a), b)
+		}
+	case uint64:
+		switch b := b.(type) {
+		case int64:
+			if b < 0 {
+				return 1
+			}
+			return compareUint64(a, uint64(b))
+		case uint64:
+			return compareUint64(a, b)
+		}
+	}
+	ba, _ := toBigInt(a)
+	bb, _ := toBigInt(b)
+	return ba.Cmp(bb)
+}
@@ -0,0 +1,121 @@
+package tuple
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func genElement(r *rand.Rand, depth int) TupleElement {
+	switch r.Intn(14) {
+	case 0:
+		return nil
+	case 1:
+		return []byte{byte(r.Intn(3)), byte(r.Intn(3))}[:r.Intn(3)]
+	case 2:
+		return []string{"", "a", "a\x00", "a\x00b", "b", "\xff"}[r.Intn(6)]
+	case 3:
+		if depth > 0 {
+			return genTuple(r, depth-1)
+		}
+		return Tuple{}
+	case 4:
+		return r.Int63n(1000) - 500
+	case 5:
+		return []int64{math.MinInt64, math.MaxInt64, -1, 0, 1 << 40}[r.Intn(5)]
+	case 6:
+		return []uint64{math.MaxUint64, 1 << 63, 255}[r.Intn(3)]
+	case 7:
+		b := new(big.Int).Lsh(big.NewInt(int64(r.Intn(10)+1)), uint(60+r.Intn(20)))
+		if r.Intn(2) == 0 {
+			b.Neg(b)
+		}
+		return b
+	case 8:
+		return []interface{}{int32(-7), uint16(7), testID(3), testColor(r.Intn(2))}[r.Intn(4)]
+	case 9:
+		return []float32{float32(math.Inf(-1)), -1.5, float32(math.Copysign(0, -1)), 0, 2.5, float32(math.NaN())}[r.Intn(6)]
+	case 10:
+		return []float64{math.Inf(1), -1e300, -0.5, 0, math.Copysign(0, -1), 3, -math.NaN()}[r.Intn(7)]
+	case 11:
+		return r.Intn(2) == 0
+	case 12:
+		var u UUID
+		u[r.Intn(16)] = byte(r.Intn(256))
+		return u
+	}
+	return Versionstamp{TransactionVersion: [10]byte{byte(r.Intn(2))}, UserVersion: uint16(r.Intn(2))}
+}
+
+func genTuple(r *rand.Rand, depth int) Tuple {
+	t := make(Tuple, r.Intn(4))
+	for i := range t {
+		t[i] = genElement(r, depth)
+	}
+	return t
+}
+
+func sign(c int) int {
+	switch {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestCompare(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		a, b := genTuple(r, 2), genTuple(r, 2)
+		if i%4 == 0 {
+			// Make tuples which share a prefix more likely.
+			b = append(append(Tuple{}, a[:len(a)/2]...), b...)
+		}
+
+		want := bytes.Compare(a.Pack(), b.Pack())
+		if got := Compare(a, b); got != want {
+			t.Fatalf("Compare(%v, %v) = %d, want %d", a, b, got, want)
+		}
+		if got := Compare(b, a); got != -want {
+			t.Fatalf("Compare(%v, %v) = %d, want %d", b, a, got, -want)
+		}
+	}
+
+	if c := Compare(Tuple{IncompleteVersionstamp(0)}, Tuple{Versionstamp{UserVersion: 1}}); c != 1 {
+		t.Errorf("Compare of incomplete Versionstamp = %d, want 1", c)
+	}
+
+	for _, a := range []Tuple{
+		{"a", Tuple{make(chan int)}},
+		{"a", Tuple{(*big.Int)(nil)}},
+	} {
+		func() {
+			defer func() {
+				if _, ok := recover().(*EncodeError); !ok {
+					t.Errorf("Compare of %v did not panic with an *EncodeError", a)
+				}
+			}()
+			Compare(a, Tuple{"a", Tuple{1}})
+		}()
+	}
+}
+
+func BenchmarkCompare(b *testing.B) {
+	x := Tuple{"users", int64(1234567), "profile", []byte("avatar"), int64(42)}
+	y := Tuple{"users", int64(1234567), "profile", []byte("avatar"), int64(43)}
+
+	b.Run("Compare", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Compare(x, y)
+		}
+	})
+	b.Run("Pack", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			bytes.Compare(x.Pack(), y.Pack())
+		}
+	})
+}
@@ -0,0 +1,446 @@
+/*
+ * decoder.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go Tuple Layer
+
+package tuple
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Decoder reads the elements of a packed tuple one at a time, without
+// unpacking the whole tuple into a Tuple. Its typed accessors (such as
+// NextInt64 and NextString) return elements as values of their types rather
+// than as TupleElements, and do not allocate except where the result must be
+// copied.
+//
+// Each accessor decodes the next element if it is of the requested type, and
+// otherwise returns an error without advancing the Decoder. A Decoder over a
+// key which is not a valid tuple reports an error only once it reaches the
+// invalid element.
+type Decoder struct {
+	b      []byte
+	off    int
+	nested bool
+}
+
+// NewDecoder returns a Decoder for the packed tuple b.
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{b: b}
+}
+
+// More reports whether there are elements remaining to be decoded.
+func (d *Decoder) More() bool {
+	return d.off < len(d.b)
+}
+
+// Offset returns the offset in the packed tuple of the next element to be
+// decoded.
+func (d *Decoder) Offset() int {
+	return d.off
+}
+
+// Skip advances past the next element without decoding it.
+func (d *Decoder) Skip() error {
+	n, err := d.length()
+	if err != nil {
+		return err
+	}
+	d.off += n
+	return nil
+}
+
+// Next decodes the next element as a TupleElement, of the type with which it
+// would be returned by Unpack.
+func (d *Decoder) Next() (TupleElement, error) {
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+	if d.b[d.off] == nilCode {
+		d.off += n
+		return nil, nil
+	}
+	t, _, err := decodeTuple(d.b[d.off:d.off+n], false)
+	if err != nil {
+		return nil, err
+	}
+	d.off += n
+	return t[0], nil
+}
+
+// NextNil decodes the next element and returns true if it is nil, and
+// otherwise returns false without advancing the Decoder.
+func (d *Decoder) NextNil() bool {
+	if d.More() && d.b[d.off] == nilCode {
+		d.off++
+		if d.nested {
+			d.off++
+		}
+		return true
+	}
+	return false
+}
+
+// NextInt64 decodes the next element as an integer, returning an error if it
+// is not an integer or does not fit in an int64.
+func (d *Decoder) NextInt64() (int64, error) {
+	u, neg, n, err := d.int("int64")
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		if u > 1<<63 {
+			return 0, d.rangeError("int64")
+		}
+		d.off += n
+		return -int64(u), nil
+	}
+	if u > math.MaxInt64 {
+		return 0, d.rangeError("int64")
+	}
+	d.off += n
+	return int64(u), nil
+}
+
+// NextUint64 decodes the next element as an integer, returning an error if it
+// is not an integer or does not fit in a uint64.
+func (d *Decoder) NextUint64() (uint64, error) {
+	u, neg, n, err := d.int("uint64")
+	if err != nil {
+		return 0, err
+	}
+	if neg && u != 0 {
+		return 0, d.rangeError("uint64")
+	}
+	d.off += n
+	return u, nil
+}
+
+// NextFloat32 decodes the next element as a float32.
+func (d *Decoder) NextFloat32() (float32, error) {
+	if err := d.expect(floatCode, 5, "float32"); err != nil {
+		return 0, err
+	}
+	var scratch [4]byte
+	copy(scratch[:], d.b[d.off+1:])
+	adjustFloatBytes(scratch[:], false)
+	d.off += 5
+	return math.Float32frombits(binary.BigEndian.Uint32(scratch[:])), nil
+}
+
+// NextFloat64 decodes the next element as a float64.
+func (d *Decoder) NextFloat64() (float64, error) {
+	if err := d.expect(doubleCode, 9, "float64"); err != nil {
+		return 0, err
+	}
+	var scratch [8]byte
+	copy(scratch[:], d.b[d.off+1:])
+	adjustFloatBytes(scratch[:], false)
+	d.off += 9
+	return math.Float64frombits(binary.BigEndian.Uint64(scratch[:])), nil
+}
+
+// NextBool decodes the next element as a bool.
+func (d *Decoder) NextBool() (bool, error) {
+	if d.More() {
+		switch d.b[d.off] {
+		case trueCode:
+			d.off++
+			return true, nil
+		case falseCode:
+			d.off++
+			return false, nil
+		}
+	}
+	return false, d.typeError("bool")
+}
+
+// NextString decodes the next element as a string.
+func (d *Decoder) NextString() (string, error) {
+	b, n, escaped, err := d.bytes(stringCode, "string")
+	if err != nil {
+		return "", err
+	}
+	d.off += n
+	if escaped {
+		return string(unescapeNulls(b)), nil
+	}
+	return string(b), nil
+}
+
+// NextBytes decodes the next element as a byte string. Unless the byte string
+// contains a 0x00 byte, the returned slice shares its memory with the packed
+// tuple, and must be copied if the packed tuple may be modified.
+func (d *Decoder) NextBytes() ([]byte, error) {
+	b, n, escaped, err := d.bytes(bytesCode, "[]byte")
+	if err != nil {
+		return nil, err
+	}
+	d.off += n
+	if escaped {
+		return unescapeNulls(b), nil
+	}
+	return b, nil
+}
+
+// NextUUID decodes the next element as a UUID.
+func (d *Decoder) NextUUID() (UUID, error) {
+	var u UUID
+	if err := d.expect(uuidCode, 17, "UUID"); err != nil {
+		return u, err
+	}
+	copy(u[:], d.b[d.off+1:])
+	d.off += 17
+	return u, nil
+}
+
+// NextVersionstamp decodes the next element as a Versionstamp.
+func (d *Decoder) NextVersionstamp() (Versionstamp, error) {
+	if err := d.expect(versionstampCode, versionstampLength+1, "Versionstamp"); err != nil {
+		return Versionstamp{}, err
+	}
+	v, n := decodeVersionstamp(d.b[d.off:])
+	d.off += n
+	return v, nil
+}
+
+// NextTuple decodes the next element as a nested tuple, returning a Decoder for
+// its elements.
+func (d *Decoder) NextTuple() (*Decoder, error) {
+	if !d.More() || d.b[d.off] != nestedCode {
+		return nil, d.typeError("Tuple")
+	}
+	n, err := d.length()
+	if err != nil {
+		return nil, err
+	}
+	nd := &Decoder{b: d.b[d.off+1 : d.off+n-1], nested: true}
+	d.off += n
+	return nd, nil
+}
+
+// length returns the length of the encoding of the next element.
+func (d *Decoder) length() (int, error) {
+	if !d.More() {
+		return 0, d.endError()
+	}
+	n, err := elementLength(d.b[d.off:], d.nested)
+	if err != nil {
+		return 0, fmt.Errorf("%v at offset %d of tuple", err, d.off)
+	}
+	return n, nil
+}
+
+// elementLength returns the length of the encoding of the element at the start
+// of b, which is in a nested tuple if nested is true.
+func elementLength(b []byte, nested bool) (int, error) {
+	code := b[0]
+	n := 0
+
+	switch {
+	case code == nilCode:
+		n = 1
+		if nested {
+			n = 2
+		}
+	case code == bytesCode || code == stringCode:
+		end, _, ok := scanBytes(b[1:])
+		if !ok {
+			return 0, fmt.Errorf("unterminated byte string")
+		}
+		n = end + 2
+	case code == nestedCode:
+		n = 1
+		for {
+			if n >= len(b) {
+				return 0, fmt.Errorf("unterminated nested tuple")
+			}
+			if b[n] == nilCode && (n+1 >= len(b) || b[n+1] != 0xFF) {
+				n++
+				break
+			}
+			m, err := elementLength(b[n:], true)
+			if err != nil {
+				return 0, err
+			}
+			n += m
+		}
+	case code == negIntStart || code == posIntEnd:
+		if len(b) < 2 {
+			return 0, fmt.Errorf("insufficient bytes to decode integer")
+		}
+		l := int(b[1])
+		if code == negIntStart {
+			l ^= 0xff
+		}
+		n = l + 2
+	case negIntStart < code && code < posIntEnd:
+		l := int(code) - intZeroCode
+		if l < 0 {
+			l = -l
+		}
+		n = l + 1
+	case code == floatCode:
+		n = 5
+	case code == doubleCode:
+		n = 9
+	case code == falseCode || code == trueCode:
+		n = 1
+	case code == uuidCode:
+		n = 17
+	case code == versionstampCode:
+		n = versionstampLength + 1
+	default:
+		return 0, fmt.Errorf("unable to decode tuple element with unknown typecode %02x", code)
+	}
+
+	if n > len(b) {
+		return 0, fmt.Errorf("insufficient bytes to decode %s", typeName(code))
+	}
+	return n, nil
+}
+
+// scanBytes returns the index in b of the 0x00 byte which terminates a byte
+// string, and whether the byte string contains escaped 0x00 bytes.
+func scanBytes(b []byte) (end int, escaped bool, ok bool) {
+	for {
+		i := bytes.IndexByte(b[end:], 0x00)
+		if i < 0 {
+			return 0, false, false
+		}
+		end += i
+		if end+1 < len(b) && b[end+1] == 0xFF {
+			escaped = true
+			end += 2
+			continue
+		}
+		return end, escaped, true
+	}
+}
+
+func unescapeNulls(b []byte) []byte {
+	return bytes.Replace(b, []byte{0x00, 0xFF}, []byte{0x00}, -1)
+}
+
+// int returns the magnitude and sign of the next element, which is an integer
+// of at most 8 bytes, and the length of its encoding.
+func (d *Decoder) int(want string) (u uint64, neg bool, n int, err error) {
+	if !d.More() {
+		return 0, false, 0, d.endError()
+	}
+
+	code := d.b[d.off]
+	if code == negIntStart || code == posIntEnd {
+		return 0, false, 0, d.rangeError(want)
+	}
+	if code < negIntStart || code > posIntEnd {
+		return 0, false, 0, d.typeError(want)
+	}
+
+	l := int(code) - intZeroCode
+	if l < 0 {
+		l, neg = -l, true
+	}
+	if d.off+l+1 > len(d.b) {
+		return 0, false, 0, fmt.Errorf("insufficient bytes to decode integer at offset %d of tuple", d.off)
+	}
+
+	var scratch [8]byte
+	copy(scratch[8-l:], d.b[d.off+1:d.off+l+1])
+	u = binary.BigEndian.Uint64(scratch[:])
+	if neg {
+		u = sizeLimits[l] - u
+	}
+	return u, neg, l + 1, nil
+}
+
+// expect checks that the next element has the provided type code and an
+// encoding of n bytes.
+func (d *Decoder) expect(code byte, n int, want string) error {
+	if !d.More() || d.b[d.off] != code {
+		return d.typeError(want)
+	}
+	if d.off+n > len(d.b) {
+		return fmt.Errorf("insufficient bytes to decode %s at offset %d of tuple", typeName(code), d.off)
+	}
+	return nil
+}
+
+// bytes returns the contents of the next element, which is a byte string with
+// the provided type code, the length of its encoding, and whether its contents
+// contain escaped 0x00 bytes.
+func (d *Decoder) bytes(code byte, want string) ([]byte, int, bool, error) {
+	if !d.More() || d.b[d.off] != code {
+		return nil, 0, false, d.typeError(want)
+	}
+	start := d.off + 1
+	end, escaped, ok := scanBytes(d.b[start:])
+	if !ok {
+		return nil, 0, false, fmt.Errorf("unterminated byte string at offset %d of tuple", d.off)
+	}
+	return d.b[start : start+end : start+end], end + 2, escaped, nil
+}
+
+func (d *Decoder) endError() error {
+	return fmt.Errorf("no tuple elements remaining at offset %d", d.off)
+}
+
+func (d *Decoder) typeError(want string) error {
+	if !d.More() {
+		return d.endError()
+	}
+	return fmt.Errorf("cannot decode %s at offset %d of tuple as %s", typeName(d.b[d.off]), d.off, want)
+}
+
+func (d *Decoder) rangeError(want string) error {
+	return fmt.Errorf("integer at offset %d of tuple overflows %s", d.off, want)
+}
+
+// typeName describes the type of element with the provided type code.
+func typeName(code byte) string {
+	switch {
+	case code == nilCode:
+		return "nil"
+	case code == bytesCode:
+		return "byte string"
+	case code == stringCode:
+		return "string"
+	case code == nestedCode:
+		return "nested tuple"
+	case negIntStart <= code && code <= posIntEnd:
+		return "integer"
+	case code == floatCode:
+		return "float"
+	case code == doubleCode:
+		return "double"
+	case code == falseCode || code == trueCode:
+		return "bool"
+	case code == uuidCode:
+		return "UUID"
+	case code == versionstampCode:
+		return "Versionstamp"
+	}
+	return fmt.Sprintf("element with unknown typecode %02x", code)
+}
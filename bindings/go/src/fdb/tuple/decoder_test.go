@@ -0,0 +1,165 @@
+package tuple
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestAppendPack(t *testing.T) {
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			prefix := []byte("prefix")
+			b := tc.tuple.AppendPack(prefix)
+			if want := append([]byte("prefix"), tc.tuple.Pack()...); !bytes.Equal(b, want) {
+				t.Errorf("AppendPack = %x, want %x", b, want)
+			}
+		})
+	}
+
+	buf := make([]byte, 0, 128)
+	key := Tuple{"users", int64(1234), testUUID, "name\x00", []byte("id")}
+	if n := testing.AllocsPerRun(100, func() { buf = key.AppendPack(buf[:0]) }); n != 0 {
+		t.Errorf("AppendPack made %v allocations, want 0", n)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	vs := Versionstamp{TransactionVersion: [10]byte{1, 2, 3}, UserVersion: 7}
+	tuple := Tuple{
+		"str\x00", []byte{0x00, 0x01}, int64(-1), int64(math.MinInt64), int64(math.MaxInt64),
+		uint64(math.MaxUint64), float32(1.5), -2.25, true, false, testUUID, vs,
+		nil, Tuple{int64(1), nil, Tuple{"nested"}}, big.NewInt(0).Lsh(big.NewInt(1), 70),
+	}
+	b := tuple.AppendPack(nil)
+
+	d := NewDecoder(b)
+	if s, err := d.NextString(); err != nil || s != "str\x00" {
+		t.Errorf("NextString = %q, %v", s, err)
+	}
+	if _, err := d.NextString(); err == nil {
+		t.Error("NextString of byte string succeeded")
+	}
+	if v, err := d.NextBytes(); err != nil || !bytes.Equal(v, []byte{0x00, 0x01}) {
+		t.Errorf("NextBytes = %x, %v", v, err)
+	}
+	if _, err := d.NextUint64(); err == nil {
+		t.Error("NextUint64 of -1 succeeded")
+	}
+	for _, want := range []int64{-1, math.MinInt64, math.MaxInt64} {
+		if v, err := d.NextInt64(); err != nil || v != want {
+			t.Errorf("NextInt64 = %d, %v; want %d", v, err, want)
+		}
+	}
+	if _, err := d.NextInt64(); err == nil {
+		t.Error("NextInt64 of MaxUint64 succeeded")
+	}
+	if v, err := d.NextUint64(); err != nil || v != math.MaxUint64 {
+		t.Errorf("NextUint64 = %d, %v", v, err)
+	}
+	if v, err := d.NextFloat32(); err != nil || v != 1.5 {
+		t.Errorf("NextFloat32 = %v, %v", v, err)
+	}
+	if v, err := d.NextFloat64(); err != nil || v != -2.25 {
+		t.Errorf("NextFloat64 = %v, %v", v, err)
+	}
+	for _, want := range []bool{true, false} {
+		if v, err := d.NextBool(); err != nil || v != want {
+			t.Errorf("NextBool = %v, %v; want %v", v, err, want)
+		}
+	}
+	if v, err := d.NextUUID(); err != nil || v != testUUID {
+		t.Errorf("NextUUID = %v, %v", v, err)
+	}
+	if v, err := d.NextVersionstamp(); err != nil || v != vs {
+		t.Errorf("NextVersionstamp = %v, %v", v, err)
+	}
+	if !d.NextNil() {
+		t.Error("NextNil = false")
+	}
+
+	nd, err := d.NextTuple()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := nd.NextInt64(); err != nil || v != 1 {
+		t.Errorf("nested NextInt64 = %d, %v", v, err)
+	}
+	if !nd.NextNil() {
+		t.Error("nested NextNil = false")
+	}
+	if e, err := nd.Next(); err != nil || !reflect.DeepEqual(e, Tuple{"nested"}) {
+		t.Errorf("nested Next = %v, %v", e, err)
+	}
+	if nd.More() {
+		t.Error("nested More = true at end of nested tuple")
+	}
+
+	if _, err := d.NextInt64(); err == nil {
+		t.Error("NextInt64 of big integer succeeded")
+	}
+	if err := d.Skip(); err != nil {
+		t.Errorf("Skip = %v", err)
+	}
+	if d.More() {
+		t.Error("More = true at end of tuple")
+	}
+	if err := d.Skip(); err == nil {
+		t.Error("Skip at end of tuple succeeded")
+	}
+}
+
+func TestDecoderMatchesUnpack(t *testing.T) {
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.tuple.Pack()
+			want, err := Unpack(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got Tuple
+			for d := NewDecoder(b); d.More(); {
+				e, err := d.Next()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, e)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Decoder = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecoderInvalid(t *testing.T) {
+	for _, b := range [][]byte{
+		{stringCode, 'a'},
+		{nestedCode, intZeroCode},
+		{intZeroCode + 4, 0x01},
+		{doubleCode, 0x00},
+		{0xFF},
+	} {
+		if err := NewDecoder(b).Skip(); err == nil {
+			t.Errorf("Skip of %x succeeded", b)
+		}
+	}
+}
+
+func TestDecoderAllocs(t *testing.T) {
+	b := Tuple{int64(42), []byte("bytes"), testUUID, 1.5, Tuple{true}}.Pack()
+	n := testing.AllocsPerRun(100, func() {
+		d := NewDecoder(b)
+		d.NextInt64()
+		d.NextBytes()
+		d.NextUUID()
+		d.NextFloat64()
+		d.Skip()
+	})
+	if n != 0 {
+		t.Errorf("Decoder made %v allocations, want 0", n)
+	}
+}
@@ -0,0 +1,388 @@
+/*
+ * format.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go Tuple Layer
+
+package tuple
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// String returns the canonical human-readable representation of the tuple, in
+// the notation used by the other FoundationDB bindings:
+//
+//	("a", 1, b"\x00", nil)
+//
+// Strings are quoted as Go string literals, and byte strings as b"...", in
+// which bytes other than printable ASCII characters are escaped as \xNN.
+// Integers are printed in decimal, float64s with a decimal point or exponent
+// (as in 1.0 or 1e+21) and float32s as float32(1.5). UUIDs are printed as
+// UUID("1100aabb-ccdd-eeff-1100-aabbccddeeff"), Versionstamps as
+// Versionstamp(<20 hex digits of the transaction version>, <user version>),
+// and nested tuples in parentheses.
+//
+// Elements are printed as they would be unpacked, so that Parse(t.String())
+// returns the same tuple as Unpack(t.Pack()) (except for the payloads of NaNs).
+// Elements which cannot be packed are printed with the %v verb of the fmt
+// package.
+func (t Tuple) String() string {
+	var sb strings.Builder
+	t.format(&sb)
+	return sb.String()
+}
+
+func (t Tuple) format(sb *strings.Builder) {
+	sb.WriteByte('(')
+	for i, e := range t {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		formatElement(sb, e)
+	}
+	sb.WriteByte(')')
+}
+
+func formatElement(sb *strings.Builder, e TupleElement) {
+	n, err := normalize(e)
+	if err != nil {
+		fmt.Fprintf(sb, "%v", e)
+		return
+	}
+
+	switch e := n.(type) {
+	case nil:
+		sb.WriteString("nil")
+	case bool:
+		sb.WriteString(strconv.FormatBool(e))
+	case int64:
+		sb.WriteString(strconv.FormatInt(e, 10))
+	case uint64:
+		sb.WriteString(strconv.FormatUint(e, 10))
+	case *big.Int:
+		sb.WriteString(e.String())
+	case float32:
+		sb.WriteString("float32(")
+		sb.WriteString(formatFloat(float64(e), 32))
+		sb.WriteByte(')')
+	case float64:
+		sb.WriteString(formatFloat(e, 64))
+	case string:
+		sb.WriteString(strconv.Quote(e))
+	case []byte:
+		sb.WriteString(`b"`)
+		for _, c := range e {
+			switch {
+			case c == '"' || c == '\\':
+				sb.WriteByte('\\')
+				sb.WriteByte(c)
+			case c >= 0x20 && c < 0x7f:
+				sb.WriteByte(c)
+			default:
+				fmt.Fprintf(sb, `\x%02x`, c)
+			}
+		}
+		sb.WriteByte('"')
+	case UUID:
+		sb.WriteString(`UUID("`)
+		sb.WriteString(formatUUID(e))
+		sb.WriteString(`")`)
+	case Versionstamp:
+		fmt.Fprintf(sb, "Versionstamp(%x, %d)", e.TransactionVersion[:], e.UserVersion)
+	case Tuple:
+		e.format(sb)
+	}
+}
+
+// formatFloat formats f so that it cannot be mistaken for an integer.
+func formatFloat(f float64, bitSize int) string {
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if math.IsNaN(f) && math.Signbit(f) {
+		s = "-" + s
+	} else if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+func formatUUID(u UUID) string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// Parse returns the tuple represented by s, in the notation produced by
+// (Tuple).String. Integers are parsed as int64 if they fit in an int64, as
+// uint64 if they fit in a uint64, and as *big.Int otherwise, as they are by
+// Unpack. Whitespace between elements is ignored, and a trailing comma after
+// the last element of a tuple is permitted.
+func Parse(s string) (Tuple, error) {
+	p := parser{s: s}
+	p.skipSpace()
+	t, err := p.tuple()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, p.errorf("unexpected %q after tuple", p.s[p.pos:])
+	}
+	return t, nil
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("cannot parse tuple at offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume advances past prefix if the remaining input starts with it.
+func (p *parser) consume(prefix string) bool {
+	if strings.HasPrefix(p.s[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(prefix string) error {
+	if !p.consume(prefix) {
+		return p.errorf("expected %q", prefix)
+	}
+	return nil
+}
+
+func (p *parser) tuple() (Tuple, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var t Tuple
+	for {
+		p.skipSpace()
+		if p.consume(")") {
+			return t, nil
+		}
+
+		e, err := p.element()
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, e)
+
+		p.skipSpace()
+		if p.consume(")") {
+			return t, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) element() (TupleElement, error) {
+	switch {
+	case p.pos >= len(p.s):
+		return nil, p.errorf("unexpected end of input")
+	case p.s[p.pos] == '(':
+		return p.tuple()
+	case p.s[p.pos] == '"':
+		lit, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		s, err := strconv.Unquote(lit)
+		if err != nil {
+			return nil, p.errorf("invalid string %s", lit)
+		}
+		return s, nil
+	case p.consume("b"):
+		return p.bytes()
+	case p.consume("float32("):
+		f, err := parseFloat(p.token(), 32)
+		if err != nil {
+			return nil, p.errorf("invalid float32: %v", err)
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return float32(f), nil
+	case p.consume("UUID("):
+		return p.uuid()
+	case p.consume("Versionstamp("):
+		return p.versionstamp()
+	}
+
+	tok := p.token()
+	switch tok {
+	case "":
+		return nil, p.errorf("expected tuple element")
+	case "nil":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	if strings.ContainsAny(tok, ".eEIN") {
+		f, err := parseFloat(tok, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", tok)
+		}
+		return f, nil
+	}
+	if i, err := strconv.ParseInt(tok, 10, 64); err == nil {
+		return i, nil
+	}
+	if u, err := strconv.ParseUint(tok, 10, 64); err == nil {
+		return u, nil
+	}
+	if b, ok := new(big.Int).SetString(tok, 10); ok {
+		return b, nil
+	}
+	return nil, p.errorf("invalid element %q", tok)
+}
+
+// parseFloat parses a float formatted by formatFloat, which unlike
+// strconv.ParseFloat preserves the sign of a NaN.
+func parseFloat(s string, bitSize int) (float64, error) {
+	if s == "-NaN" {
+		return math.Copysign(math.NaN(), -1), nil
+	}
+	return strconv.ParseFloat(s, bitSize)
+}
+
+// token returns the characters up to the next delimiter.
+func (p *parser) token() string {
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte(" \t\r\n,()\"", p.s[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// quoted returns the double-quoted literal at the current position, including
+// its quotes.
+func (p *parser) quoted() (string, error) {
+	start := p.pos
+	if err := p.expect(`"`); err != nil {
+		return "", err
+	}
+	for p.pos < len(p.s) {
+		switch p.s[p.pos] {
+		case '\\':
+			p.pos += 2
+		case '"':
+			p.pos++
+			return p.s[start:p.pos], nil
+		default:
+			p.pos++
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated quoted string")
+}
+
+func (p *parser) bytes() ([]byte, error) {
+	lit, err := p.quoted()
+	if err != nil {
+		return nil, err
+	}
+
+	lit = lit[1 : len(lit)-1]
+	b := make([]byte, 0, len(lit))
+	for i := 0; i < len(lit); i++ {
+		if lit[i] != '\\' {
+			b = append(b, lit[i])
+			continue
+		}
+		i++
+		switch {
+		case i < len(lit) && (lit[i] == '\\' || lit[i] == '"'):
+			b = append(b, lit[i])
+		case i+2 < len(lit) && lit[i] == 'x':
+			v, err := strconv.ParseUint(lit[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, p.errorf("invalid escape \\x%s in byte string", lit[i+1:i+3])
+			}
+			b = append(b, byte(v))
+			i += 2
+		default:
+			return nil, p.errorf("invalid escape in byte string")
+		}
+	}
+	return b, nil
+}
+
+func (p *parser) uuid() (UUID, error) {
+	var u UUID
+	lit, err := p.quoted()
+	if err != nil {
+		return u, err
+	}
+	h := strings.Replace(lit[1:len(lit)-1], "-", "", -1)
+	if len(h) != 32 {
+		return u, p.errorf("invalid UUID %s", lit)
+	}
+	if _, err := hex.Decode(u[:], []byte(h)); err != nil {
+		return u, p.errorf("invalid UUID %s", lit)
+	}
+	return u, p.expect(")")
+}
+
+func (p *parser) versionstamp() (Versionstamp, error) {
+	var v Versionstamp
+	p.skipSpace()
+	tv := p.token()
+	if len(tv) != 2*len(v.TransactionVersion) {
+		return v, p.errorf("invalid Versionstamp transaction version %q", tv)
+	}
+	if _, err := hex.Decode(v.TransactionVersion[:], []byte(tv)); err != nil {
+		return v, p.errorf("invalid Versionstamp transaction version %q", tv)
+	}
+
+	p.skipSpace()
+	if err := p.expect(","); err != nil {
+		return v, err
+	}
+	p.skipSpace()
+	uv, err := strconv.ParseUint(p.token(), 10, 16)
+	if err != nil {
+		return v, p.errorf("invalid Versionstamp user version: %v", err)
+	}
+	v.UserVersion = uint16(uv)
+
+	p.skipSpace()
+	return v, p.expect(")")
+}
@@ -0,0 +1,66 @@
+package tuple
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	huge, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+
+	for _, tc := range []struct {
+		tuple Tuple
+		want  string
+	}{
+		{Tuple{}, "()"},
+		{Tuple{"a", 1, []byte{0x00}, nil}, `("a", 1, b"\x00", nil)`},
+		{Tuple{"quote\"\n", []byte(`a"\`)}, `("quote\"\n", b"a\"\\")`},
+		{Tuple{int8(-1), uint64(math.MaxUint64), huge}, "(-1, 18446744073709551615, -123456789012345678901234567890)"},
+		{Tuple{1.0, 1e21, -2.5, math.Inf(-1), float32(1.5), float32(3)}, "(1.0, 1e+21, -2.5, -Inf, float32(1.5), float32(3.0))"},
+		{Tuple{true, false, testUUID}, `(true, false, UUID("1100aabb-ccdd-eeff-1100-aabbccddeeff"))`},
+		{Tuple{IncompleteVersionstamp(3)}, "(Versionstamp(ffffffffffffffffffff, 3))"},
+		{Tuple{Tuple{"a", Tuple{}}, testColor(1), testID(7)}, `(("a", ()), "green", 7)`},
+		{Tuple{math.NaN(), -math.NaN(), struct{}{}}, "(NaN, -NaN, {})"},
+	} {
+		if got := tc.tuple.String(); got != tc.want {
+			t.Errorf("String = %s, want %s", got, tc.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		tuple := genTuple(r, 2)
+		want, err := Unpack(tuple.Pack())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Parse(tuple.String())
+		if err != nil {
+			t.Fatalf("Parse(%s): %v", tuple, err)
+		}
+		// NaNs are never equal, so compare them as packed
+		if !reflect.DeepEqual(got, want) && Compare(got, want) != 0 {
+			t.Fatalf("Parse(%s) = %#v, want %#v", tuple, got, want)
+		}
+	}
+
+	got, err := Parse(" ( \"a\" ,1,\tb\"\\x00\" , (nil,), ) ")
+	if want := (Tuple{"a", int64(1), []byte{0x00}, Tuple{nil}}); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %#v, %v; want %#v", got, err, want)
+	}
+
+	for _, s := range []string{
+		"", "(", "(1", "(1,,)", "(1 2)", "() x", "(\"a)", `(b"\q")`, `(b"\x0")`, "(hello)",
+		`(UUID("1234"))`, "(Versionstamp(00, 1))", "(Versionstamp(ffffffffffffffffffff, 70000))", "(float32(x))",
+	} {
+		if tuple, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", s, tuple)
+		}
+	}
+}
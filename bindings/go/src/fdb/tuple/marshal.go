@@ -0,0 +1,479 @@
+/*
+ * marshal.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go Tuple Layer
+
+package tuple
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Marshal returns the packed tuple encoding of v, which must be a struct or a
+// non-nil pointer to a struct. Each exported field of the struct is encoded as
+// one element of the tuple, so that Marshal(v) returns the same bytes as
+// packing the equivalent Tuple built by hand.
+//
+// Fields are encoded in the order in which they are declared, unless the
+// fdbtuple tag of a field gives its position. A field tagged fdbtuple:"-" is
+// omitted. If any field has a position, every encoded field must have one, and
+// no two fields may share a position:
+//
+//	type Order struct {
+//		Customer string    `fdbtuple:"0"`
+//		Placed   time.Time `fdbtuple:"1"`
+//		ID       int64     `fdbtuple:"2"`
+//		Note     string    `fdbtuple:"-"`
+//	}
+//
+// Fields of the element types listed in the documentation of TupleElement are
+// encoded as those elements, as are fields whose types are defined over the
+// kinds of those types (such as a type UserID int64). Fields of other types are
+// encoded as follows:
+//
+// Signed and unsigned integers of any size are encoded as int64 and uint64.
+//
+// Fields whose types implement Encoder are encoded as the elements returned by
+// their TupleElement methods.
+//
+// Nested structs are encoded as nested tuples, by the same rules.
+//
+// Slices and arrays (other than []byte and UUID) are encoded as nested tuples
+// of their elements.
+//
+// Pointers are encoded as nil if they are nil, and as the value to which they
+// point otherwise. Interface values are encoded as their dynamic values.
+//
+// A time.Time is encoded as an int64 holding the number of nanoseconds since
+// the Unix epoch, so that times sort in order. It is an error to marshal a
+// time.Time which cannot be represented this way (one before the year 1678 or
+// after the year 2262, including the zero time.Time); use a *time.Time for a
+// time which may be absent.
+//
+// Marshal returns an error if v contains a value of any other type (such as a
+// map or a channel).
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot marshal %T: not a struct or a pointer to a struct", v)
+	}
+
+	t, err := marshalStruct(rv, "")
+	if err != nil {
+		return nil, err
+	}
+	return t.PackErr()
+}
+
+// Unmarshal decodes the packed tuple b into v, which must be a non-nil pointer
+// to a struct. Unmarshal is the inverse of Marshal: the tuple must have one
+// element for each field that Marshal would encode, in the same order, and each
+// element must be convertible to the type of its field. Integer elements may be
+// stored into fields of any integer type which can represent them. A nil
+// element sets a pointer, slice or interface field to nil. Unmarshal decodes
+// elements into fields whose types implement Encoder according to the kinds of
+// those types, and so cannot decode an Encoder whose representation is of a
+// different kind.
+func Unmarshal(b []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot unmarshal into %T: not a non-nil pointer to a struct", v)
+	}
+
+	t, err := Unpack(b)
+	if err != nil {
+		return err
+	}
+	return unmarshalStruct(t, rv.Elem(), "")
+}
+
+var (
+	timeType         = reflect.TypeOf(time.Time{})
+	bigIntType       = reflect.TypeOf(big.Int{})
+	bigIntPtrType    = reflect.TypeOf((*big.Int)(nil))
+	uuidType         = reflect.TypeOf(UUID{})
+	versionstampType = reflect.TypeOf(Versionstamp{})
+	tupleType        = reflect.TypeOf(Tuple{})
+	encoderType      = reflect.TypeOf((*Encoder)(nil)).Elem()
+
+	minTime = time.Unix(0, math.MinInt64)
+	maxTime = time.Unix(0, math.MaxInt64)
+)
+
+// structField is a field of a struct which is encoded in its tuple.
+type structField struct {
+	name  string
+	index int
+	pos   int
+}
+
+var structFieldCache sync.Map // map[reflect.Type][]structField
+
+// structFields returns the encoded fields of the struct type t, in the order
+// in which they are encoded.
+func structFields(t reflect.Type) ([]structField, error) {
+	if fs, ok := structFieldCache.Load(t); ok {
+		return fs.([]structField), nil
+	}
+
+	var fields []structField
+	positioned := 0
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		tag := sf.Tag.Get("fdbtuple")
+		if tag == "-" {
+			continue
+		}
+
+		f := structField{name: sf.Name, index: i, pos: -1}
+		if tag != "" {
+			pos, err := strconv.Atoi(tag)
+			if err != nil || pos < 0 {
+				return nil, fmt.Errorf("invalid fdbtuple tag %q on field %s of %s", tag, sf.Name, t)
+			}
+			f.pos = pos
+			positioned++
+		}
+		fields = append(fields, f)
+	}
+
+	if positioned > 0 {
+		if positioned != len(fields) {
+			return nil, fmt.Errorf("some but not all encoded fields of %s have an fdbtuple position", t)
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].pos < fields[j].pos })
+		for i := 1; i < len(fields); i++ {
+			if fields[i].pos == fields[i-1].pos {
+				return nil, fmt.Errorf("fields %s and %s of %s have the same fdbtuple position %d", fields[i-1].name, fields[i].name, t, fields[i].pos)
+			}
+		}
+	}
+
+	structFieldCache.Store(t, fields)
+	return fields, nil
+}
+
+func fieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func marshalStruct(v reflect.Value, path string) (Tuple, error) {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	t := make(Tuple, len(fields))
+	for i, f := range fields {
+		if t[i], err = marshalValue(v.Field(f.index), fieldPath(path, f.name)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func marshalSequence(v reflect.Value, path string) (Tuple, error) {
+	t := make(Tuple, v.Len())
+	for i := range t {
+		var err error
+		if t[i], err = marshalValue(v.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// marshalValue returns the TupleElement encoding v, which is found at path.
+func marshalValue(v reflect.Value, path string) (TupleElement, error) {
+	if v.Type().Implements(encoderType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil, nil
+		}
+		return v.Interface(), nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		tm := v.Interface().(time.Time)
+		if tm.Before(minTime) || tm.After(maxTime) {
+			return nil, fmt.Errorf("cannot marshal %s: time %v is outside the range of int64 nanoseconds", path, tm)
+		}
+		return tm.UnixNano(), nil
+	case bigIntType:
+		b := v.Interface().(big.Int)
+		return &b, nil
+	case uuidType, versionstampType, tupleType:
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32:
+		return float32(v.Float()), nil
+	case reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Struct:
+		return marshalStruct(v, path)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+		return marshalSequence(v, path)
+	case reflect.Array:
+		return marshalSequence(v, path)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type() == bigIntPtrType {
+			return v.Interface(), nil
+		}
+		return marshalValue(v.Elem(), path)
+	}
+
+	return nil, fmt.Errorf("cannot marshal %s: unsupported type %s", path, v.Type())
+}
+
+func unmarshalStruct(t Tuple, v reflect.Value, path string) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	if len(t) != len(fields) {
+		name := path
+		if name == "" {
+			name = v.Type().String()
+		}
+		return fmt.Errorf("cannot unmarshal tuple of %d elements into %s, which has %d fields", len(t), name, len(fields))
+	}
+
+	for i, f := range fields {
+		if err := unmarshalValue(t[i], v.Field(f.index), fieldPath(path, f.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unmarshalError(e TupleElement, v reflect.Value, path string) error {
+	return fmt.Errorf("cannot unmarshal %T into %s of type %s", e, path, v.Type())
+}
+
+// unmarshalValue stores the element e into v, which is found at path.
+func unmarshalValue(e TupleElement, v reflect.Value, path string) error {
+	if e == nil {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Interface:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return unmarshalError(e, v, path)
+	}
+
+	switch v.Type() {
+	case timeType:
+		n, ok := e.(int64)
+		if !ok {
+			return unmarshalError(e, v, path)
+		}
+		v.Set(reflect.ValueOf(time.Unix(0, n).UTC()))
+		return nil
+	case bigIntType, bigIntPtrType:
+		b, ok := toBigInt(e)
+		if !ok {
+			return unmarshalError(e, v, path)
+		}
+		if v.Kind() == reflect.Ptr {
+			v.Set(reflect.ValueOf(b))
+		} else {
+			v.Set(reflect.ValueOf(b).Elem())
+		}
+		return nil
+	case uuidType, versionstampType, tupleType:
+		ev := reflect.ValueOf(e)
+		if ev.Type() != v.Type() {
+			return unmarshalError(e, v, path)
+		}
+		v.Set(ev)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := e.(bool)
+		if !ok {
+			return unmarshalError(e, v, path)
+		}
+		v.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		switch e := e.(type) {
+		case int64:
+			n = e
+		case uint64:
+			if e > math.MaxInt64 {
+				return fmt.Errorf("cannot unmarshal %d into %s: overflows %s", e, path, v.Type())
+			}
+			n = int64(e)
+		default:
+			return unmarshalError(e, v, path)
+		}
+		if v.OverflowInt(n) {
+			return fmt.Errorf("cannot unmarshal %d into %s: overflows %s", n, path, v.Type())
+		}
+		v.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var n uint64
+		switch e := e.(type) {
+		case int64:
+			if e < 0 {
+				return fmt.Errorf("cannot unmarshal %d into %s: overflows %s", e, path, v.Type())
+			}
+			n = uint64(e)
+		case uint64:
+			n = e
+		default:
+			return unmarshalError(e, v, path)
+		}
+		if v.OverflowUint(n) {
+			return fmt.Errorf("cannot unmarshal %d into %s: overflows %s", n, path, v.Type())
+		}
+		v.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch e := e.(type) {
+		case float32:
+			v.SetFloat(float64(e))
+		case float64:
+			if v.Kind() == reflect.Float32 {
+				return unmarshalError(e, v, path)
+			}
+			v.SetFloat(e)
+		default:
+			return unmarshalError(e, v, path)
+		}
+		return nil
+	case reflect.String:
+		s, ok := e.(string)
+		if !ok {
+			return unmarshalError(e, v, path)
+		}
+		v.SetString(s)
+		return nil
+	case reflect.Struct:
+		t, ok := e.(Tuple)
+		if !ok {
+			return unmarshalError(e, v, path)
+		}
+		return unmarshalStruct(t, v, path)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, ok := e.([]byte)
+			if !ok {
+				return unmarshalError(e, v, path)
+			}
+			v.SetBytes(b)
+			return nil
+		}
+		t, ok := e.(Tuple)
+		if !ok {
+			return unmarshalError(e, v, path)
+		}
+		s := reflect.MakeSlice(v.Type(), len(t), len(t))
+		if err := unmarshalSequence(t, s, path); err != nil {
+			return err
+		}
+		v.Set(s)
+		return nil
+	case reflect.Array:
+		t, ok := e.(Tuple)
+		if !ok {
+			return unmarshalError(e, v, path)
+		}
+		if len(t) != v.Len() {
+			return fmt.Errorf("cannot unmarshal tuple of %d elements into %s of type %s", len(t), path, v.Type())
+		}
+		return unmarshalSequence(t, v, path)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		if err := unmarshalValue(e, p.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	case reflect.Interface:
+		ev := reflect.ValueOf(e)
+		if !ev.Type().AssignableTo(v.Type()) {
+			return unmarshalError(e, v, path)
+		}
+		v.Set(ev)
+		return nil
+	}
+
+	return fmt.Errorf("cannot unmarshal into %s: unsupported type %s", path, v.Type())
+}
+
+func unmarshalSequence(t Tuple, v reflect.Value, path string) error {
+	for i, e := range t {
+		if err := unmarshalValue(e, v.Index(i), path+"["+strconv.Itoa(i)+"]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toBigInt(e TupleElement) (*big.Int, bool) {
+	switch e := e.(type) {
+	case int64:
+		return big.NewInt(e), true
+	case uint64:
+		return new(big.Int).SetUint64(e), true
+	case *big.Int:
+		return new(big.Int).Set(e), true
+	}
+	return nil, false
+}
@@ -0,0 +1,194 @@
+package tuple
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type userID int64
+
+type address struct {
+	City string
+	Zip  *string
+}
+
+type person struct {
+	ID       userID
+	Name     string
+	Age      uint8
+	Height   float32
+	Weight   float64
+	Admin    bool
+	Avatar   []byte
+	Tags     []string
+	Home     address
+	Work     *address
+	Born     time.Time
+	Seen     *time.Time
+	Balance  *big.Int
+	Token    UUID
+	Extra    interface{}
+	Scores   [2]int16
+	internal int
+	Ignored  string `fdbtuple:"-"`
+}
+
+func TestMarshal(t *testing.T) {
+	zip := "94301"
+	born := time.Date(1990, 5, 17, 8, 30, 0, 0, time.UTC)
+	p := person{
+		ID:      42,
+		Name:    "ada",
+		Age:     36,
+		Height:  1.7,
+		Weight:  60.5,
+		Admin:   true,
+		Avatar:  []byte{0x00, 0xFF},
+		Tags:    []string{"a", "b"},
+		Home:    address{City: "Palo Alto", Zip: &zip},
+		Born:    born,
+		Balance: big.NewInt(-1000),
+		Token:   testUUID,
+		Extra:   Tuple{"x", int64(1)},
+		Scores:  [2]int16{-3, 4},
+	}
+
+	b, err := Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Tuple{
+		int64(42), "ada", uint64(36), float32(1.7), 60.5, true, []byte{0x00, 0xFF},
+		Tuple{"a", "b"}, Tuple{"Palo Alto", "94301"}, nil, born.UnixNano(), nil,
+		big.NewInt(-1000), testUUID, Tuple{"x", int64(1)}, Tuple{-3, 4},
+	}.Pack()
+	if !bytes.Equal(b, want) {
+		t.Fatalf("Marshal = %x, want %x", b, want)
+	}
+
+	if pb, err := Marshal(&p); err != nil || !bytes.Equal(pb, b) {
+		t.Errorf("Marshal(&p) = %x, %v; want %x", pb, err, b)
+	}
+
+	var q person
+	if err := Unmarshal(b, &q); err != nil {
+		t.Fatal(err)
+	}
+	p.internal, p.Ignored = 0, ""
+	if !reflect.DeepEqual(p, q) {
+		t.Errorf("Unmarshal = %+v, want %+v", q, p)
+	}
+}
+
+func TestMarshalPositions(t *testing.T) {
+	type event struct {
+		Kind string `fdbtuple:"1"`
+		At   int64  `fdbtuple:"0"`
+		Note string `fdbtuple:"-"`
+	}
+
+	b, err := Marshal(event{Kind: "login", At: 7, Note: "ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Tuple{7, "login"}).Pack(); !bytes.Equal(b, want) {
+		t.Errorf("Marshal = %x, want %x", b, want)
+	}
+
+	var e event
+	if err := Unmarshal(b, &e); err != nil {
+		t.Fatal(err)
+	}
+	if e != (event{Kind: "login", At: 7}) {
+		t.Errorf("Unmarshal = %+v", e)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	type partial struct {
+		A int `fdbtuple:"0"`
+		B int
+	}
+	type duplicate struct {
+		A int `fdbtuple:"0"`
+		B int `fdbtuple:"0"`
+	}
+	type badTag struct {
+		A int `fdbtuple:"first"`
+	}
+	type withMap struct {
+		M map[string]int
+	}
+	type withTime struct {
+		T time.Time
+	}
+
+	for _, tc := range []struct {
+		name string
+		v    interface{}
+		err  string
+	}{
+		{"NotStruct", 1, "not a struct"},
+		{"NilPointer", (*person)(nil), "not a struct"},
+		{"Partial", partial{}, "some but not all"},
+		{"Duplicate", duplicate{}, "same fdbtuple position"},
+		{"BadTag", badTag{}, "invalid fdbtuple tag"},
+		{"Map", withMap{}, "cannot marshal M"},
+		{"ZeroTime", withTime{}, "cannot marshal T"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Marshal(tc.v); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("Marshal error = %v, want one containing %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	type small struct {
+		N int8
+	}
+	type str struct {
+		S string
+	}
+
+	for _, tc := range []struct {
+		name  string
+		tuple Tuple
+		v     interface{}
+		err   string
+	}{
+		{"NotPointer", Tuple{1}, small{}, "not a non-nil pointer"},
+		{"Length", Tuple{1, 2}, &small{}, "2 elements"},
+		{"Overflow", Tuple{1000}, &small{}, "overflows int8"},
+		{"Type", Tuple{1}, &str{}, "cannot unmarshal int64 into S"},
+		{"Nil", Tuple{nil}, &str{}, "cannot unmarshal <nil> into S"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Unmarshal(tc.tuple.Pack(), tc.v); err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("Unmarshal error = %v, want one containing %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestMarshalEncoder(t *testing.T) {
+	type pixel struct {
+		Color testColor
+		Shade *testColor
+		ID    testULID
+	}
+
+	b, err := Marshal(pixel{Color: 1, ID: testULID(testUUID)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Tuple{"green", nil, testUUID}).Pack(); !bytes.Equal(b, want) {
+		t.Errorf("Marshal = %x, want %x", b, want)
+	}
+}
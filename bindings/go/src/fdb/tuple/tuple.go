@@ -32,8 +32,14 @@
 // FoundationDB tuples can currently encode byte and unicode strings, integers,
 // large integers, floats, doubles, booleans, UUIDs, tuples, and NULL values.
 // In Go these are represented as []byte (or fdb.KeyConvertible), string, int64
-// (or int, uint, uint64), *big.Int (or big.Int), float32, float64, bool,
-// UUID, Tuple, and nil.
+// (or any other integer type), *big.Int (or big.Int), float32, float64, bool,
+// UUID, Tuple, and nil. Other types may define their representation by
+// implementing Encoder. Marshal and Unmarshal convert between tuples and Go
+// structs whose fields are of these (and related) types.
+//
+// Compare orders tuples as their encodings are ordered, and String and Parse
+// convert between tuples and the human-readable notation used by the other
+// FoundationDB bindings.
 package tuple
 
 import (
@@ -43,6 +49,8 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"reflect"
+	"strings"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
 )
@@ -53,13 +61,32 @@ import (
 // result in a runtime panic).
 //
 // The valid types for TupleElement are []byte (or fdb.KeyConvertible), string,
-// int64 (or int, uint, uint64), *big.Int (or big.Int), float, double, bool,
-// UUID, Tuple, and nil.
+// int64 (or any other integer type except uintptr), *big.Int (or big.Int),
+// float, double, bool, UUID, Tuple, and nil. Types defined over the kinds of
+// bool, the integer types, float32, float64, string, []byte and Tuple (such as
+// a type UserID int64) are encoded as those types, and an Encoder is encoded as
+// the element returned by its TupleElement method.
 type TupleElement interface{}
 
+// Encoder is implemented by types which define their own representation in a
+// tuple, such as identifiers and enumerations. An Encoder is packed as the
+// TupleElement returned by its TupleElement method, which must be of one of the
+// valid types for TupleElement and must not itself be an Encoder. The element
+// is unpacked as the type with which that representation is unpacked, rather
+// than as the Encoder; for example:
+//
+//	type Color int
+//
+//	func (c Color) TupleElement() tuple.TupleElement {
+//		return colorNames[c] // encodes colors as strings
+//	}
+type Encoder interface {
+	TupleElement() TupleElement
+}
+
 // Tuple is a slice of objects that can be encoded as FoundationDB tuples. If
 // any of the TupleElements are of unsupported types, a runtime panic will occur
-// when the Tuple is packed.
+// when the Tuple is packed (unless it is packed with PackErr).
 //
 // Given a Tuple T containing objects only of these types, then T will be
 // identical to the Tuple returned by unpacking the byte slice obtained by
@@ -156,6 +183,58 @@ func adjustFloatBytes(b []byte, encode bool) {
 	}
 }
 
+// EncodeError is the error returned by PackErr and PackWithVersionstamp (and
+// the value with which Pack panics) when an element of a tuple cannot be
+// encoded.
+type EncodeError struct {
+	// Index is the index of the element (or of the nested tuple containing it)
+	// in the tuple being packed.
+	Index int
+
+	// Path is the path to the element through any nested tuples: Path[0] is
+	// Index, Path[1] the index of the element in the tuple at Path[0], and so
+	// on.
+	Path []int
+
+	// Type is the Go type of the element.
+	Type reflect.Type
+
+	// Err describes why the element cannot be encoded.
+	Err error
+}
+
+func (e *EncodeError) Error() string {
+	if len(e.Path) > 1 {
+		return fmt.Sprintf("unencodable element at index %d (path %v, type %v): %v", e.Index, e.Path, e.Type, e.Err)
+	}
+	return fmt.Sprintf("unencodable element at index %d (type %v): %v", e.Index, e.Type, e.Err)
+}
+
+// Unwrap returns e.Err.
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	errUnsupportedType        = errors.New("unsupported type")
+	errBigIntTooLarge         = errors.New("integer magnitude is too large (more than 255 bytes)")
+	errNilBigInt              = errors.New("nil *big.Int")
+	errIncompleteVersionstamp = errors.New("incomplete versionstamp included in vanilla tuple pack")
+	errMultipleVersionstamps  = errors.New("tuple can only contain one incomplete versionstamp")
+	errEncoderLoop            = errors.New("TupleElement returned another Encoder")
+)
+
+func newEncodeError(i int, e TupleElement, err error) *EncodeError {
+	return &EncodeError{Index: i, Path: []int{i}, Type: reflect.TypeOf(e), Err: err}
+}
+
+// nest records that e occurred in the nested tuple at index i.
+func (e *EncodeError) nest(i int) *EncodeError {
+	e.Index = i
+	e.Path = append([]int{i}, e.Path...)
+	return e
+}
+
 type packer struct {
 	versionstampPos int32
 	buf             []byte
@@ -196,6 +275,21 @@ func (p *packer) encodeBytes(code byte, b []byte) {
 	p.putByte(0x00)
 }
 
+func (p *packer) encodeString(s string) {
+	p.putByte(stringCode)
+	for {
+		i := strings.IndexByte(s, 0x00)
+		if i < 0 {
+			break
+		}
+		p.buf = append(p.buf, s[:i+1]...)
+		p.putByte(0xFF)
+		s = s[i+1:]
+	}
+	p.buf = append(p.buf, s...)
+	p.putByte(0x00)
+}
+
 func (p *packer) encodeUint(i uint64) {
 	if i == 0 {
 		p.putByte(intZeroCode)
@@ -227,10 +321,10 @@ func (p *packer) encodeInt(i int64) {
 	p.putBytes(scratch[8-n:])
 }
 
-func (p *packer) encodeBigInt(i *big.Int) {
+func (p *packer) encodeBigInt(i *big.Int) error {
 	length := len(i.Bytes())
 	if length > 0xff {
-		panic(fmt.Sprintf("Integer magnitude is too large (more than 255 bytes)"))
+		return errBigIntTooLarge
 	}
 
 	if i.Sign() >= 0 {
@@ -267,6 +361,8 @@ func (p *packer) encodeBigInt(i *big.Int) {
 
 		p.putBytes(intBytes)
 	}
+
+	return nil
 }
 
 func (p *packer) encodeFloat(f float32) {
@@ -287,92 +383,200 @@ func (p *packer) encodeDouble(d float64) {
 	p.putBytes(scratch[:])
 }
 
+func (p *packer) encodeBool(b bool) {
+	if b {
+		p.putByte(trueCode)
+	} else {
+		p.putByte(falseCode)
+	}
+}
+
 func (p *packer) encodeUUID(u UUID) {
 	p.putByte(uuidCode)
 	p.putBytes(u[:])
 }
 
-func (p *packer) encodeVersionstamp(v Versionstamp) {
-	p.putByte(versionstampCode)
-
+func (p *packer) encodeVersionstamp(v Versionstamp) error {
 	isIncomplete := v.TransactionVersion == incompleteTransactionVersion
 	if isIncomplete {
 		if p.versionstampPos != -1 {
-			panic(fmt.Sprintf("Tuple can only contain one incomplete versionstamp"))
+			return errMultipleVersionstamps
 		}
 
-		p.versionstampPos = int32(len(p.buf))
+		p.versionstampPos = int32(len(p.buf) + 1)
 	}
 
+	p.putByte(versionstampCode)
 	p.putBytes(v.Bytes())
+	return nil
 }
 
-func (p *packer) encodeTuple(t Tuple, nested bool, versionstamps bool) {
+func (p *packer) encodeTuple(t Tuple, nested bool, versionstamps bool) *EncodeError {
 	if nested {
 		p.putByte(nestedCode)
 	}
 
 	for i, e := range t {
-		switch e := e.(type) {
-		case Tuple:
-			p.encodeTuple(e, true, versionstamps)
-		case nil:
-			p.putByte(nilCode)
-			if nested {
-				p.putByte(0xff)
+		if err := p.encodeElement(e, nested, versionstamps); err != nil {
+			if ee, ok := err.(*EncodeError); ok {
+				return ee.nest(i)
 			}
-		case int:
-			p.encodeInt(int64(e))
-		case int64:
-			p.encodeInt(e)
-		case uint:
-			p.encodeUint(uint64(e))
-		case uint64:
-			p.encodeUint(e)
-		case *big.Int:
-			p.encodeBigInt(e)
-		case big.Int:
-			p.encodeBigInt(&e)
-		case []byte:
-			p.encodeBytes(bytesCode, e)
-		case fdb.KeyConvertible:
-			p.encodeBytes(bytesCode, []byte(e.FDBKey()))
-		case string:
-			p.encodeBytes(stringCode, []byte(e))
-		case float32:
-			p.encodeFloat(e)
-		case float64:
-			p.encodeDouble(e)
-		case bool:
-			if e {
-				p.putByte(trueCode)
-			} else {
-				p.putByte(falseCode)
-			}
-		case UUID:
-			p.encodeUUID(e)
-		case Versionstamp:
-			if versionstamps == false && e.TransactionVersion == incompleteTransactionVersion {
-				panic(fmt.Sprintf("Incomplete Versionstamp included in vanilla tuple pack"))
-			}
-
-			p.encodeVersionstamp(e)
-		default:
-			panic(fmt.Sprintf("unencodable element at index %d (%v, type %T)", i, t[i], t[i]))
+			return newEncodeError(i, e, err)
 		}
 	}
 
 	if nested {
 		p.putByte(0x00)
 	}
+
+	return nil
+}
+
+// encodeElement encodes e, which is an element of a nested tuple if nested is
+// true. It returns an *EncodeError if e is a tuple containing an element which
+// cannot be encoded.
+func (p *packer) encodeElement(e TupleElement, nested bool, versionstamps bool) error {
+	switch e := e.(type) {
+	case Tuple:
+		if err := p.encodeTuple(e, true, versionstamps); err != nil {
+			return err
+		}
+	case nil:
+		p.putByte(nilCode)
+		if nested {
+			p.putByte(0xff)
+		}
+	case int:
+		p.encodeInt(int64(e))
+	case int8:
+		p.encodeInt(int64(e))
+	case int16:
+		p.encodeInt(int64(e))
+	case int32:
+		p.encodeInt(int64(e))
+	case int64:
+		p.encodeInt(e)
+	case uint:
+		p.encodeUint(uint64(e))
+	case uint8:
+		p.encodeUint(uint64(e))
+	case uint16:
+		p.encodeUint(uint64(e))
+	case uint32:
+		p.encodeUint(uint64(e))
+	case uint64:
+		p.encodeUint(e)
+	case *big.Int:
+		if e == nil {
+			return errNilBigInt
+		}
+		return p.encodeBigInt(e)
+	case big.Int:
+		return p.encodeBigInt(&e)
+	case []byte:
+		p.encodeBytes(bytesCode, e)
+	case Encoder:
+		el := e.TupleElement()
+		if _, ok := el.(Encoder); ok {
+			return errEncoderLoop
+		}
+		return p.encodeElement(el, nested, versionstamps)
+	case fdb.KeyConvertible:
+		p.encodeBytes(bytesCode, []byte(e.FDBKey()))
+	case string:
+		p.encodeString(e)
+	case float32:
+		p.encodeFloat(e)
+	case float64:
+		p.encodeDouble(e)
+	case bool:
+		p.encodeBool(e)
+	case UUID:
+		p.encodeUUID(e)
+	case Versionstamp:
+		if versionstamps == false && e.TransactionVersion == incompleteTransactionVersion {
+			return errIncompleteVersionstamp
+		}
+		return p.encodeVersionstamp(e)
+	default:
+		return p.encodeReflect(reflect.ValueOf(e), versionstamps)
+	}
+
+	return nil
+}
+
+// encodeReflect encodes v, whose type is defined over one of the kinds of the
+// types that may be encoded in a tuple (such as a type UserID int64).
+func (p *packer) encodeReflect(v reflect.Value, versionstamps bool) error {
+	switch v.Kind() {
+	case reflect.Bool:
+		p.encodeBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		p.encodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		p.encodeUint(v.Uint())
+	case reflect.Float32:
+		p.encodeFloat(float32(v.Float()))
+	case reflect.Float64:
+		p.encodeDouble(v.Float())
+	case reflect.String:
+		p.encodeString(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			p.encodeBytes(bytesCode, v.Bytes())
+		} else if v.Type().ConvertibleTo(tupleType) {
+			if err := p.encodeTuple(v.Convert(tupleType).Interface().(Tuple), true, versionstamps); err != nil {
+				return err
+			}
+		} else {
+			return errUnsupportedType
+		}
+	default:
+		return errUnsupportedType
+	}
+
+	return nil
+}
+
+// PackErr returns a new byte slice encoding the provided tuple, or an
+// *EncodeError if the tuple cannot be encoded. It succeeds in exactly those
+// cases in which Pack does not panic, and so may be used to encode tuples built
+// from untrusted input.
+func (t Tuple) PackErr() ([]byte, error) {
+	return t.appendPack(make([]byte, 0, 64))
+}
+
+// AppendPack appends the encoding of the provided tuple to dst and returns the
+// extended slice. It panics in the same circumstances as Pack. Unlike Pack,
+// AppendPack allocates only when dst lacks the capacity for the encoding, so
+// that keys may be built in a reused buffer:
+//
+//	buf = tuple.Tuple{"users", id}.AppendPack(buf[:0])
+func (t Tuple) AppendPack(dst []byte) []byte {
+	b, err := t.appendPack(dst)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (t Tuple) appendPack(dst []byte) ([]byte, error) {
+	p := packer{versionstampPos: -1, buf: dst}
+	if err := p.encodeTuple(t, false, false); err != nil {
+		return nil, err
+	}
+	return p.buf, nil
 }
 
 // Pack returns a new byte slice encoding the provided tuple. Pack will panic if
 // the tuple contains an element of any type other than []byte,
-// fdb.KeyConvertible, string, int64, int, uint64, uint, *big.Int, big.Int, float32,
-// float64, bool, tuple.UUID, tuple.Versionstamp, nil, or a Tuple with elements of
-// valid types. It will also panic if an integer is specified with a value outside
-// the range [-2**2040+1, 2**2040-1]
+// fdb.KeyConvertible, string, int64, int, int32, int16, int8, uint64, uint,
+// uint32, uint16, uint8, *big.Int, big.Int, float32, float64, bool, tuple.UUID,
+// tuple.Versionstamp, nil, a Tuple with elements of valid types, a type defined
+// over one of these kinds (see TupleElement), or an Encoder. It will also panic
+// if an integer is specified with a value outside the range
+// [-2**2040+1, 2**2040-1]. The value of the panic is an *EncodeError; use
+// PackErr to have it returned instead.
 //
 // Tuple satisfies the fdb.KeyConvertible interface, so it is not necessary to
 // call Pack when using a Tuple with a FoundationDB API function that requires a
@@ -382,14 +586,17 @@ func (p *packer) encodeTuple(t Tuple, nested bool, versionstamps bool) {
 // PackWithVersionstamp instead.
 //
 func (t Tuple) Pack() []byte {
-	p := newPacker()
-	p.encodeTuple(t, false, false)
-	return p.buf
+	b, err := t.PackErr()
+	if err != nil {
+		panic(err)
+	}
+	return b
 }
 
 // PackWithVersionstamp packs the specified tuple into a key for versionstamp
 // operations. See Pack for more information. This function will return an error
-// if you attempt to pack a tuple with more than one versionstamp. This function will
+// if you attempt to pack a tuple with more than one versionstamp, and an
+// *EncodeError if the tuple cannot otherwise be encoded. This function will
 // return an error if you attempt to pack a tuple with a versionstamp position larger
 // than an uint16 if the API version is less than 520.
 func (t Tuple) PackWithVersionstamp(prefix []byte) ([]byte, error) {
@@ -413,7 +620,9 @@ func (t Tuple) PackWithVersionstamp(prefix []byte) ([]byte, error) {
 		p.putBytes(prefix)
 	}
 
-	p.encodeTuple(t, false, true)
+	if err := p.encodeTuple(t, false, true); err != nil {
+		return nil, err
+	}
 
 	if hasVersionstamp {
 		var scratch [4]byte
@@ -461,6 +670,8 @@ func (t Tuple) countIncompleteVersionstamps() int {
 			}
 		case Tuple:
 			incompleteCount += e.countIncompleteVersionstamps()
+		case Encoder:
+			incompleteCount += Tuple{e.TupleElement()}.countIncompleteVersionstamps()
 		}
 	}
 
@@ -676,6 +887,9 @@ func decodeTuple(b []byte, nested bool) (Tuple, int, error) {
 
 // Unpack returns the tuple encoded by the provided byte slice, or an error if
 // the key does not correctly encode a FoundationDB tuple.
+//
+// To read the elements of an encoded tuple without unpacking the whole tuple,
+// use a Decoder.
 func Unpack(b []byte) (Tuple, error) {
 	t, _, err := decodeTuple(b, false)
 	return t, err
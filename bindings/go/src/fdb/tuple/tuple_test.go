@@ -3,9 +3,13 @@ package tuple
 import (
 	"bytes"
 	"encoding/gob"
+	"errors"
 	"flag"
+	"math"
+	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -118,3 +122,96 @@ func BenchmarkTuplePacking(b *testing.B) {
 		})
 	}
 }
+
+func TestPackErr(t *testing.T) {
+	large := new(big.Int).Lsh(big.NewInt(1), 2048)
+
+	for _, tc := range []struct {
+		name  string
+		tuple Tuple
+		path  []int
+		typ   reflect.Type
+		err   error
+	}{
+		{"Unsupported", Tuple{"a", make(chan int)}, []int{1}, reflect.TypeOf(make(chan int)), errUnsupportedType},
+		{"Nested", Tuple{"a", Tuple{1, Tuple{struct{}{}}}}, []int{1, 1, 0}, reflect.TypeOf(struct{}{}), errUnsupportedType},
+		{"BigInt", Tuple{large}, []int{0}, reflect.TypeOf(large), errBigIntTooLarge},
+		{"NilBigInt", Tuple{1, (*big.Int)(nil)}, []int{1}, reflect.TypeOf(large), errNilBigInt},
+		{"Versionstamp", Tuple{IncompleteVersionstamp(0)}, []int{0}, reflect.TypeOf(Versionstamp{}), errIncompleteVersionstamp},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.tuple.PackErr()
+			var ee *EncodeError
+			if b != nil || !errors.As(err, &ee) {
+				t.Fatalf("PackErr = %x, %v; want an *EncodeError", b, err)
+			}
+			if ee.Index != tc.path[0] || !reflect.DeepEqual(ee.Path, tc.path) || ee.Type != tc.typ || !errors.Is(err, tc.err) {
+				t.Errorf("PackErr error = %#v, want path %v, type %v and %v", ee, tc.path, tc.typ, tc.err)
+			}
+
+			defer func() {
+				if r := recover(); r == nil || r.(error).Error() != err.Error() {
+					t.Errorf("Pack panicked with %v, want %v", r, err)
+				}
+			}()
+			tc.tuple.Pack()
+		})
+	}
+
+	if b, err := (Tuple{"a", Tuple{1, nil}}).PackErr(); err != nil || !bytes.Equal(b, Tuple{"a", Tuple{1, nil}}.Pack()) {
+		t.Errorf("PackErr = %x, %v", b, err)
+	}
+}
+
+type testID int64
+type testName string
+type testPath Tuple
+type testBytes []byte
+
+type testColor int
+
+func (c testColor) TupleElement() TupleElement {
+	return []string{"red", "green"}[c]
+}
+
+type testULID [16]byte
+
+func (u testULID) TupleElement() TupleElement {
+	return UUID(u)
+}
+
+type testLoop struct{}
+
+func (l testLoop) TupleElement() TupleElement {
+	return l
+}
+
+func TestPackElementTypes(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		tuple     Tuple
+		canonical Tuple
+	}{
+		{"Signed", Tuple{int8(-8), int16(-16), int32(-32), int8(math.MaxInt8)}, Tuple{-8, -16, -32, math.MaxInt8}},
+		{"Unsigned", Tuple{uint8(8), uint16(16), uint32(math.MaxUint32)}, Tuple{8, 16, uint64(math.MaxUint32)}},
+		{"Named", Tuple{testID(-7), testName("name"), testBytes{0x00, 0x01}, true}, Tuple{-7, "name", []byte{0x00, 0x01}, true}},
+		{"NamedTuple", Tuple{testPath{"a", testID(1)}}, Tuple{Tuple{"a", 1}}},
+		{"Encoder", Tuple{testColor(1), testULID(testUUID), Tuple{testColor(0)}}, Tuple{"green", testUUID, Tuple{"red"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.tuple.PackErr()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := tc.canonical.Pack(); !bytes.Equal(b, want) {
+				t.Errorf("PackErr = %x, want %x", b, want)
+			}
+		})
+	}
+
+	_, err := Tuple{"a", testLoop{}}.PackErr()
+	var ee *EncodeError
+	if !errors.As(err, &ee) || ee.Index != 1 || !errors.Is(err, errEncoderLoop) {
+		t.Errorf("PackErr error = %v, want an *EncodeError at index 1", err)
+	}
+}
@@ -0,0 +1,370 @@
+/*
+ * typedfutures.go
+ *
+ * This source file is part of the FoundationDB open source project
+ *
+ * Copyright 2013-2018 Apple Inc. and the FoundationDB project authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// FoundationDB Go API
+
+package fdb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// TypedFuture represents a value of type T (or an error) that will be
+// available at some later time.
+//
+// FutureByteSlice, FutureKey, FutureInt64 and FutureStringSlice all satisfy
+// TypedFuture (for []byte, Key, int64 and []string respectively), so they may
+// be passed directly to Map, FlatMap and Then to build asynchronous pipelines
+// without resorting to interface{} and type assertions.
+type TypedFuture[T any] interface {
+	// Get returns the value of the future, or an error if the asynchronous
+	// operation failed. Get will block until the value is available.
+	Get() (T, error)
+
+	// GetContext is like Get, but returns ctx.Err() if ctx is done before
+	// the future is ready. In that case the future is cancelled.
+	GetContext(ctx context.Context) (T, error)
+
+	// MustGet returns the value of the future, or panics if the asynchronous
+	// operation failed. MustGet will block until the value is available.
+	MustGet() T
+
+	Future
+}
+
+var (
+	_ TypedFuture[[]byte]   = FutureByteSlice(nil)
+	_ TypedFuture[Key]      = FutureKey(nil)
+	_ TypedFuture[int64]    = FutureInt64(nil)
+	_ TypedFuture[[]string] = FutureStringSlice(nil)
+)
+
+// Resolved returns a TypedFuture that is already ready with the provided
+// value and error. It is useful for returning early from a FlatMap callback.
+func Resolved[T any](v T, e error) TypedFuture[T] {
+	return &resolvedFuture[T]{v: v, e: e}
+}
+
+// Map returns a TypedFuture that becomes ready when f does, and whose value is
+// the result of applying fn to the value of f. If f fails, fn is not called and
+// the returned future fails with the same error.
+//
+// fn is called at most once, in the goroutine that first retrieves the value
+// of the returned future. No additional goroutine is created. If fn panics with
+// an Error (for example, by calling MustGet on another future), the returned
+// future fails with that error. If fn panics with any other value, the panic
+// propagates to that goroutine, and the returned future thereafter fails with
+// an error describing the panic.
+func Map[T, U any](f TypedFuture[T], fn func(T) (U, error)) TypedFuture[U] {
+	return Then(f, func(v T, e error) (U, error) {
+		if e != nil {
+			var zero U
+			return zero, e
+		}
+		return fn(v)
+	})
+}
+
+// Then is like Map, except that fn is called whether or not f succeeds, and
+// receives the error (if any) from f. This allows errors to be inspected,
+// replaced or recovered from.
+func Then[T, U any](f TypedFuture[T], fn func(T, error) (U, error)) TypedFuture[U] {
+	return &mappedFuture[T, U]{src: f, fn: fn}
+}
+
+// FlatMap returns a TypedFuture that becomes ready when the future returned by
+// fn does. fn is called with the value of f once f is ready, and may itself
+// start further asynchronous operations (such as additional reads within the
+// same transaction). If f fails, fn is not called and the returned future
+// fails with the same error.
+//
+// fn is called at most once. If the returned future is waited on by Get, MustGet
+// or BlockUntilReady after f has become ready, fn is called in the waiting
+// goroutine. If its readiness is instead observed through Ready, IsReady or
+// GetContext, fn is called in a goroutine started when f becomes ready, so no
+// goroutine is left waiting on an f which never becomes ready. If the returned
+// future is cancelled before fn is called, fn is never called, and the future
+// fails with the error of f (if it has one) or operation_cancelled.
+//
+// Panics in fn are handled as they are by Map, except that a panic with a value
+// other than an Error propagates only if fn was called by Get or MustGet.
+func FlatMap[T, U any](f TypedFuture[T], fn func(T) TypedFuture[U]) TypedFuture[U] {
+	return &flatMappedFuture[T, U]{src: f, fn: fn}
+}
+
+// whenReady arranges for fn to be called once f is ready. The futures of this
+// package call fn from the callback which makes them ready (or at once, if they
+// are already ready), so fn must not block. For other futures, a goroutine
+// waits for f to become ready.
+func whenReady(f Future, fn func()) {
+	if r, ok := f.(interface{ onReady(func()) }); ok {
+		r.onReady(fn)
+		return
+	}
+	if f.IsReady() {
+		fn()
+		return
+	}
+	go func() {
+		<-f.Ready()
+		fn()
+	}()
+}
+
+// waitContext blocks until f is ready or ctx is done, cancelling f in the
+// latter case.
+func waitContext(ctx context.Context, f Future) error {
+	if f.IsReady() {
+		return nil
+	}
+	select {
+	case <-f.Ready():
+		return nil
+	case <-ctx.Done():
+		f.Cancel()
+		return ctx.Err()
+	}
+}
+
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+type resolvedFuture[T any] struct {
+	v T
+	e error
+}
+
+func (f *resolvedFuture[T]) Get() (T, error) {
+	return f.v, f.e
+}
+
+func (f *resolvedFuture[T]) GetContext(ctx context.Context) (T, error) {
+	return f.v, f.e
+}
+
+func (f *resolvedFuture[T]) MustGet() T {
+	if f.e != nil {
+		panic(f.e)
+	}
+	return f.v
+}
+
+func (f *resolvedFuture[T]) err() error {
+	return f.e
+}
+
+func (f *resolvedFuture[T]) BlockUntilReady()       {}
+func (f *resolvedFuture[T]) IsReady() bool          { return true }
+func (f *resolvedFuture[T]) Ready() <-chan struct{} { return closedChan }
+func (f *resolvedFuture[T]) Cancel()                {}
+func (f *resolvedFuture[T]) onReady(fn func())      { fn() }
+
+type mappedFuture[T, U any] struct {
+	src  TypedFuture[T]
+	fn   func(T, error) (U, error)
+	once sync.Once
+	v    U
+	e    error
+}
+
+// recoverFunc records in *e the panic value r of the function of a mapped
+// future, so that the future fails rather than appearing to succeed after the
+// panic. An Error is recorded as it is; any other value is described by the
+// error recorded, and returned to be panicked with again.
+func recoverFunc(r interface{}, e *error) interface{} {
+	if r == nil {
+		return nil
+	}
+	if fe, ok := r.(Error); ok {
+		*e = fe
+		return nil
+	}
+	*e = fmt.Errorf("panic in function of future: %v", r)
+	return r
+}
+
+func (f *mappedFuture[T, U]) Get() (U, error) {
+	var p interface{}
+	f.once.Do(func() {
+		defer func() {
+			p = recoverFunc(recover(), &f.e)
+		}()
+		v, e := f.src.Get()
+		f.v, f.e = f.fn(v, e)
+	})
+	if p != nil {
+		panic(p)
+	}
+	return f.v, f.e
+}
+
+func (f *mappedFuture[T, U]) GetContext(ctx context.Context) (U, error) {
+	if e := waitContext(ctx, f); e != nil {
+		var zero U
+		return zero, e
+	}
+	return f.Get()
+}
+
+func (f *mappedFuture[T, U]) MustGet() U {
+	v, e := f.Get()
+	if e != nil {
+		panic(e)
+	}
+	return v
+}
+
+func (f *mappedFuture[T, U]) err() error {
+	_, e := f.Get()
+	return e
+}
+
+func (f *mappedFuture[T, U]) BlockUntilReady()       { f.src.BlockUntilReady() }
+func (f *mappedFuture[T, U]) IsReady() bool          { return f.src.IsReady() }
+func (f *mappedFuture[T, U]) Ready() <-chan struct{} { return f.src.Ready() }
+func (f *mappedFuture[T, U]) Cancel()                { f.src.Cancel() }
+func (f *mappedFuture[T, U]) onReady(fn func())      { whenReady(f.src, fn) }
+
+type flatMappedFuture[T, U any] struct {
+	src       TypedFuture[T]
+	fn        func(T) TypedFuture[U]
+	nextOnce  sync.Once
+	next      TypedFuture[U]
+	resolved  atomic.Bool
+	cancelled atomic.Bool
+	readyOnce sync.Once
+	ready     *readyState
+}
+
+// inner waits for the source future and returns the future produced by fn,
+// panicking again if fn was called and panicked with a value other than an
+// Error.
+func (f *flatMappedFuture[T, U]) inner() TypedFuture[U] {
+	next, p := f.resolve()
+	if p != nil {
+		panic(p)
+	}
+	return next
+}
+
+// resolve waits for the source future and returns the future produced by fn
+// (or a future failing with the error of the source, or with which fn
+// panicked), and the value with which fn panicked if it did so during this
+// call.
+func (f *flatMappedFuture[T, U]) resolve() (next TypedFuture[U], p interface{}) {
+	f.nextOnce.Do(func() {
+		v, e := f.src.Get()
+		if e == nil && f.cancelled.Load() {
+			e = ErrOperationCancelled
+		}
+		if e == nil {
+			p, e = f.call(v)
+		}
+		if e != nil || f.next == nil {
+			var zero U
+			f.next = Resolved(zero, e)
+		}
+		f.resolved.Store(true)
+	})
+	return f.next, p
+}
+
+func (f *flatMappedFuture[T, U]) call(v T) (p interface{}, e error) {
+	defer func() {
+		p = recoverFunc(recover(), &e)
+	}()
+	f.next = f.fn(v)
+	return
+}
+
+func (f *flatMappedFuture[T, U]) Get() (U, error) {
+	return f.inner().Get()
+}
+
+func (f *flatMappedFuture[T, U]) GetContext(ctx context.Context) (U, error) {
+	if e := waitContext(ctx, f); e != nil {
+		var zero U
+		return zero, e
+	}
+	return f.Get()
+}
+
+func (f *flatMappedFuture[T, U]) MustGet() U {
+	return f.inner().MustGet()
+}
+
+func (f *flatMappedFuture[T, U]) err() error {
+	_, e := f.Get()
+	return e
+}
+
+func (f *flatMappedFuture[T, U]) BlockUntilReady() {
+	next, _ := f.resolve()
+	next.BlockUntilReady()
+}
+
+func (f *flatMappedFuture[T, U]) IsReady() bool {
+	select {
+	case <-f.Ready():
+		return true
+	default:
+		return false
+	}
+}
+
+// readyState chains the readiness of the inner future onto that of the source
+// future, so no goroutine runs until the source is ready. fn may block, so it
+// is then called in a new goroutine rather than in the callback of the source.
+func (f *flatMappedFuture[T, U]) readyState() *readyState {
+	f.readyOnce.Do(func() {
+		f.ready = &readyState{ch: make(chan struct{})}
+		whenReady(f.src, func() {
+			go func() {
+				next, _ := f.resolve()
+				whenReady(next, f.ready.fire)
+			}()
+		})
+	})
+	return f.ready
+}
+
+func (f *flatMappedFuture[T, U]) Ready() <-chan struct{} {
+	return f.readyState().ch
+}
+
+func (f *flatMappedFuture[T, U]) onReady(fn func()) {
+	f.readyState().add(fn)
+}
+
+// Cancel cancels the source future and, if fn has been called, the future it
+// returned. If fn has not been called, it never will be.
+func (f *flatMappedFuture[T, U]) Cancel() {
+	f.cancelled.Store(true)
+	f.src.Cancel()
+	if f.resolved.Load() {
+		f.next.Cancel()
+	}
+}
@@ -30,9 +30,9 @@ import (
 
 const CHUNK_SIZE int = 5
 
-func write_blob(t fdb.Transactor, blob_subspace subspace.Subspace, blob []byte) (err error) {
+func write_blob(t fdb.ReadWriterTransactor, blob_subspace subspace.Subspace, blob []byte) (err error) {
 
-	_, err = t.Transact(func(tr fdb.Transaction) (interface{}, error) {
+	_, err = t.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 
 		if len(blob) == 0 {
 			return nil, nil
@@ -50,9 +50,9 @@ func write_blob(t fdb.Transactor, blob_subspace subspace.Subspace, blob []byte)
 	return
 }
 
-func read_blob(t fdb.ReadTransactor, blob_subspace subspace.Subspace) ([]byte, error) {
+func read_blob(t fdb.ReaderTransactor, blob_subspace subspace.Subspace) ([]byte, error) {
 
-	blb, err := t.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+	blb, err := t.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 
 		var blob []byte
 
@@ -31,17 +31,17 @@ import (
 	"math/rand"
 )
 
-func clear_subspace(trtr fdb.Transactor, sub subspace.Subspace) error {
-	_, err := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func clear_subspace(trtr fdb.ReadWriterTransactor, sub subspace.Subspace) error {
+	_, err := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.ClearRange(sub)
 		return nil, nil
 	})
 	return err
 }
 
-func print_subspace(trtr fdb.Transactor, sub subspace.Subspace) {
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		k := tr.GetRange(sub, fdb.RangeOptions{0, -1, false}).Iterator()
+func print_subspace(trtr fdb.ReadWriterTransactor, sub subspace.Subspace) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		k := tr.GetRange(sub, fdb.RangeOptions{Limit: 0, Mode: fdb.StreamingModeWantAll, Reverse: false}).Iterator()
 
 		for k.Advance() {
 			fmt.Println(_unpack(k.MustGet().Value))
@@ -150,7 +150,7 @@ type Doc struct {
 	DocSS subspace.Subspace
 }
 
-func (doc Doc) InsertDoc(trtr fdb.Transactor, docdata []byte) int {
+func (doc Doc) InsertDoc(trtr fdb.ReadWriterTransactor, docdata []byte) int {
 	var data interface{}
 	json.Unmarshal(docdata, &data)
 	docid := 0
@@ -164,7 +164,7 @@ func (doc Doc) InsertDoc(trtr fdb.Transactor, docdata []byte) int {
 			docid = temp.(int)
 		}
 		tuples := ToTuples(d)
-		trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 			for _, t := range tuples {
 				tr.Set(doc.DocSS.Pack(append(tuple.Tuple{d["doc_id"]}, t[0:len(t)-1]...)), _pack(t[len(t)-1]))
 			}
@@ -174,9 +174,9 @@ func (doc Doc) InsertDoc(trtr fdb.Transactor, docdata []byte) int {
 	return docid
 }
 
-func (doc Doc) _GetNewID(trtr fdb.Transactor) int {
+func (doc Doc) _GetNewID(trtr fdb.ReadWriterTransactor) int {
 	new_id := rand.Intn(100000007)
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		for true {
 			new_id = rand.Intn(100000007)
 			rp, err := fdb.PrefixRange(doc.DocSS.Pack(tuple.Tuple{new_id}))
@@ -184,7 +184,7 @@ func (doc Doc) _GetNewID(trtr fdb.Transactor) int {
 				continue
 			}
 
-			res, err := tr.GetRange(rp, fdb.RangeOptions{1, -1, false}).GetSliceWithError()
+			res, err := tr.GetRange(rp, fdb.RangeOptions{Limit: 1, Mode: fdb.StreamingModeWantAll, Reverse: false}).GetSliceWithError()
 			if len(res) == 0 {
 				break
 			}
@@ -194,9 +194,9 @@ func (doc Doc) _GetNewID(trtr fdb.Transactor) int {
 	return new_id
 }
 
-func (doc Doc) GetDoc(trtr fdb.Transactor, doc_id int) interface{} {
+func (doc Doc) GetDoc(trtr fdb.ReadWriterTransactor, doc_id int) interface{} {
 	tuples := make([]tuple.Tuple, 0)
-	trtr.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+	trtr.ReadTransactReader(func(tr fdb.Reader) (interface{}, error) {
 		kr, err := fdb.PrefixRange(doc.DocSS.Pack(tuple.Tuple{doc_id}))
 		if err != nil {
 			panic(err)
@@ -29,8 +29,8 @@ import (
 	"log"
 )
 
-func clear_subspace(trtr fdb.Transactor, sub subspace.Subspace) error {
-	_, err := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func clear_subspace(trtr fdb.ReadWriterTransactor, sub subspace.Subspace) error {
+	_, err := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.ClearRange(sub)
 		return nil, nil
 	})
@@ -48,8 +48,8 @@ func (graph *Graph) NewGraph(dir subspace.Subspace, name string) {
 	graph.InvSpace = ss.Sub("inv")
 }
 
-func (graph *Graph) set_edge(trtr fdb.Transactor, node, neighbor int) (inter interface{}, err error) {
-	inter, err = trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (graph *Graph) set_edge(trtr fdb.ReadWriterTransactor, node, neighbor int) (inter interface{}, err error) {
+	inter, err = trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.Set(graph.EdgeSpace.Pack(tuple.Tuple{node, neighbor}), []byte(""))
 		tr.Set(graph.InvSpace.Pack(tuple.Tuple{neighbor, node}), []byte(""))
 		return nil, nil
@@ -57,8 +57,8 @@ func (graph *Graph) set_edge(trtr fdb.Transactor, node, neighbor int) (inter int
 	return
 }
 
-func (graph *Graph) del_edge(trtr fdb.Transactor, node, neighbor int) (inter interface{}, err error) {
-	inter, err = trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (graph *Graph) del_edge(trtr fdb.ReadWriterTransactor, node, neighbor int) (inter interface{}, err error) {
+	inter, err = trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.Clear(graph.EdgeSpace.Pack(tuple.Tuple{node, neighbor}))
 		tr.Clear(graph.InvSpace.Pack(tuple.Tuple{neighbor, node}))
 		return nil, nil
@@ -66,9 +66,9 @@ func (graph *Graph) del_edge(trtr fdb.Transactor, node, neighbor int) (inter int
 	return
 }
 
-func (graph *Graph) get_out_neighbors(trtr fdb.Transactor, node int) ([]int, error) {
+func (graph *Graph) get_out_neighbors(trtr fdb.ReadWriterTransactor, node int) ([]int, error) {
 
-	val, err := trtr.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+	val, err := trtr.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 
 		kr, err := fdb.PrefixRange(graph.EdgeSpace.Pack(tuple.Tuple{node}))
 		if err != nil {
@@ -95,8 +95,8 @@ func (graph *Graph) get_out_neighbors(trtr fdb.Transactor, node int) ([]int, err
 	return val.([]int), err
 }
 
-func (graph *Graph) get_in_neighbors(trtr fdb.Transactor, node int) ([]int, error) {
-	val, err := trtr.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+func (graph *Graph) get_in_neighbors(trtr fdb.ReadWriterTransactor, node int) ([]int, error) {
+	val, err := trtr.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 
 		kr, err := fdb.PrefixRange(graph.InvSpace.Pack(tuple.Tuple{node}))
 		if err != nil {
@@ -29,17 +29,17 @@ import (
 	"log"
 )
 
-func clear_subspace(trtr fdb.Transactor, sub subspace.Subspace) error {
-	_, err := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func clear_subspace(trtr fdb.ReadWriterTransactor, sub subspace.Subspace) error {
+	_, err := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.ClearRange(sub)
 		return nil, nil
 	})
 	return err
 }
 
-func print_subspace(trtr fdb.Transactor, sub subspace.Subspace) {
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		k := tr.GetRange(sub, fdb.RangeOptions{0, -1, false}).Iterator()
+func print_subspace(trtr fdb.ReadWriterTransactor, sub subspace.Subspace) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		k := tr.GetRange(sub, fdb.RangeOptions{Limit: 0, Mode: fdb.StreamingModeWantAll, Reverse: false}).Iterator()
 
 		for k.Advance() {
 			fmt.Println(_unpack(k.MustGet().Value))
@@ -61,18 +61,18 @@ func _unpack(t []byte) tuple.Tuple {
 }
 
 type Workspace struct {
-	Dir directory.Directory
+	Dir directory.ReadWriterDirectory
 	db  fdb.Database
 }
 
-func (wrkspc Workspace) _Update(trtr fdb.Transactor) {
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		_, err := wrkspc.Dir.Remove(tr, []string{"current"})
+func (wrkspc Workspace) _Update(trtr fdb.ReadWriterTransactor) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		_, err := wrkspc.Dir.RemoveReadWriter(tr, []string{"current"})
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		_, err = wrkspc.Dir.Move(tr, []string{"new"}, []string{"current"})
+		_, err = wrkspc.Dir.MoveReadWriter(tr, []string{"new"}, []string{"current"})
 		return nil, err
 	})
 }
@@ -104,12 +104,12 @@ func main() {
 
 	clear_subspace(db, WorkspaceDemoDir)
 
-	w := Workspace{WorkspaceDemoDir, db}
+	w := Workspace{WorkspaceDemoDir.(directory.ReadWriterDirectory), db}
 	current, err := w.GetCurrent()
 
 	clear_subspace(db, current)
 
-	db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+	db.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.Set(current.Pack(tuple.Tuple{"a"}), _pack("Hello"))
 		return nil, nil
 	})
@@ -117,7 +117,7 @@ func main() {
 	print_subspace(db, current)
 
 	w.Session(func(dir directory.DirectorySubspace) {
-		db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		db.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 			tr.Set(dir.Pack(tuple.Tuple{"b"}), _pack("World"))
 			return nil, nil
 		})
@@ -30,8 +30,8 @@ import (
 	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
 )
 
-func clear_subspace(db fdb.Transactor, ss subspace.Subspace) {
-	db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func clear_subspace(db fdb.ReadWriterTransactor, ss subspace.Subspace) {
+	db.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.ClearRange(ss)
 		return nil, nil
 	})
@@ -49,15 +49,15 @@ func (multi *MultiMap) NewMultiMap(ss subspace.Subspace) {
 	multi.Pos, multi.Neg = []byte{1, 0, 0, 0}, []byte{255, 255, 255, 255}
 }
 
-func (multi MultiMap) MultiAdd(trtr fdb.Transactor, index, value interface{}) {
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (multi MultiMap) MultiAdd(trtr fdb.ReadWriterTransactor, index, value interface{}) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.Add(multi.MapSS.Pack(tuple.Tuple{index, value}), multi.Pos)
 		return nil, nil
 	})
 }
 
-func (multi MultiMap) MultiSubtract(trtr fdb.Transactor, index, value interface{}) {
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (multi MultiMap) MultiSubtract(trtr fdb.ReadWriterTransactor, index, value interface{}) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		item := tr.Get(multi.MapSS.Pack(tuple.Tuple{index, value})).MustGet()
 
 		if item == nil {
@@ -69,13 +69,13 @@ func (multi MultiMap) MultiSubtract(trtr fdb.Transactor, index, value interface{
 	})
 }
 
-func (multi MultiMap) MultiGet(tr fdb.ReadTransactor, index int) (ret []interface{}, e error) {
-	_, e = tr.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+func (multi MultiMap) MultiGet(tr fdb.ReaderTransactor, index int) (ret []interface{}, e error) {
+	_, e = tr.ReadTransactReader(func(tr fdb.Reader) (interface{}, error) {
 		pr, err := fdb.PrefixRange(multi.MapSS.Pack(tuple.Tuple{index}))
 		if err != nil {
 			return nil, err
 		}
-		kvs := tr.GetRange(pr, fdb.RangeOptions{0, -1, false}).GetSliceOrPanic()
+		kvs := tr.GetRange(pr, fdb.RangeOptions{Limit: 0, Mode: fdb.StreamingModeWantAll, Reverse: false}).GetSliceOrPanic()
 		ret := make([]interface{}, len(kvs))
 		i := 0
 		for _, kv := range kvs {
@@ -91,8 +91,8 @@ func (multi MultiMap) MultiGet(tr fdb.ReadTransactor, index int) (ret []interfac
 	return
 }
 
-func (multi MultiMap) MultiGetCounts(trtr fdb.Transactor, index interface{}) (map[interface{}]int, error) {
-	i, e := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (multi MultiMap) MultiGetCounts(trtr fdb.ReadWriterTransactor, index interface{}) (map[interface{}]int, error) {
+	i, e := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		kr, err := fdb.PrefixRange(multi.MapSS.Pack(tuple.Tuple{}))
 		if err != nil {
 			return nil, err
@@ -119,8 +119,8 @@ func (multi MultiMap) MultiGetCounts(trtr fdb.Transactor, index interface{}) (ma
 	return i.(map[interface{}]int), e
 }
 
-func (multi MultiMap) MultiIsElement(trtr fdb.Transactor, index, value interface{}) bool {
-	item, _ := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (multi MultiMap) MultiIsElement(trtr fdb.ReadWriterTransactor, index, value interface{}) bool {
+	item, _ := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		r := tr.Get(multi.MapSS.Pack(tuple.Tuple{index, value})).MustGet()
 		if r == nil {
 			return false, nil
@@ -30,8 +30,8 @@ import (
 	"math/rand"
 )
 
-func clear_subspace(trtr fdb.Transactor, sub subspace.Subspace) error {
-	_, err := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func clear_subspace(trtr fdb.ReadWriterTransactor, sub subspace.Subspace) error {
+	_, err := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.ClearRange(sub)
 		return nil, nil
 	})
@@ -54,21 +54,21 @@ type Priority struct {
 	PrioritySS subspace.Subspace
 }
 
-func (prty Priority) Push(trtr fdb.Transactor, value interface{}, priority int) {
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (prty Priority) Push(trtr fdb.ReadWriterTransactor, value interface{}, priority int) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.Set(prty.PrioritySS.Pack(tuple.Tuple{priority, prty._NextCount(tr, priority), rand.Intn(20)}), _pack(value))
 		return nil, nil
 	})
 }
 
-func (prty Priority) _NextCount(trtr fdb.Transactor, priority int) int {
-	res, err := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (prty Priority) _NextCount(trtr fdb.ReadWriterTransactor, priority int) int {
+	res, err := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		kr, e := fdb.PrefixRange(prty.PrioritySS.Pack(tuple.Tuple{priority}))
 		if e != nil {
 			return nil, e
 		}
 
-		ks, e := tr.Snapshot().GetRange(kr, fdb.RangeOptions{1, -1, true}).GetSliceWithError()
+		ks, e := tr.SnapshotReader().GetRange(kr, fdb.RangeOptions{Limit: 1, Mode: fdb.StreamingModeWantAll, Reverse: true}).GetSliceWithError()
 		if e != nil {
 			return nil, e
 		}
@@ -85,9 +85,9 @@ func (prty Priority) _NextCount(trtr fdb.Transactor, priority int) int {
 	return res.(int)
 }
 
-func (prty Priority) Pop(trtr fdb.Transactor, max bool) interface{} {
-	res, _ := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		ks, err := tr.GetRange(prty.PrioritySS, fdb.RangeOptions{1, -1, max}).GetSliceWithError()
+func (prty Priority) Pop(trtr fdb.ReadWriterTransactor, max bool) interface{} {
+	res, _ := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		ks, err := tr.GetRange(prty.PrioritySS, fdb.RangeOptions{Limit: 1, Mode: fdb.StreamingModeWantAll, Reverse: max}).GetSliceWithError()
 		if err != nil {
 			return nil, err
 		}
@@ -101,9 +101,9 @@ func (prty Priority) Pop(trtr fdb.Transactor, max bool) interface{} {
 	return res
 }
 
-func (prty Priority) Peek(trtr fdb.Transactor, max bool) interface{} {
-	res, _ := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
-		ks, err := tr.GetRange(prty.PrioritySS, fdb.RangeOptions{1, -1, max}).GetSliceWithError()
+func (prty Priority) Peek(trtr fdb.ReadWriterTransactor, max bool) interface{} {
+	res, _ := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
+		ks, err := tr.GetRange(prty.PrioritySS, fdb.RangeOptions{Limit: 1, Mode: fdb.StreamingModeWantAll, Reverse: max}).GetSliceWithError()
 		if err != nil {
 			return nil, err
 		}
@@ -35,8 +35,8 @@ func (q EmptyQueueError) Error() string {
 	return "Queue is Empty"
 }
 
-func clear_subspace(trtr fdb.Transactor, sub subspace.Subspace) error {
-	_, err := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func clear_subspace(trtr fdb.ReadWriterTransactor, sub subspace.Subspace) error {
+	_, err := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.ClearRange(sub)
 		return nil, nil
 	})
@@ -51,26 +51,25 @@ func (q *Queue) NewQueue(ss subspace.Subspace) {
 	q.QueueSS = ss
 }
 
-func (q *Queue) Dequeue(trtr fdb.Transactor) (interface{}, error) {
-	i, e := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (q *Queue) Dequeue(trtr fdb.ReadWriterTransactor) ([]byte, error) {
+	return fdb.TransactReadWriter(trtr, func(tr fdb.ReadWriter) ([]byte, error) {
 		item, err := q.FirstItem(tr)
 		if err != nil {
 			return nil, err
 		}
-		tr.Clear(item.(fdb.KeyValue).Key)
-		return item.(fdb.KeyValue).Value, err
+		tr.Clear(item.Key)
+		return item.Value, nil
 	})
-	return i, e
 }
 
-func (q *Queue) Enqueue(trtr fdb.Transactor, item interface{}) (interface{}, error) {
-	i, e := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (q *Queue) Enqueue(trtr fdb.ReadWriterTransactor, item interface{}) (interface{}, error) {
+	i, e := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		index, err := q.LastIndex(tr)
 		if err != nil {
 			return nil, err
 		}
 
-		ki, err := q.QueueSS.Unpack(index.(fdb.Key))
+		ki, err := q.QueueSS.Unpack(index)
 		if err != nil {
 			return nil, err
 		}
@@ -82,26 +81,24 @@ func (q *Queue) Enqueue(trtr fdb.Transactor, item interface{}) (interface{}, err
 	return i, e
 }
 
-func (q *Queue) LastIndex(trtr fdb.Transactor) (interface{}, error) {
-	i, e := trtr.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
-		r, err := rtr.Snapshot().GetRange(q.QueueSS, fdb.RangeOptions{1, 0, true}).GetSliceWithError()
+func (q *Queue) LastIndex(trtr fdb.ReaderTransactor) (fdb.Key, error) {
+	return fdb.ReadTransactReader(trtr, func(rtr fdb.Reader) (fdb.Key, error) {
+		r, err := rtr.SnapshotReader().GetRange(q.QueueSS, fdb.RangeOptions{Limit: 1, Mode: fdb.StreamingModeIterator, Reverse: true}).GetSliceWithError()
 		if len(r) == 0 {
 			return q.QueueSS.Pack(tuple.Tuple{0}), nil
 		}
 		return r[0].Key, err
 	})
-	return i, e
 }
 
-func (q *Queue) FirstItem(trtr fdb.Transactor) (interface{}, error) {
-	i, e := trtr.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
-		r, err := rtr.GetRange(q.QueueSS, fdb.RangeOptions{1, 0, false}).GetSliceWithError()
+func (q *Queue) FirstItem(trtr fdb.ReaderTransactor) (fdb.KeyValue, error) {
+	return fdb.ReadTransactReader(trtr, func(rtr fdb.Reader) (fdb.KeyValue, error) {
+		r, err := rtr.GetRange(q.QueueSS, fdb.RangeOptions{Limit: 1, Mode: fdb.StreamingModeIterator, Reverse: false}).GetSliceWithError()
 		if len(r) == 0 {
-			return nil, EmptyQueueError{}
+			return fdb.KeyValue{}, EmptyQueueError{}
 		}
 		return r[0], err
 	})
-	return i, e
 }
 
 func main() {
@@ -121,16 +118,20 @@ func main() {
 	var q Queue
 	q.NewQueue(QueueDemoDir.Sub("Queue"))
 
-	q.Enqueue(db, "test")
-	q.Enqueue(db, "test1")
-	q.Enqueue(db, "test2")
-	q.Enqueue(db, "test3")
+	// Enqueue is not idempotent: if a commit fails with commit_unknown_result
+	// but actually succeeded, a plain retry would enqueue the item twice.
+	idb := db.WithIdempotency(QueueDemoDir.Sub("Idempotency"))
+
+	q.Enqueue(idb, "test")
+	q.Enqueue(idb, "test1")
+	q.Enqueue(idb, "test2")
+	q.Enqueue(idb, "test3")
 	for i := 0; i < 5; i++ {
 		item, e := q.Dequeue(db)
 		if e != nil {
 			log.Fatal(e)
 		}
 
-		fmt.Println(string(item.([]byte)))
+		fmt.Println(string(item))
 	}
 }
@@ -29,8 +29,8 @@ import (
 	"log"
 )
 
-func clear_subspace(trtr fdb.Transactor, sub subspace.Subspace) error {
-	_, err := trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func clear_subspace(trtr fdb.ReadWriterTransactor, sub subspace.Subspace) error {
+	_, err := trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.ClearRange(sub)
 		return nil, nil
 	})
@@ -58,24 +58,24 @@ func (tbl *Table) NewTable(ss subspace.Subspace) {
 	tbl.col = ss.Sub("col")
 }
 
-func (tbl Table) TableSetCell(trtr fdb.Transactor, row, column int, value interface{}) {
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (tbl Table) TableSetCell(trtr fdb.ReadWriterTransactor, row, column int, value interface{}) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		tr.Set(tbl.row.Pack(tuple.Tuple{row, column}), _pack(value))
 		tr.Set(tbl.col.Pack(tuple.Tuple{column, row}), _pack(value))
 		return nil, nil
 	})
 }
 
-func (tbl Table) TableGetCell(trtr fdb.Transactor, row, column int) interface{} {
-	item, _ := trtr.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+func (tbl Table) TableGetCell(trtr fdb.ReadWriterTransactor, row, column int) interface{} {
+	item, _ := trtr.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 		i := rtr.Get(tbl.row.Pack(tuple.Tuple{row, column})).MustGet()
 		return i, nil
 	})
 	return _unpack(item.([]byte))[0]
 }
 
-func (tbl Table) TableSetRow(trtr fdb.Transactor, row int, cols ...interface{}) {
-	trtr.Transact(func(tr fdb.Transaction) (interface{}, error) {
+func (tbl Table) TableSetRow(trtr fdb.ReadWriterTransactor, row int, cols ...interface{}) {
+	trtr.TransactReadWriter(func(tr fdb.ReadWriter) (interface{}, error) {
 		kr, err := fdb.PrefixRange(tbl.row.Pack(tuple.Tuple{row}))
 		if err != nil {
 			return nil, err
@@ -91,14 +91,14 @@ func (tbl Table) TableSetRow(trtr fdb.Transactor, row int, cols ...interface{})
 	return
 }
 
-func (tbl Table) TableGetRow(tr fdb.ReadTransactor, row int) ([]interface{}, error) {
-	item, err := tr.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+func (tbl Table) TableGetRow(tr fdb.ReaderTransactor, row int) ([]interface{}, error) {
+	item, err := tr.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 		kr, e := fdb.PrefixRange(tbl.row.Pack(tuple.Tuple{row}))
 		if e != nil {
 			return nil, e
 		}
 
-		slice, e := rtr.GetRange(kr, fdb.RangeOptions{0, -1, false}).GetSliceWithError()
+		slice, e := rtr.GetRange(kr, fdb.RangeOptions{Limit: 0, Mode: fdb.StreamingModeWantAll, Reverse: false}).GetSliceWithError()
 		if e != nil {
 			return nil, e
 		}
@@ -117,14 +117,14 @@ func (tbl Table) TableGetRow(tr fdb.ReadTransactor, row int) ([]interface{}, err
 	return item.([]interface{}), nil
 }
 
-func (tbl Table) TableGetCol(tr fdb.ReadTransactor, col int) ([]interface{}, error) {
-	item, err := tr.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+func (tbl Table) TableGetCol(tr fdb.ReaderTransactor, col int) ([]interface{}, error) {
+	item, err := tr.ReadTransactReader(func(rtr fdb.Reader) (interface{}, error) {
 		kr, e := fdb.PrefixRange(tbl.col.Pack(tuple.Tuple{col}))
 		if e != nil {
 			return nil, e
 		}
 
-		slice, e := rtr.GetRange(kr, fdb.RangeOptions{0, -1, false}).GetSliceWithError()
+		slice, e := rtr.GetRange(kr, fdb.RangeOptions{Limit: 0, Mode: fdb.StreamingModeWantAll, Reverse: false}).GetSliceWithError()
 		if e != nil {
 			return nil, e
 		}